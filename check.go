@@ -0,0 +1,101 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/wtsi-hgi/gst/sla"
+)
+
+// exitProblems is the exit code of the check and validate subcommands when
+// they find problems, distinguishing those from errors.
+const exitProblems = 2
+
+// runCheck implements the check subcommand, reporting samples that have
+// breached their SLA and exiting non-zero if there are any.
+func runCheck(args []string) {
+	checkCmd := flag.NewFlagSet("check", flag.ExitOnError)
+	slaPath := checkCmd.String("sla", "sla.yaml", "Path to a YAML file of SLA rules")
+	holidays := checkCmd.String("holidays", "",
+		"Path to a file of YYYY-MM-DD holiday dates, one per line, excluded from working days")
+	format := checkCmd.String("format", "text", "Output format: text or json")
+	mockPath := checkCmd.String("mock", "", "Path to a sample file (TSV, CSV, JSON or JSON Lines, optionally gzipped) to read instead of the database")
+	strict := checkCmd.Bool("strict", false, "Fail on malformed values in the --mock file, listing each one")
+	timeout := checkCmd.Duration("timeout", 0, "Abandon the query if it takes longer than this (0 for no limit)")
+	query := addQueryFlags(checkCmd)
+
+	checkCmd.Parse(args)
+
+	rules := mustRules(*slaPath, mustCalendar(*holidays))
+
+	samples, err := querySamples(*mockPath, *timeout, query.mustSpec(mustConfig(*query.config)), strictOptions(*strict)...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error querying samples: %v\n", err)
+		os.Exit(1)
+	}
+
+	breaches := rules.Overdue(samples.Samples, time.Now())
+
+	if err = writeBreaches(os.Stdout, breaches, *format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing overdue samples: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(breaches) > 0 {
+		os.Exit(exitProblems)
+	}
+}
+
+// writeBreaches writes breaches to w in the given format.
+func writeBreaches(w io.Writer, breaches []sla.Breach, format string) error {
+	switch format {
+	case "text":
+		for _, b := range breaches {
+			if _, err := fmt.Fprintln(w, b); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case "json":
+		if breaches == nil {
+			breaches = []sla.Breach{}
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(breaches)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
@@ -0,0 +1,253 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// Package config loads gst's layered configuration. Built in defaults are
+// overridden by a YAML config file, then by environment variables, then by
+// command line flags.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/wtsi-hgi/gst/db"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPath is the environment variable that can give the path of the config
+// file.
+const EnvPath = "GST_CONFIG"
+
+// pathFlag is the name of the flag that gives the path of the config file,
+// which isn't itself configured by the file or environment.
+const pathFlag = "config"
+
+// envPrefix starts the name of every environment variable that sets a flag.
+const envPrefix = "GST_"
+
+// redacted replaces secret values when showing the config.
+const redacted = "REDACTED"
+
+// secretWords are the words that mark a setting as secret if its name
+// contains one of them.
+var secretWords = []string{"pass", "secret", "token", "webhook"}
+
+// Config is gst's configuration.
+type Config struct {
+	// Query holds the query params given at the top level of the file.
+	// Settings missing from the file keep their db.DefaultQueryParams values.
+	Query db.QueryParams `yaml:",inline"`
+
+	// Database says which database to connect to.
+	Database db.ConnectionConfig `yaml:"database"`
+
+	// Export and Server set the flags of those subcommands, keyed on the
+	// flag name in snake case, like cache_ttl for --cacheTTL.
+	Export map[string]string `yaml:"export"`
+	Server map[string]string `yaml:"server"`
+}
+
+// Load returns the Config in the YAML file at path, or the defaults if path is
+// empty, with Database overridden by the environment as
+// db.ConnectionConfig.ApplyEnv.
+func Load(path string) (*Config, error) {
+	c := &Config{Query: db.DefaultQueryParams(), Database: db.DefaultConnectionConfig()}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = yaml.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+	}
+
+	c.Database.ApplyEnv()
+
+	return c, nil
+}
+
+// section returns the settings of the named subcommand.
+func (c *Config) section(name string) map[string]string {
+	switch name {
+	case "export":
+		return c.Export
+	case "server":
+		return c.Server
+	default:
+		return nil
+	}
+}
+
+// Apply sets each flag of fs that wasn't given on the command line, from its
+// environment variable if set, or else from the config file section named
+// after fs. The variable is named like GST_SERVER_CACHE_TTL for the --cacheTTL
+// flag of the server subcommand. It must be called after fs is parsed.
+func (c *Config) Apply(fs *flag.FlagSet) error {
+	section := c.section(fs.Name())
+
+	if err := checkSection(fs, section); err != nil {
+		return err
+	}
+
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	var errs []error
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if given[f.Name] || f.Name == pathFlag {
+			return
+		}
+
+		if value, ok := lookup(fs, f.Name, section); ok {
+			if err := fs.Set(f.Name, value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s setting %q: %w", f.Name, value, err))
+			}
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+// checkSection returns an error if section has a setting that isn't one of
+// fs's flags.
+func checkSection(fs *flag.FlagSet, section map[string]string) error {
+	known := make(map[string]bool)
+	fs.VisitAll(func(f *flag.Flag) { known[SnakeCase(f.Name)] = true })
+
+	for key := range section {
+		if !known[key] || key == pathFlag {
+			return fmt.Errorf("unknown setting %q in %s config", key, fs.Name())
+		}
+	}
+
+	return nil
+}
+
+// lookup returns the configured value of fs's named flag, from the
+// environment or else section.
+func lookup(fs *flag.FlagSet, name string, section map[string]string) (string, bool) {
+	if value, ok := os.LookupEnv(EnvName(fs.Name(), name)); ok {
+		return value, true
+	}
+
+	value, ok := section[SnakeCase(name)]
+
+	return value, ok
+}
+
+// EnvName returns the environment variable that sets the named flag of the
+// named subcommand, like GST_SERVER_CACHE_TTL for server's cacheTTL.
+func EnvName(command, flagName string) string {
+	return envPrefix + strings.ToUpper(command+"_"+SnakeCase(flagName))
+}
+
+// SnakeCase converts a camel case flag name like cacheTTL to snake case like
+// cache_ttl.
+func SnakeCase(name string) string {
+	runes := []rune(name)
+
+	var b strings.Builder
+
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && (!unicode.IsUpper(runes[i-1]) ||
+			(i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			b.WriteByte('_')
+		}
+
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
+
+// Show writes c as YAML to w, with the effective values of the flags of each
+// of sets (which should have had Apply called) as their sections. Passwords
+// and other secrets are redacted.
+func (c *Config) Show(w io.Writer, sets ...*flag.FlagSet) error {
+	show := struct {
+		Database db.ConnectionConfig          `yaml:"database"`
+		Commands map[string]map[string]string `yaml:",inline"`
+	}{Database: c.Database, Commands: make(map[string]map[string]string)}
+
+	if show.Database.Password != "" {
+		show.Database.Password = redacted
+	}
+
+	for _, fs := range sets {
+		show.Commands[fs.Name()] = effective(fs)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(show); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+// effective returns the values of fs's flags keyed on their snake case names,
+// with secrets redacted.
+func effective(fs *flag.FlagSet) map[string]string {
+	values := make(map[string]string)
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == pathFlag {
+			return
+		}
+
+		value := f.Value.String()
+		if value != "" && isSecret(f.Name) {
+			value = redacted
+		}
+
+		values[SnakeCase(f.Name)] = value
+	})
+
+	return values
+}
+
+// isSecret returns true if the named setting holds a secret.
+func isSecret(name string) bool {
+	name = strings.ToLower(name)
+
+	for _, word := range secretWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,140 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package config_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/config"
+	"github.com/wtsi-hgi/gst/db"
+)
+
+// setenv sets an environment variable until the current Convey block ends.
+func setenv(key, value string) {
+	So(os.Setenv(key, value), ShouldBeNil)
+	Reset(func() { os.Unsetenv(key) })
+}
+
+func TestConfig(t *testing.T) {
+	Convey("Given a config file and a subcommand's flags", t, func() {
+		path := filepath.Join(t.TempDir(), "gst.yaml")
+		So(os.WriteFile(path, []byte(`sponsors:
+  - Carl Anderson
+database:
+  host: mlwh.example.com
+  user: gst_reader
+  password: hunter2
+server:
+  port: 9000
+  cache_ttl: 10m
+  notify_webhook: https://hooks.example.com/abc
+`), 0600), ShouldBeNil)
+
+		fs := flag.NewFlagSet("server", flag.ContinueOnError)
+		port := fs.Int("port", 8080, "")
+		cacheTTL := fs.Duration("cacheTTL", 5*time.Minute, "")
+		mock := fs.String("mock", "samples.tsv", "")
+		fs.String("notifyWebhook", "", "")
+		fs.String("config", path, "")
+
+		Convey("The file overrides the defaults, the environment overrides the file, and flags override both", func() {
+			setenv("GST_SERVER_CACHE_TTL", "20m")
+			setenv("GST_SQL_PASS", "fromenv")
+			So(fs.Parse([]string{"--port", "7000"}), ShouldBeNil)
+
+			conf, err := config.Load(path)
+			So(err, ShouldBeNil)
+			So(conf.Apply(fs), ShouldBeNil)
+
+			So(*port, ShouldEqual, 7000)
+			So(*cacheTTL, ShouldEqual, 20*time.Minute)
+			So(*mock, ShouldEqual, "samples.tsv")
+			So(conf.Database.Host, ShouldEqual, "mlwh.example.com")
+			So(conf.Database.Port, ShouldEqual, "3306")
+			So(conf.Database.Password, ShouldEqual, "fromenv")
+			So(conf.Query.Sponsors, ShouldResemble, []string{"Carl Anderson"})
+			So(conf.Query.ManifestYears, ShouldEqual, db.DefaultQueryParams().ManifestYears)
+		})
+
+		Convey("Showing the config redacts secrets", func() {
+			So(fs.Parse(nil), ShouldBeNil)
+
+			conf, err := config.Load(path)
+			So(err, ShouldBeNil)
+			So(conf.Apply(fs), ShouldBeNil)
+
+			var buf bytes.Buffer
+			So(conf.Show(&buf, fs), ShouldBeNil)
+
+			out := buf.String()
+			So(out, ShouldContainSubstring, "password: REDACTED")
+			So(out, ShouldContainSubstring, "notify_webhook: REDACTED")
+			So(out, ShouldContainSubstring, "cache_ttl: 10m0s")
+			So(out, ShouldContainSubstring, `port: "9000"`)
+			So(out, ShouldNotContainSubstring, "hunter2")
+			So(out, ShouldNotContainSubstring, "hooks.example.com")
+			So(out, ShouldNotContainSubstring, "config:")
+		})
+
+		Convey("Unknown and invalid settings are errors", func() {
+			So(os.WriteFile(path, []byte("server:\n  colour: blue\n"), 0600), ShouldBeNil)
+
+			conf, err := config.Load(path)
+			So(err, ShouldBeNil)
+			So(conf.Apply(fs), ShouldNotBeNil)
+
+			So(os.WriteFile(path, []byte("server:\n  port: lots\n"), 0600), ShouldBeNil)
+
+			conf, err = config.Load(path)
+			So(err, ShouldBeNil)
+			So(conf.Apply(fs), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Without a config file, defaults and the environment are used", t, func() {
+		setenv("GST_SQL_HOST", "envhost")
+
+		conf, err := config.Load("")
+		So(err, ShouldBeNil)
+		So(conf.Database.Host, ShouldEqual, "envhost")
+		So(conf.Database.Port, ShouldEqual, "3306")
+		So(conf.Query, ShouldResemble, db.DefaultQueryParams())
+	})
+
+	Convey("Flag names convert to snake case and environment variable names", t, func() {
+		So(config.SnakeCase("cacheTTL"), ShouldEqual, "cache_ttl")
+		So(config.SnakeCase("notifyWebhook"), ShouldEqual, "notify_webhook")
+		So(config.SnakeCase("HTTPPort"), ShouldEqual, "http_port")
+		So(config.SnakeCase("port"), ShouldEqual, "port")
+		So(config.EnvName("server", "fullRefresh"), ShouldEqual, "GST_SERVER_FULL_REFRESH")
+	})
+}
@@ -0,0 +1,76 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/wtsi-hgi/gst/config"
+)
+
+// mustConfig returns the config in the file at path (which may be empty for
+// none), overridden by the environment, exiting on error. It is applied to
+// each of sets, which must already be parsed.
+func mustConfig(path string, sets ...*flag.FlagSet) *config.Config {
+	conf, err := config.Load(path)
+	if err == nil {
+		for _, fs := range sets {
+			if err = conf.Apply(fs); err != nil {
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		os.Exit(1)
+	}
+
+	return conf
+}
+
+// runConfig implements the config subcommand, which shows the effective
+// configuration of the database connection and of each of sets' subcommands.
+func runConfig(args []string, sets ...*flag.FlagSet) {
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Println("Expected 'config show'")
+		os.Exit(1)
+	}
+
+	showCmd := flag.NewFlagSet("config show", flag.ExitOnError)
+	path := showCmd.String("config", os.Getenv(config.EnvPath), configUsage)
+
+	showCmd.Parse(args[1:])
+
+	conf := mustConfig(*path, sets...)
+
+	if err := conf.Show(os.Stdout, sets...); err != nil {
+		fmt.Fprintf(os.Stderr, "Error showing config: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,196 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// columnKind is the type of value a column holds.
+type columnKind int
+
+const (
+	stringKind columnKind = iota
+	timeKind
+	intKind
+)
+
+// column is a data field of TrackedSample as it appears in TSV files.
+type column struct {
+	name string
+	kind columnKind
+
+	// key is the name of the TrackedSample field, used in JSON.
+	key string
+
+	// get formats the field's value.
+	get func(s *TrackedSample) string
+
+	// value returns the field's value as a string, time.Time or int, or nil
+	// if it is unset.
+	value func(s *TrackedSample) any
+
+	// set parses value into the field, leaving it unset and returning an error
+	// if value is malformed.
+	set func(s *TrackedSample, value string) error
+
+	// dest returns a destination for sql.Rows.Scan that sets the field,
+	// leaving it unset for NULL.
+	dest func(s *TrackedSample) any
+}
+
+// columns are the data fields of a sample, in the order they are written in
+// TSV output.
+var columns = []column{
+	stringColumn("StudyID", func(s *TrackedSample) *string { return &s.StudyID }),
+	stringColumn("StudyName", func(s *TrackedSample) *string { return &s.StudyName }),
+	stringColumn("FacultySponsor", func(s *TrackedSample) *string { return &s.FacultySponsor }),
+	stringColumn("Programme", func(s *TrackedSample) *string { return &s.Programme }),
+	stringColumn(sampleIDColumn, func(s *TrackedSample) *string { return &s.SangerSampleID }),
+	stringColumn("SupplierName", func(s *TrackedSample) *string { return &s.SupplierName }),
+	timeColumn("ManifestCreated", func(s *TrackedSample) **time.Time { return &s.ManifestCreated }),
+	timeColumn("ManifestUploaded", func(s *TrackedSample) **time.Time { return &s.ManifestUploaded }),
+	timeColumn("LabwareReceived", func(s *TrackedSample) **time.Time { return &s.LabwareReceived }),
+	keyed("LabwareHumanBarcode",
+		stringColumn("Plate/Tube", func(s *TrackedSample) *string { return &s.LabwareHumanBarcode })),
+	timeColumn("OrderMade", func(s *TrackedSample) **time.Time { return &s.OrderMade }),
+	timeColumn("LibraryStart", func(s *TrackedSample) **time.Time { return &s.LibraryStart }),
+	timeColumn("LibraryComplete", func(s *TrackedSample) **time.Time { return &s.LibraryComplete }),
+	intColumn("LibraryTime", func(s *TrackedSample) **int { return &s.LibraryTime }),
+	stringColumn("RunID", func(s *TrackedSample) *string { return &s.RunID }),
+	stringColumn("Platform", func(s *TrackedSample) *string { return &s.Platform }),
+	stringColumn("Pipeline", func(s *TrackedSample) *string { return &s.Pipeline }),
+	timeColumn("SequencingRunStart", func(s *TrackedSample) **time.Time { return &s.SequencingRunStart }),
+	timeColumn("SequencingQCComplete", func(s *TrackedSample) **time.Time { return &s.SequencingQCComplete }),
+	intColumn("SequencingTime", func(s *TrackedSample) **int { return &s.SequencingTime }),
+	stringColumn("QCPass", func(s *TrackedSample) *string { return &s.QCPass }),
+}
+
+// sampleIDColumn is the one column a TSV file must have.
+const sampleIDColumn = "SangerSampleID"
+
+// columnNames returns the names of the given columns.
+func columnNames(cols []column) []string {
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = col.name
+	}
+
+	return names
+}
+
+// keyed returns col with the given key, for columns whose name isn't that of
+// their field.
+func keyed(key string, col column) column {
+	col.key = key
+
+	return col
+}
+
+// stringColumn returns a column for the string field that field points to.
+func stringColumn(name string, field func(*TrackedSample) *string) column {
+	return column{
+		name:  name,
+		kind:  stringKind,
+		key:   name,
+		get:   func(s *TrackedSample) string { return *field(s) },
+		value: func(s *TrackedSample) any { return *field(s) },
+		set: func(s *TrackedSample, value string) error {
+			*field(s) = value
+
+			return nil
+		},
+		dest: func(s *TrackedSample) any { return nullableString{field(s)} },
+	}
+}
+
+// timeColumn returns a column for the RFC3339 time field that field points
+// to, which is nil when empty.
+func timeColumn(name string, field func(*TrackedSample) **time.Time) column {
+	return column{
+		name: name,
+		kind: timeKind,
+		key:  name,
+		get:  func(s *TrackedSample) string { return formatTimePointer(*field(s)) },
+		value: func(s *TrackedSample) any {
+			if t := *field(s); t != nil {
+				return *t
+			}
+
+			return nil
+		},
+		set: func(s *TrackedSample, value string) error {
+			if value == "" {
+				return nil
+			}
+
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return fmt.Errorf("invalid time %q, expected a format like 2006-01-02T15:04:05Z", value)
+			}
+
+			*field(s) = &t
+
+			return nil
+		},
+		dest: func(s *TrackedSample) any { return field(s) },
+	}
+}
+
+// intColumn returns a column for the integer field that field points to,
+// which is nil when empty.
+func intColumn(name string, field func(*TrackedSample) **int) column {
+	return column{
+		name: name,
+		kind: intKind,
+		key:  name,
+		get:  func(s *TrackedSample) string { return formatIntPointer(*field(s)) },
+		value: func(s *TrackedSample) any {
+			if i := *field(s); i != nil {
+				return *i
+			}
+
+			return nil
+		},
+		set: func(s *TrackedSample, value string) error {
+			if value == "" {
+				return nil
+			}
+
+			i, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid whole number %q", value)
+			}
+
+			*field(s) = &i
+
+			return nil
+		},
+		dest: func(s *TrackedSample) any { return field(s) },
+	}
+}
@@ -26,70 +26,269 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"embed"
 	"fmt"
+	"net"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/joho/godotenv"
+	"github.com/go-sql-driver/mysql"
 )
 
-//go:embed query.sql
+//go:embed query.sql detail.sql
 var sqlFiles embed.FS
 
 // DBConnector provides an interface to connect to a database.
 type DBConnector interface {
-	Connect() (*sql.DB, error)
+	// Connect returns the database, which may be a pool of connections that
+	// is shared by every call until Close.
+	Connect(ctx context.Context) (*sql.DB, error)
 	Close() error
 }
 
-// MySQLConnector implements DBConnector for MySQL databases.
-type MySQLConnector struct {
-	db *sql.DB
+// ConnectionConfig holds the details of the MySQL database to connect to, and
+// how the pool of connections to it is managed.
+type ConnectionConfig struct {
+	User string `yaml:"user"`
+
+	// Password is ignored if PasswordFile is set.
+	Password string `yaml:"password"`
+
+	// PasswordFile is the path to a file holding the password, such as a
+	// mounted secret. It is read for every new connection, so it can be
+	// rotated.
+	PasswordFile string `yaml:"password_file"`
+
+	Host string `yaml:"host"`
+	Port string `yaml:"port"`
+	Name string `yaml:"name"`
+
+	// MaxOpenConns limits the number of open connections (0 for no limit).
+	MaxOpenConns int `yaml:"max_open_conns"`
+
+	// MaxIdleConns is how many idle connections are kept for reuse.
+	MaxIdleConns int `yaml:"max_idle_conns"`
+
+	// ConnMaxLifetime is how long a connection is reused before being
+	// replaced (0 to reuse forever).
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+
+	// HealthInterval is how often the database is pinged while connected, so
+	// that broken connections are replaced before a query needs them (0 to
+	// not ping).
+	HealthInterval time.Duration `yaml:"health_interval"`
+
+	// Retry says how queries that fail with transient errors are retried.
+	Retry RetryPolicy `yaml:"retry"`
+}
+
+// DefaultConnectionConfig returns the connection details used where none are
+// configured.
+func DefaultConnectionConfig() ConnectionConfig {
+	return ConnectionConfig{
+		Port:            "3306",
+		MaxOpenConns:    4,
+		MaxIdleConns:    2,
+		ConnMaxLifetime: 30 * time.Minute,
+		HealthInterval:  time.Minute,
+		Retry:           DefaultRetryPolicy(),
+	}
+}
+
+// ApplyEnv overrides c with any of the GST_SQL_USER, GST_SQL_PASS,
+// GST_SQL_PASS_FILE, GST_SQL_HOST, GST_SQL_PORT and GST_SQL_DB environment
+// variables that are set. GST_SQL_PASS replaces any PasswordFile, so that it
+// isn't ignored.
+func (c *ConnectionConfig) ApplyEnv() {
+	if pass, ok := os.LookupEnv("GST_SQL_PASS"); ok {
+		c.Password, c.PasswordFile = pass, ""
+	}
+
+	for name, field := range map[string]*string{
+		"GST_SQL_USER":      &c.User,
+		"GST_SQL_PASS_FILE": &c.PasswordFile,
+		"GST_SQL_HOST":      &c.Host,
+		"GST_SQL_PORT":      &c.Port,
+		"GST_SQL_DB":        &c.Name,
+	} {
+		if value, ok := os.LookupEnv(name); ok {
+			*field = value
+		}
+	}
+}
+
+// DSN returns the data source name to open the database with, reading the
+// password from PasswordFile if it is set.
+func (c ConnectionConfig) DSN() (string, error) {
+	cfg, err := c.mysqlConfig()
+	if err != nil {
+		return "", err
+	}
+
+	return cfg.FormatDSN(), nil
 }
 
-// Connect establishes a connection to the MySQL database using environment
-// vars.
-func (c *MySQLConnector) Connect() (*sql.DB, error) {
-	if err := godotenv.Load(); err != nil {
-		return nil, fmt.Errorf("error loading .env file: %w", err)
+// mysqlConfig returns the MySQL driver's config for connecting to the
+// database, reading the password from PasswordFile if it is set.
+func (c ConnectionConfig) mysqlConfig() (*mysql.Config, error) {
+	password := c.Password
+
+	if c.PasswordFile != "" {
+		data, err := os.ReadFile(c.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read password file: %w", err)
+		}
+
+		password = strings.TrimRight(string(data), "\r\n")
 	}
 
-	user := os.Getenv("GST_SQL_USER")
-	password := os.Getenv("GST_SQL_PASS")
-	host := os.Getenv("GST_SQL_HOST")
-	port := os.Getenv("GST_SQL_PORT")
-	dbname := os.Getenv("GST_SQL_DB")
+	cfg := mysql.NewConfig()
+	cfg.User = c.User
+	cfg.Passwd = password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(c.Host, c.Port)
+	cfg.DBName = c.Name
+	cfg.ParseTime = true
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
-		user, password, host, port, dbname)
+	return cfg, nil
+}
 
-	db, err := sql.Open("mysql", dsn)
+// freshConnector is a driver.Connector that reads its config's password
+// afresh for every new connection, so that a rotated password file is used
+// without reopening the pool.
+type freshConnector struct {
+	config ConnectionConfig
+}
+
+// Connect opens a new connection to the database.
+func (f freshConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	cfg, err := f.config.mysqlConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	if err = db.Ping(); err != nil {
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return connector.Connect(ctx)
+}
+
+// Driver returns the MySQL driver.
+func (f freshConnector) Driver() driver.Driver {
+	return &mysql.MySQLDriver{}
+}
+
+// MySQLConnector implements DBConnector for MySQL databases. It keeps a pool
+// of connections open from the first Connect until Close.
+type MySQLConnector struct {
+	config *ConnectionConfig
+
+	mu     sync.Mutex
+	db     *sql.DB
+	stop   chan struct{}
+	health error
+}
+
+// NewMySQLConnector returns a MySQLConnector for the database described by
+// config. A zero MySQLConnector instead uses DefaultConnectionConfig()
+// overridden by the environment, as ApplyEnv.
+func NewMySQLConnector(config ConnectionConfig) *MySQLConnector {
+	return &MySQLConnector{config: &config}
+}
+
+// Connect returns the pool of connections to the MySQL database, opening it
+// if this is the first call since creation or Close. Opening it involves a
+// ping, which is abandoned if ctx is done first.
+func (c *MySQLConnector) Connect(ctx context.Context) (*sql.DB, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.db != nil {
+		return c.db, nil
+	}
+
+	config := c.connectionConfig()
+
+	db, err := config.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.db, c.health = db, nil
+
+	if config.HealthInterval > 0 {
+		c.stop = make(chan struct{})
+		go c.checkHealth(db, config.HealthInterval, c.stop)
+	}
+
+	return db, nil
+}
+
+// connectionConfig returns the connection details that c was created with, or
+// the defaults overridden by the environment.
+func (c *MySQLConnector) connectionConfig() ConnectionConfig {
+	if c.config != nil {
+		return *c.config
+	}
+
+	config := DefaultConnectionConfig()
+	config.ApplyEnv()
+
+	return config
+}
+
+// open opens a pool of connections to the database described by c, checking
+// it can connect.
+func (c ConnectionConfig) open(ctx context.Context) (*sql.DB, error) {
+	db := sql.OpenDB(freshConnector{config: c})
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+
 		return nil, err
 	}
 
-	c.db = db
 	return db, nil
 }
 
-// Close closes the database connection.
+// Close closes the pool of connections, if open.
 func (c *MySQLConnector) Close() error {
-	if c.db != nil {
-		return c.db.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.db == nil {
+		return nil
 	}
-	return nil
+
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+
+	db := c.db
+	c.db = nil
+
+	return db.Close()
 }
 
 // GetEmbeddedSQL retrieves the SQL query from the embedded file.
 func GetEmbeddedSQL() string {
-	data, err := sqlFiles.ReadFile("query.sql")
+	return readEmbeddedSQL("query.sql")
+}
+
+// readEmbeddedSQL retrieves the SQL query from the named embedded file.
+func readEmbeddedSQL(name string) string {
+	data, err := sqlFiles.ReadFile(name)
 	if err != nil {
 		// This should never happen as the file is embedded at compile time
 		panic(fmt.Sprintf("failed to read embedded SQL file: %v", err))
@@ -97,63 +296,41 @@ func GetEmbeddedSQL() string {
 	return strings.TrimSpace(string(data))
 }
 
-// parseRows converts SQL rows to a TrackedSampleCollection.
-func parseRows(rows *sql.Rows) (*TrackedSampleCollection, error) {
-	var samples []TrackedSample
+// parseRows converts SQL rows to a TrackedSampleCollection, stopping early if
+// ctx is done.
+func parseRows(ctx context.Context, rows *sql.Rows) (*TrackedSampleCollection, error) {
+	return collect(ctx, func(ctx context.Context, fn SampleHandler) error {
+		return scanRows(ctx, rows, fn)
+	})
+}
+
+// scanRows converts each SQL row to a TrackedSample and passes it to fn,
+// stopping early if ctx is done or fn returns an error. The rows' columns are
+// checked first, returning a *QueryShapeError if they can't make samples.
+func scanRows(ctx context.Context, rows *sql.Rows, fn SampleHandler) error {
+	scanner, err := newRowScanner(rows)
+	if err != nil {
+		return err
+	}
 
 	for rows.Next() {
-		// Use NullString for fields that might be NULL
-		var s TrackedSample
-		var runIDNull, platformNull, pipelineNull, qcPassNull sql.NullString
-
-		err := rows.Scan(
-			&s.StudyID,
-			&s.StudyName,
-			&s.FacultySponsor,
-			&s.Programme,
-			&s.SangerSampleID,
-			&s.SupplierName,
-			&s.ManifestCreated,
-			&s.ManifestUploaded,
-			&s.LabwareReceived,
-			&s.LabwareHumanBarcode,
-			&s.OrderMade,
-			&s.LibraryStart,
-			&s.LibraryComplete,
-			&s.LibraryTime,
-			&runIDNull,
-			&platformNull,
-			&pipelineNull,
-			&s.SequencingRunStart,
-			&s.SequencingQCComplete,
-			&s.SequencingTime,
-			&qcPassNull,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("error scanning row: %w", err)
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
-		// Convert NullString to string (empty string if NULL)
-		s.RunID = getNullableString(runIDNull)
-		s.Platform = getNullableString(platformNull)
-		s.Pipeline = getNullableString(pipelineNull)
-		s.QCPass = getNullableString(qcPassNull)
+		s, err := scanner.scan(rows)
+		if err != nil {
+			return err
+		}
 
-		samples = append(samples, s)
+		if err = fn(s); err != nil {
+			return err
+		}
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+		return fmt.Errorf("error iterating rows: %w", err)
 	}
 
-	return &TrackedSampleCollection{Samples: samples}, nil
-}
-
-// getNullableString returns the string value of a sql.NullString,
-// or empty string if the value is NULL.
-func getNullableString(ns sql.NullString) string {
-	if ns.Valid {
-		return ns.String
-	}
-	return ""
+	return nil
 }
@@ -0,0 +1,224 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+)
+
+// IlluminaLane holds the metrics of a sample in one lane of an Illumina run.
+type IlluminaLane struct {
+	Lane int
+
+	// YieldBases is the number of bases in the whole lane that passed filter.
+	YieldBases *int64 `json:",omitempty"`
+
+	// Q30YieldKb is the sample's yield, in kilobases, of bases with a quality
+	// of at least 30.
+	Q30YieldKb *int64 `json:",omitempty"`
+}
+
+// PacBioWell holds the metrics of a sample in one well of a PacBio run.
+type PacBioWell struct {
+	Well  string
+	Movie string
+
+	// HiFiYieldBases is the number of bases in the well's HiFi reads.
+	HiFiYieldBases *int64 `json:",omitempty"`
+}
+
+// ONTFlowcell identifies where an ONT run sequenced a sample.
+type ONTFlowcell struct {
+	FlowcellID     string
+	DevicePosition string
+}
+
+// DetailQueryProvider is a QueryProvider that can also fill in the
+// platform-specific run metrics of samples, which are too costly to fetch for
+// every sample, for drilling down into a few.
+type DetailQueryProvider interface {
+	QueryProvider
+
+	// AddDetails sets the Illumina, PacBio and ONT fields of each of samples
+	// that its run has metrics for.
+	AddDetails(ctx context.Context, samples []TrackedSample) error
+}
+
+// platformDetail is a row of the detail query: some metrics of a sample's
+// run, with nil for platforms it has no metrics for.
+type platformDetail struct {
+	key      SampleKey
+	illumina *IlluminaLane
+	pacBio   *PacBioWell
+	ont      *ONTFlowcell
+}
+
+// AddDetails runs the embedded detail query for the sample IDs of samples,
+// and adds the resulting metrics to the samples with matching runs.
+func (p *MySQLQueryProvider) AddDetails(ctx context.Context, samples []TrackedSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	db, err := p.connector.Connect(ctx)
+	if err != nil {
+		return fmt.Errorf("database connection error: %w", err)
+	}
+
+	query, args := detailQuery(samples)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("detail query execution error: %w", preferContextError(ctx, err))
+	}
+	defer rows.Close()
+
+	details, err := scanDetails(rows)
+	if err != nil {
+		return err
+	}
+
+	attachDetails(samples, details)
+
+	return nil
+}
+
+// detailQuery returns the embedded detail query restricted to the sample IDs
+// of samples, and the values for its placeholders. The query has a SELECT for
+// each platform, so that each row has its own platform's run ID, and each is
+// restricted to the samples.
+func detailQuery(samples []TrackedSample) (string, []any) {
+	ids := make([]string, 0, len(samples))
+
+	for i := range samples {
+		if !slices.Contains(ids, samples[i].SangerSampleID) {
+			ids = append(ids, samples[i].SangerSampleID)
+		}
+	}
+
+	var b conditionBuilder
+
+	b.in("tps.sanger_sample_id", ids)
+
+	return b.buildEach(readEmbeddedSQL("detail.sql"))
+}
+
+// scanDetails reads every row of the detail query.
+func scanDetails(rows *sql.Rows) ([]platformDetail, error) {
+	var details []platformDetail
+
+	for rows.Next() {
+		d, err := scanDetail(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		details = append(details, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating detail rows: %w", err)
+	}
+
+	return details, nil
+}
+
+// scanDetail reads the current row of the detail query. A platform's metrics
+// are only set if the column that identifies them, such as the lane, is not
+// NULL.
+func scanDetail(rows *sql.Rows) (platformDetail, error) {
+	var (
+		d                               platformDetail
+		lane, yield, q30, hifi          sql.NullInt64
+		well, movie, flowcell, position sql.NullString
+	)
+
+	err := rows.Scan(nullableString{&d.key.SangerSampleID}, nullableString{&d.key.RunID},
+		&lane, &yield, &q30, &well, &movie, &hifi, &flowcell, &position)
+	if err != nil {
+		return d, fmt.Errorf("error scanning detail row: %w", err)
+	}
+
+	if lane.Valid {
+		d.illumina = &IlluminaLane{Lane: int(lane.Int64), YieldBases: nullInt64(yield), Q30YieldKb: nullInt64(q30)}
+	}
+
+	if well.Valid {
+		d.pacBio = &PacBioWell{Well: well.String, Movie: movie.String, HiFiYieldBases: nullInt64(hifi)}
+	}
+
+	if flowcell.Valid {
+		d.ont = &ONTFlowcell{FlowcellID: flowcell.String, DevicePosition: position.String}
+	}
+
+	return d, nil
+}
+
+// nullInt64 returns a pointer to n's value, or nil if it is NULL.
+func nullInt64(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+
+	return &n.Int64
+}
+
+// attachDetails adds each of details to the samples with the same key.
+func attachDetails(samples []TrackedSample, details []platformDetail) {
+	byKey := make(map[SampleKey][]int, len(samples))
+
+	for i := range samples {
+		byKey[samples[i].Key()] = append(byKey[samples[i].Key()], i)
+	}
+
+	for _, d := range details {
+		for _, i := range byKey[d.key] {
+			samples[i].addDetail(d)
+		}
+	}
+}
+
+// addDetail adds d's metrics to this sample, unless it already has those of
+// the same lane, well or flowcell.
+func (s *TrackedSample) addDetail(d platformDetail) {
+	if d.illumina != nil && !slices.ContainsFunc(s.Illumina,
+		func(l IlluminaLane) bool { return l.Lane == d.illumina.Lane }) {
+		s.Illumina = append(s.Illumina, *d.illumina)
+	}
+
+	if d.pacBio != nil && !slices.ContainsFunc(s.PacBio,
+		func(w PacBioWell) bool { return w.Well == d.pacBio.Well }) {
+		s.PacBio = append(s.PacBio, *d.pacBio)
+	}
+
+	if d.ont != nil && !slices.ContainsFunc(s.ONT,
+		func(f ONTFlowcell) bool { return f.FlowcellID == d.ont.FlowcellID }) {
+		s.ONT = append(s.ONT, *d.ont)
+	}
+}
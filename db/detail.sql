@@ -0,0 +1,55 @@
+SELECT DISTINCT
+tps.sanger_sample_id,
+pm.id_run as RunID,
+pm.position as lane,
+rlm.pf_bases as lane_yield_bases,
+pm.q30_yield_kb_forward_read + COALESCE(pm.q30_yield_kb_reverse_read, 0) as q30_yield_kb,
+NULL as well_label,
+NULL as movie_name,
+NULL as hifi_read_bases,
+NULL as flowcell_id,
+NULL as device_position
+
+FROM mlwh_reporting.seq_ops_tracking_per_sample tps
+JOIN sample sa on sa.id_sample_lims = tps.id_sample_lims
+JOIN iseq_flowcell fc on fc.id_sample_tmp = sa.id_sample_tmp
+JOIN iseq_product_metrics pm on pm.id_iseq_flowcell_tmp = fc.id_iseq_flowcell_tmp
+LEFT JOIN iseq_run_lane_metrics rlm on rlm.id_run = pm.id_run and rlm.position = pm.position
+
+/* conditions */
+
+UNION ALL
+
+SELECT DISTINCT
+tps.sanger_sample_id,
+pbr.id_pac_bio_run_lims as RunID,
+NULL, NULL, NULL,
+pbw.well_label,
+pbw.movie_name,
+pbw.hifi_read_bases,
+NULL, NULL
+
+FROM mlwh_reporting.seq_ops_tracking_per_sample tps
+JOIN sample sa on sa.id_sample_lims = tps.id_sample_lims
+JOIN pac_bio_run pbr on pbr.id_sample_tmp = sa.id_sample_tmp
+JOIN pac_bio_product_metrics pbm on pbm.id_pac_bio_tmp = pbr.id_pac_bio_tmp
+JOIN pac_bio_run_well_metrics pbw on pbw.id_pac_bio_product = pbm.id_pac_bio_product
+
+/* conditions */
+
+UNION ALL
+
+SELECT DISTINCT
+tps.sanger_sample_id,
+ofc.experiment_name as RunID,
+NULL, NULL, NULL, NULL, NULL, NULL,
+ofc.flowcell_id,
+ofc.instrument_slot
+
+FROM mlwh_reporting.seq_ops_tracking_per_sample tps
+JOIN sample sa on sa.id_sample_lims = tps.id_sample_lims
+JOIN oseq_flowcell ofc on ofc.id_sample_tmp = sa.id_sample_tmp
+
+/* conditions */
+
+ORDER BY sanger_sample_id, RunID, lane, well_label, flowcell_id;
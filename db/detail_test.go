@@ -0,0 +1,104 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+)
+
+var detailColumns = []string{
+	"sanger_sample_id", "RunID", "lane", "lane_yield_bases", "q30_yield_kb",
+	"well_label", "movie_name", "hifi_read_bases", "flowcell_id", "device_position",
+}
+
+func TestPlatformDetails(t *testing.T) {
+	Convey("Given a MySQL provider with a sqlmock database", t, func() {
+		mockDB, mock, err := sqlmock.New()
+		So(err, ShouldBeNil)
+		defer mockDB.Close()
+
+		provider, err := db.New(db.WithMySQLConnector(&mockConnector{mockDB: mockDB}))
+		So(err, ShouldBeNil)
+
+		details, ok := provider.(db.DetailQueryProvider)
+		So(ok, ShouldBeTrue)
+
+		samples := []db.TrackedSample{
+			{SangerSampleID: "SANG1", RunID: "1001"},
+			{SangerSampleID: "SANG1", RunID: "1002"},
+			{SangerSampleID: "SANG2", RunID: "RUN-PB"},
+			{SangerSampleID: "SANG3", RunID: "EXP-ONT"},
+		}
+
+		Convey("Metrics are added to the samples of the matching runs", func() {
+			mock.ExpectQuery(`(?s)pm.id_run as RunID.*WHERE tps.sanger_sample_id IN \(\?,\?,\?\).*UNION ALL.*`+
+				`pbr.id_pac_bio_run_lims as RunID.*UNION ALL.*ofc.experiment_name as RunID`).
+				WithArgs("SANG1", "SANG2", "SANG3", "SANG1", "SANG2", "SANG3", "SANG1", "SANG2", "SANG3").
+				WillReturnRows(sqlmock.NewRows(detailColumns).
+					AddRow("SANG1", int64(1001), int64(1), int64(5000), int64(300), nil, nil, nil, nil, nil).
+					AddRow("SANG1", int64(1001), int64(2), nil, nil, nil, nil, nil, nil, nil).
+					AddRow("SANG1", int64(1001), int64(2), nil, nil, nil, nil, nil, nil, nil).
+					AddRow("SANG2", "RUN-PB", nil, nil, nil, "A01", "m84001", int64(9000), nil, nil).
+					AddRow("SANG3", "EXP-ONT", nil, nil, nil, nil, nil, nil, "PAO12345", "1A"))
+
+			So(details.AddDetails(context.Background(), samples), ShouldBeNil)
+			So(mock.ExpectationsWereMet(), ShouldBeNil)
+
+			So(len(samples[0].Illumina), ShouldEqual, 2)
+			So(samples[0].Illumina[0].Lane, ShouldEqual, 1)
+			So(*samples[0].Illumina[0].YieldBases, ShouldEqual, 5000)
+			So(*samples[0].Illumina[0].Q30YieldKb, ShouldEqual, 300)
+			So(samples[0].Illumina[1].Q30YieldKb, ShouldBeNil)
+			So(samples[0].PacBio, ShouldBeNil)
+
+			So(samples[1].Illumina, ShouldBeNil)
+
+			So(samples[2].PacBio, ShouldResemble, []db.PacBioWell{
+				{Well: "A01", Movie: "m84001", HiFiYieldBases: samples[2].PacBio[0].HiFiYieldBases},
+			})
+			So(*samples[2].PacBio[0].HiFiYieldBases, ShouldEqual, 9000)
+
+			So(samples[3].ONT, ShouldResemble, []db.ONTFlowcell{{FlowcellID: "PAO12345", DevicePosition: "1A"}})
+		})
+
+		Convey("Samples without metrics are left out of their JSON", func() {
+			encoded, err := json.Marshal(samples[0])
+			So(err, ShouldBeNil)
+			So(string(encoded), ShouldNotContainSubstring, "Illumina")
+		})
+
+		Convey("No query is run for no samples", func() {
+			So(details.AddDetails(context.Background(), nil), ShouldBeNil)
+			So(mock.ExpectationsWereMet(), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,228 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"fmt"
+	"io"
+)
+
+// FieldChange describes a field that has a different value in a newer copy of
+// a sample.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// SampleChange describes how a sample present in two collections differs.
+type SampleChange struct {
+	Key SampleKey `json:"key"`
+
+	// OldMilestone and NewMilestone are the furthest milestones reached in
+	// each collection, or empty if none were.
+	OldMilestone string `json:"oldMilestone"`
+	NewMilestone string `json:"newMilestone"`
+
+	// Advanced is true if the sample reached a later milestone.
+	Advanced bool `json:"advanced"`
+
+	// Fields are the fields whose values differ.
+	Fields []FieldChange `json:"fields"`
+}
+
+// Diff describes the differences between an older and a newer collection of
+// the same samples, matched by SampleKey.
+type Diff struct {
+	Added   []TrackedSample `json:"added"`
+	Removed []TrackedSample `json:"removed"`
+	Changed []SampleChange  `json:"changed"`
+}
+
+// Compare returns the differences between old and new. Where a key appears
+// more than once in a collection, only its first sample is compared.
+func Compare(old, new *TrackedSampleCollection) *Diff {
+	oldByKey, oldKeys := indexByKey(old)
+	newByKey, newKeys := indexByKey(new)
+	d := &Diff{}
+
+	for _, key := range oldKeys {
+		n, ok := newByKey[key]
+		if !ok {
+			d.Removed = append(d.Removed, *oldByKey[key])
+
+			continue
+		}
+
+		if change, differs := compareSamples(oldByKey[key], n); differs {
+			d.Changed = append(d.Changed, change)
+		}
+	}
+
+	for _, key := range newKeys {
+		if _, ok := oldByKey[key]; !ok {
+			d.Added = append(d.Added, *newByKey[key])
+		}
+	}
+
+	return d
+}
+
+// indexByKey returns the first sample of the collection with each key, and the
+// keys in the order they first appear.
+func indexByKey(sc *TrackedSampleCollection) (map[SampleKey]*TrackedSample, []SampleKey) {
+	index := make(map[SampleKey]*TrackedSample, len(sc.Samples))
+	keys := make([]SampleKey, 0, len(sc.Samples))
+
+	for i := range sc.Samples {
+		key := sc.Samples[i].Key()
+		if _, ok := index[key]; !ok {
+			index[key] = &sc.Samples[i]
+			keys = append(keys, key)
+		}
+	}
+
+	return index, keys
+}
+
+// compareSamples returns how new differs from old, and whether it does.
+func compareSamples(old, new *TrackedSample) (SampleChange, bool) {
+	change := SampleChange{Key: old.Key()}
+
+	oldMilestone, oldOK := old.LatestMilestone()
+	newMilestone, newOK := new.LatestMilestone()
+	change.OldMilestone = milestoneName(oldMilestone, oldOK)
+	change.NewMilestone = milestoneName(newMilestone, newOK)
+	change.Advanced = newOK && (!oldOK || newMilestone > oldMilestone)
+
+	oldRecord, newRecord := sampleToRecord(old), sampleToRecord(new)
+
+	for i, field := range tsvHeader {
+		if oldRecord[i] != newRecord[i] {
+			change.Fields = append(change.Fields,
+				FieldChange{Field: field, Old: oldRecord[i], New: newRecord[i]})
+		}
+	}
+
+	return change, len(change.Fields) > 0
+}
+
+// milestoneName returns the name of m, or an empty string if !ok.
+func milestoneName(m Milestone, ok bool) string {
+	if !ok {
+		return ""
+	}
+
+	return m.String()
+}
+
+// WriteText writes a human readable report of the differences to w.
+func (d *Diff) WriteText(w io.Writer) error {
+	tw := &textWriter{w: w}
+
+	tw.printf("Added (%d):\n", len(d.Added))
+	for _, s := range d.Added {
+		tw.printf("  %s\n", describeSample(&s))
+	}
+
+	tw.printf("Removed (%d):\n", len(d.Removed))
+	for _, s := range d.Removed {
+		tw.printf("  %s\n", describeSample(&s))
+	}
+
+	tw.printf("Moved to a later milestone (%d):\n", d.countAdvanced())
+	for _, c := range d.Changed {
+		if c.Advanced {
+			tw.printf("  %s: %s -> %s\n", describeKey(c.Key), orNone(c.OldMilestone), c.NewMilestone)
+		}
+	}
+
+	tw.printf("Changed (%d):\n", len(d.Changed))
+	for _, c := range d.Changed {
+		tw.printChange(c)
+	}
+
+	return tw.err
+}
+
+// countAdvanced returns the number of changed samples that reached a later
+// milestone.
+func (d *Diff) countAdvanced() int {
+	n := 0
+
+	for _, c := range d.Changed {
+		if c.Advanced {
+			n++
+		}
+	}
+
+	return n
+}
+
+// textWriter writes formatted text, remembering the first error.
+type textWriter struct {
+	w   io.Writer
+	err error
+}
+
+// printf writes formatted text unless an earlier write failed.
+func (t *textWriter) printf(format string, args ...any) {
+	if t.err == nil {
+		_, t.err = fmt.Fprintf(t.w, format, args...)
+	}
+}
+
+// printChange writes the changed fields of a sample.
+func (t *textWriter) printChange(c SampleChange) {
+	t.printf("  %s:\n", describeKey(c.Key))
+
+	for _, f := range c.Fields {
+		t.printf("    %s: %q -> %q\n", f.Field, f.Old, f.New)
+	}
+}
+
+// describeSample returns a short description of a sample for reports.
+func describeSample(s *TrackedSample) string {
+	return fmt.Sprintf("%s (%s, %s)", describeKey(s.Key()), s.StudyName, s.FacultySponsor)
+}
+
+// describeKey returns a short description of a sample key for reports.
+func describeKey(k SampleKey) string {
+	if k.RunID == "" {
+		return k.SangerSampleID
+	}
+
+	return k.SangerSampleID + " run " + k.RunID
+}
+
+// orNone returns s, or "none" if it is empty.
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+
+	return s
+}
@@ -0,0 +1,104 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+)
+
+func TestCompare(t *testing.T) {
+	Convey("Given an older and a newer collection of samples", t, func() {
+		day1 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		day2 := day1.AddDate(0, 0, 1)
+
+		old := &db.TrackedSampleCollection{Samples: []db.TrackedSample{
+			{SangerSampleID: "S1", RunID: "R1", ManifestCreated: &day1},
+			{SangerSampleID: "S2", RunID: "R1", ManifestCreated: &day1, StudyName: "Study"},
+			{SangerSampleID: "S3", RunID: "R1", ManifestCreated: &day1, QCPass: "0"},
+			{SangerSampleID: "S4", RunID: "R1", ManifestCreated: &day1},
+		}}
+		new := &db.TrackedSampleCollection{Samples: []db.TrackedSample{
+			{SangerSampleID: "S1", RunID: "R1", ManifestCreated: &day1, LibraryStart: &day2},
+			{SangerSampleID: "S3", RunID: "R1", ManifestCreated: &day1, QCPass: "1"},
+			{SangerSampleID: "S4", RunID: "R1", ManifestCreated: &day1},
+			{SangerSampleID: "S5", RunID: "R2"},
+		}}
+
+		diff := db.Compare(old, new)
+
+		Convey("It should find added and removed samples by sample and run", func() {
+			So(len(diff.Added), ShouldEqual, 1)
+			So(diff.Added[0].SangerSampleID, ShouldEqual, "S5")
+			So(len(diff.Removed), ShouldEqual, 1)
+			So(diff.Removed[0].SangerSampleID, ShouldEqual, "S2")
+		})
+
+		Convey("It should find changed samples and their fields", func() {
+			So(len(diff.Changed), ShouldEqual, 2)
+
+			advanced := diff.Changed[0]
+			So(advanced.Key, ShouldResemble, db.SampleKey{SangerSampleID: "S1", RunID: "R1"})
+			So(advanced.Advanced, ShouldBeTrue)
+			So(advanced.OldMilestone, ShouldEqual, "ManifestCreated")
+			So(advanced.NewMilestone, ShouldEqual, "LibraryStart")
+			So(advanced.Fields, ShouldResemble, []db.FieldChange{
+				{Field: "LibraryStart", Old: "", New: "2025-01-02T00:00:00Z"},
+			})
+
+			qc := diff.Changed[1]
+			So(qc.Key.SangerSampleID, ShouldEqual, "S3")
+			So(qc.Advanced, ShouldBeFalse)
+			So(qc.Fields, ShouldResemble, []db.FieldChange{{Field: "QCPass", Old: "0", New: "1"}})
+		})
+
+		Convey("Its text report should list each kind of difference", func() {
+			var buf bytes.Buffer
+			So(diff.WriteText(&buf), ShouldBeNil)
+
+			text := buf.String()
+			So(text, ShouldContainSubstring, "Added (1):\n  S5 run R2")
+			So(text, ShouldContainSubstring, "Removed (1):\n  S2 run R1 (Study, )")
+			So(text, ShouldContainSubstring, "Moved to a later milestone (1):\n  S1 run R1: ManifestCreated -> LibraryStart")
+			So(text, ShouldContainSubstring, `QCPass: "0" -> "1"`)
+		})
+	})
+
+	Convey("Given identical collections", t, func() {
+		samples := &db.TrackedSampleCollection{Samples: []db.TrackedSample{{SangerSampleID: "S1"}}}
+
+		Convey("There should be no differences", func() {
+			diff := db.Compare(samples, samples)
+			So(diff.Added, ShouldBeEmpty)
+			So(diff.Removed, ShouldBeEmpty)
+			So(diff.Changed, ShouldBeEmpty)
+		})
+	})
+}
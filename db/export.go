@@ -0,0 +1,286 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/wtsi-hgi/gst/output"
+)
+
+// SampleWriter writes samples one at a time in some format.
+type SampleWriter interface {
+	// Write writes the given sample.
+	Write(sample *TrackedSample) error
+
+	// Close writes anything still buffered and ends the output, without
+	// closing the underlying writer.
+	Close() error
+}
+
+// formatFlagNames maps the names users can choose a Format by to the Format.
+var formatFlagNames = map[string]Format{
+	"tsv":     FormatTSV,
+	"csv":     FormatCSV,
+	"json":    FormatJSON,
+	"jsonl":   FormatJSONLines,
+	"xlsx":    FormatXLSX,
+	"parquet": FormatParquet,
+}
+
+// ParseFormat returns the Format with the given name: tsv, csv, json, jsonl,
+// xlsx or parquet.
+func ParseFormat(name string) (Format, error) {
+	format, ok := formatFlagNames[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown format %q, expected tsv, csv, json, jsonl, xlsx or parquet", name)
+	}
+
+	return format, nil
+}
+
+// writerConfig holds the options for NewSampleWriter.
+type writerConfig struct {
+	bom     bool
+	sheets  SheetGroup
+	columns []string
+}
+
+// WriterOption is a function that configures a SampleWriter.
+type WriterOption func(*writerConfig)
+
+// WithBOM makes CSV output start with a UTF-8 byte order mark, so that Excel
+// reads non-ASCII values correctly. It has no effect on other formats.
+func WithBOM() WriterOption {
+	return func(c *writerConfig) {
+		c.bom = true
+	}
+}
+
+// WithColumns makes output have just the columns with the given names (as in
+// OutputColumnNames, ignoring case), in the given order.
+func WithColumns(names ...string) WriterOption {
+	return func(c *writerConfig) {
+		c.columns = names
+	}
+}
+
+// NewSampleWriter returns a SampleWriter that writes samples to w in the
+// given format. Every format has the columns of ToTSV plus the derived Stage
+// and DaysInStage as of the writer's creation, unless WithColumns is used,
+// with times in RFC3339 format.
+// Delimited formats leave missing values empty, while JSON formats have them
+// as null and numbers unquoted, with fields named like TrackedSample's, so
+// that the output can be read back with OpenSampleFile. XLSX has typed date and
+// number cells, and empty cells for missing values; it is only written once
+// the writer is closed. Parquet has UTC millisecond timestamps and nullable
+// 64 bit integers.
+func NewSampleWriter(w io.Writer, format Format, opts ...WriterOption) (SampleWriter, error) {
+	cfg, cols, err := newWriterConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFormatWriter(w, format, cfg, cols)
+}
+
+// newWriterConfig returns the config set by opts, and the columns it selects.
+func newWriterConfig(opts []WriterOption) (*writerConfig, []outputColumn, error) {
+	cfg := &writerConfig{}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	cols, err := selectOutputColumns(cfg.columns)
+
+	return cfg, cols, err
+}
+
+// newFormatWriter returns a SampleWriter for the given format configured by
+// cfg, that writes the given columns to w.
+func newFormatWriter(w io.Writer, format Format, cfg *writerConfig,
+	cols []outputColumn) (SampleWriter, error) {
+	switch format {
+	case FormatTSV:
+		return newDelimitedWriter(w, '\t', cols)
+	case FormatCSV:
+		return newCSVWriter(w, cfg.bom, cols)
+	case FormatJSON:
+		return &jsonWriter{w: w, cols: cols, now: time.Now()}, nil
+	case FormatJSONLines:
+		return &jsonLinesWriter{w: w, cols: cols, now: time.Now()}, nil
+	case FormatXLSX:
+		return &xlsxWriter{w: w, cols: cols, now: time.Now(), sheets: cfg.sheets}, nil
+	case FormatParquet:
+		return newParquetWriter(w, cols)
+	default:
+		return nil, fmt.Errorf("unsupported format %s", format)
+	}
+}
+
+// CreateSampleFile creates the file at path, replacing any existing one, and
+// returns a SampleWriter like NewSampleWriter's that writes to it, and closes
+// it when closed. The file is written like output.Create, so it only appears
+// at path once successfully closed.
+func CreateSampleFile(path string, format Format, opts ...WriterOption) (SampleWriter, error) {
+	// Check the options before creating the file
+	if _, _, err := newWriterConfig(opts); err != nil {
+		return nil, err
+	}
+
+	file, err := output.Create(path, output.WithForce())
+	if err != nil {
+		return nil, err
+	}
+
+	writer, err := NewSampleWriter(file, format, opts...)
+	if err != nil {
+		file.Abort()
+
+		return nil, err
+	}
+
+	return &fileWriter{SampleWriter: writer, file: file}, nil
+}
+
+// fileWriter is a SampleWriter that closes its file when closed.
+type fileWriter struct {
+	SampleWriter
+	file *output.File
+}
+
+// Close ends the output and closes the file, discarding it if the output
+// couldn't be ended.
+func (f *fileWriter) Close() error {
+	if err := f.SampleWriter.Close(); err != nil {
+		f.file.Abort()
+
+		return err
+	}
+
+	return f.file.Close()
+}
+
+// jsonWriter writes samples as the elements of a JSON array.
+type jsonWriter struct {
+	w       io.Writer
+	cols    []outputColumn
+	now     time.Time
+	started bool
+}
+
+// Write writes the sample as the next element of the array, starting the
+// array first if this is the first sample.
+func (j *jsonWriter) Write(sample *TrackedSample) error {
+	b, err := marshalColumns(j.cols, sample, j.now)
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if !j.started {
+		sep = "[\n"
+		j.started = true
+	}
+
+	_, err = io.WriteString(j.w, sep+string(b))
+
+	return err
+}
+
+// Close ends the array, writing an empty one if no samples were written.
+func (j *jsonWriter) Close() error {
+	end := "\n]\n"
+	if !j.started {
+		end = "[]\n"
+	}
+
+	_, err := io.WriteString(j.w, end)
+
+	return err
+}
+
+// marshalColumns encodes the values of cols for the sample as of now as a
+// JSON object, keyed by the columns' keys in order.
+func marshalColumns(cols []outputColumn, sample *TrackedSample, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+
+	for i, col := range cols {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(col.key)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := json.Marshal(col.value(sample, now))
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// jsonLinesWriter writes samples as JSON objects, one per line.
+type jsonLinesWriter struct {
+	w    io.Writer
+	cols []outputColumn
+	now  time.Time
+}
+
+// Write writes the sample as a line of JSON.
+func (j *jsonLinesWriter) Write(sample *TrackedSample) error {
+	b, err := marshalColumns(j.cols, sample, j.now)
+	if err != nil {
+		return err
+	}
+
+	_, err = j.w.Write(append(b, '\n'))
+
+	return err
+}
+
+// Close does nothing, since every line is written in full by Write.
+func (j *jsonLinesWriter) Close() error {
+	return nil
+}
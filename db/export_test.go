@@ -0,0 +1,140 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+)
+
+func TestSampleWriter(t *testing.T) {
+	Convey("ParseFormat accepts the export format names", t, func() {
+		format, err := db.ParseFormat("JSONL")
+		So(err, ShouldBeNil)
+		So(format, ShouldEqual, db.FormatJSONLines)
+
+		_, err = db.ParseFormat("xml")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Given samples with missing values", t, func() {
+		ordered := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		five := 5
+		samples := []db.TrackedSample{
+			{SangerSampleID: "S1", RunID: "R1", OrderMade: &ordered, LibraryTime: &five},
+			{SangerSampleID: "S2", RunID: "R2", Platform: "PacBio, Revio"},
+		}
+
+		write := func(format db.Format, opts ...db.WriterOption) []byte {
+			var buf bytes.Buffer
+
+			w, err := db.NewSampleWriter(&buf, format, opts...)
+			So(err, ShouldBeNil)
+
+			for i := range samples {
+				So(w.Write(&samples[i]), ShouldBeNil)
+			}
+
+			So(w.Close(), ShouldBeNil)
+
+			return buf.Bytes()
+		}
+
+		for _, format := range []db.Format{db.FormatTSV, db.FormatCSV, db.FormatJSON, db.FormatJSONLines} {
+			Convey("The "+format.String()+" output can be read back", func() {
+				path := filepath.Join(t.TempDir(), "samples.out")
+				So(os.WriteFile(path, write(format, db.WithBOM()), 0600), ShouldBeNil)
+
+				f, err := db.OpenSampleFile(path, true)
+				So(err, ShouldBeNil)
+				defer f.Close()
+				So(f.Format, ShouldEqual, format)
+
+				got, err := f.Read()
+				So(err, ShouldBeNil)
+				So(got.OrderMade.Equal(ordered), ShouldBeTrue)
+				So(*got.LibraryTime, ShouldEqual, 5)
+
+				got, err = f.Read()
+				So(err, ShouldBeNil)
+				So(got.OrderMade, ShouldBeNil)
+				So(got.Platform, ShouldEqual, "PacBio, Revio")
+			})
+		}
+
+		Convey("CSV output only has a byte order mark if asked for", func() {
+			So(string(write(db.FormatCSV)), ShouldStartWith, "StudyID,")
+			So(string(write(db.FormatCSV, db.WithBOM())), ShouldStartWith, "\ufeffStudyID,")
+		})
+
+		Convey("JSON output has typed values and nulls", func() {
+			var got []map[string]any
+			So(json.Unmarshal(write(db.FormatJSON), &got), ShouldBeNil)
+			So(len(got), ShouldEqual, 2)
+			So(got[0]["LibraryTime"], ShouldEqual, 5)
+			So(got[0]["OrderMade"], ShouldEqual, "2025-01-02T03:04:05Z")
+			So(got[0]["Stage"], ShouldEqual, db.StageOrdered.String())
+			So(got[1]["OrderMade"], ShouldBeNil)
+			So(got[1]["LibraryTime"], ShouldBeNil)
+
+			lines := strings.Split(strings.TrimSpace(string(write(db.FormatJSONLines))), "\n")
+			So(len(lines), ShouldEqual, 2)
+		})
+
+		Convey("Output can have chosen columns in a chosen order", func() {
+			cols := db.WithColumns("RunID", "plate/tube", "LibraryTime", "Stage")
+			So(string(write(db.FormatTSV, cols)), ShouldEqual,
+				"RunID\tPlate/Tube\tLibraryTime\tStage\nR1\t\t5\tordered\nR2\t\t\tunknown\n")
+			So(string(write(db.FormatJSONLines, cols)), ShouldStartWith,
+				`{"RunID":"R1","LabwareHumanBarcode":"","LibraryTime":5,"Stage":"ordered"}`+"\n")
+
+			_, err := db.NewSampleWriter(&bytes.Buffer{}, db.FormatTSV, db.WithColumns("Nonsense"))
+			So(err, ShouldNotBeNil)
+
+			_, err = db.NewSampleWriter(&bytes.Buffer{}, db.FormatTSV, db.WithColumns("RunID", "runid"))
+			So(err, ShouldNotBeNil)
+
+			So(db.OutputColumnNames(), ShouldHaveLength, 23)
+		})
+
+		Convey("An empty JSON export is an empty array", func() {
+			var buf bytes.Buffer
+
+			w, err := db.NewSampleWriter(&buf, db.FormatJSON)
+			So(err, ShouldBeNil)
+			So(w.Close(), ShouldBeNil)
+			So(buf.String(), ShouldEqual, "[]\n")
+		})
+	})
+}
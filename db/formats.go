@@ -0,0 +1,289 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Format is a file format that samples can be read from or written to.
+type Format int
+
+// The formats samples can be read from or written to. FormatXLSX and
+// FormatParquet can only be written.
+const (
+	FormatTSV Format = iota
+	FormatCSV
+	FormatJSON
+	FormatJSONLines
+	FormatXLSX
+	FormatParquet
+)
+
+// formatNames are the names of each Format.
+var formatNames = [...]string{"TSV", "CSV", "JSON", "JSON Lines", "XLSX", "Parquet"}
+
+// formatFileExtensions are the usual file extension of each Format.
+var formatFileExtensions = [...]string{".tsv", ".csv", ".json", ".jsonl", ".xlsx", ".parquet"}
+
+// String returns the name of the format.
+func (f Format) String() string {
+	return formatNames[f]
+}
+
+// Extension returns the usual file extension for the format, including the
+// leading dot.
+func (f Format) Extension() string {
+	return formatFileExtensions[f]
+}
+
+// formatExtensions maps file extensions to the Format they indicate.
+var formatExtensions = map[string]Format{
+	".tsv":    FormatTSV,
+	".txt":    FormatTSV,
+	".csv":    FormatCSV,
+	".json":   FormatJSON,
+	".jsonl":  FormatJSONLines,
+	".ndjson": FormatJSONLines,
+}
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// zstdMagic starts every zstd frame.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// sniffSize is how much of a file is looked at to detect its format.
+const sniffSize = 4096
+
+// SampleReader reads samples one at a time, returning io.EOF after the last.
+type SampleReader interface {
+	Read() (*TrackedSample, error)
+}
+
+// SampleFile is an open file of samples.
+type SampleFile struct {
+	SampleReader
+
+	// Format is the detected format of the file.
+	Format Format
+
+	// Compressed is true if the file was gzip or zstd compressed.
+	Compressed bool
+
+	closers []io.Closer
+}
+
+// OpenSampleFile opens the file at path for reading samples, detecting its
+// format from its extension, or its content if the extension isn't known, and
+// decompressing it if it is gzip or zstd compressed. The file must be closed after use.
+// Delimited files are read like NewTSVReader, with the given strictness;
+// JSON files (an array of samples) and JSON Lines files (a sample per line)
+// have a field per sample field, named like TrackedSample's, as written by the
+// snapshot package.
+func OpenSampleFile(path string, strict bool) (*SampleFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sf := &SampleFile{closers: []io.Closer{file}}
+
+	reader, name, err := sf.decompress(bufio.NewReaderSize(file, sniffSize), strings.ToLower(path))
+	if err == nil {
+		sf.Format = detectFormat(name, reader)
+		sf.SampleReader, err = newSampleReader(reader, sf.Format, strict)
+	}
+
+	if err != nil {
+		sf.Close()
+
+		return nil, err
+	}
+
+	return sf, nil
+}
+
+// decompress returns a reader of r's decompressed content and name without
+// its .gz or .zst extension if r is compressed, or r and name unchanged if
+// not.
+func (sf *SampleFile) decompress(r *bufio.Reader, name string) (*bufio.Reader, string, error) {
+	magic, _ := r.Peek(len(zstdMagic))
+
+	var (
+		decompressed io.ReadCloser
+		err          error
+	)
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		decompressed, err = gzip.NewReader(r)
+		name = strings.TrimSuffix(name, ".gz")
+	case bytes.Equal(magic, zstdMagic):
+		decompressed, err = newZstdReader(r)
+		name = strings.TrimSuffix(name, ".zst")
+	default:
+		return r, name, nil
+	}
+
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decompress: %w", err)
+	}
+
+	sf.Compressed = true
+	sf.closers = append(sf.closers, decompressed)
+
+	return bufio.NewReaderSize(decompressed, sniffSize), name, nil
+}
+
+// newZstdReader returns a reader of r's zstd decompressed content.
+func newZstdReader(r io.Reader) (io.ReadCloser, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return zr.IOReadCloser(), nil
+}
+
+// detectFormat returns the format of r's content, from a file called name.
+// JSON and JSON Lines are recognised by their content whatever the extension,
+// since an export's format need not match its file name. Otherwise the
+// extension says whether it is TSV or CSV, or else the content does.
+func detectFormat(name string, r *bufio.Reader) Format {
+	sniffed := sniffFormat(r)
+	if sniffed.isJSON() {
+		return sniffed
+	}
+
+	if format, ok := formatExtensions[filepath.Ext(name)]; ok && !format.isJSON() {
+		return format
+	}
+
+	return sniffed
+}
+
+// sniffFormat returns the format that the start of r's content looks like.
+func sniffFormat(r *bufio.Reader) Format {
+	start, _ := r.Peek(sniffSize)
+	trimmed := bytes.TrimLeft(start, " \t\r\n\ufeff")
+
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		return FormatJSON
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return FormatJSONLines
+	}
+
+	header, _, _ := bytes.Cut(start, []byte("\n"))
+	if !bytes.Contains(header, []byte("\t")) && bytes.Contains(header, []byte(",")) {
+		return FormatCSV
+	}
+
+	return FormatTSV
+}
+
+// isJSON returns true for JSON and JSON Lines.
+func (f Format) isJSON() bool {
+	return f == FormatJSON || f == FormatJSONLines
+}
+
+// newSampleReader returns a reader of samples in the given format from r.
+func newSampleReader(r io.Reader, format Format, strict bool) (SampleReader, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVReader(r, strict)
+	case FormatJSON, FormatJSONLines:
+		return newJSONReader(r, format == FormatJSON)
+	default:
+		return NewTSVReader(r, strict)
+	}
+}
+
+// Close closes the file.
+func (sf *SampleFile) Close() error {
+	var errs []error
+
+	for i := len(sf.closers) - 1; i >= 0; i-- {
+		errs = append(errs, sf.closers[i].Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// jsonReader reads samples from a JSON array or JSON Lines.
+type jsonReader struct {
+	dec   *json.Decoder
+	array bool
+	n     int
+}
+
+// newJSONReader returns a jsonReader of r, which holds an array of samples if
+// array is true, or a sample per line otherwise.
+func newJSONReader(r io.Reader, array bool) (*jsonReader, error) {
+	dec := json.NewDecoder(r)
+
+	if array {
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+			return nil, fmt.Errorf("expected a JSON array of samples")
+		}
+	}
+
+	return &jsonReader{dec: dec, array: array}, nil
+}
+
+// Read returns the next sample, or io.EOF after the last one.
+func (j *jsonReader) Read() (*TrackedSample, error) {
+	if j.array && !j.dec.More() {
+		return nil, io.EOF
+	}
+
+	sample := &TrackedSample{}
+
+	err := j.dec.Decode(sample)
+	if errors.Is(err, io.EOF) && !j.array {
+		return nil, io.EOF
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("sample %d: %w", j.n+1, err)
+	}
+
+	j.n++
+
+	return sample, nil
+}
@@ -0,0 +1,178 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+	"github.com/wtsi-hgi/gst/snapshot"
+)
+
+// gzipped returns data gzip compressed.
+func gzipped(data []byte) []byte {
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	gz.Write(data)
+	gz.Close()
+
+	return buf.Bytes()
+}
+
+// zstdCompressed returns data zstd compressed.
+func zstdCompressed(data []byte) []byte {
+	enc, err := zstd.NewWriter(nil)
+	So(err, ShouldBeNil)
+
+	defer enc.Close()
+
+	return enc.EncodeAll(data, nil)
+}
+
+func TestSampleFileFormats(t *testing.T) {
+	Convey("Given the same samples in every format", t, func() {
+		dir := t.TempDir()
+		ordered := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		five := 5
+		samples := []db.TrackedSample{
+			{SangerSampleID: "S1", RunID: "R1", OrderMade: &ordered, LibraryTime: &five},
+			{SangerSampleID: "S2", RunID: "R2", Platform: "PacBio, Revio"},
+		}
+
+		tsvPath := filepath.Join(dir, "samples.tsv")
+		So((&db.TrackedSampleCollection{Samples: samples}).ToTSV(tsvPath), ShouldBeNil)
+		tsv, err := os.ReadFile(tsvPath)
+		So(err, ShouldBeNil)
+
+		csv := []byte("\ufeffSangerSampleID,RunID,OrderMade,LibraryTime,Platform\n" +
+			"S1,R1,2025-01-02T03:04:05Z,5,\nS2,R2,,,\"PacBio, Revio\"\n")
+
+		array, err := json.Marshal(samples)
+		So(err, ShouldBeNil)
+
+		var lines []byte
+		for _, s := range samples {
+			line, errm := json.Marshal(s)
+			So(errm, ShouldBeNil)
+			lines = append(append(lines, line...), '\n')
+		}
+
+		contents := map[db.Format][]byte{
+			db.FormatTSV: tsv, db.FormatCSV: csv, db.FormatJSON: array, db.FormatJSONLines: lines,
+		}
+		extensions := map[db.Format]string{
+			db.FormatTSV: ".tsv", db.FormatCSV: ".csv", db.FormatJSON: ".json", db.FormatJSONLines: ".jsonl",
+		}
+
+		check := func(path string, format db.Format, compressed bool) {
+			f, err := db.OpenSampleFile(path, true)
+			So(err, ShouldBeNil)
+			So(f.Format, ShouldEqual, format)
+			So(f.Compressed, ShouldEqual, compressed)
+			So(f.Close(), ShouldBeNil)
+
+			provider, err := db.New(db.WithMockData(path), db.WithStrictParsing())
+			So(err, ShouldBeNil)
+
+			got, err := provider.Execute()
+			So(err, ShouldBeNil)
+			So(len(got.Samples), ShouldEqual, 2)
+			So(got.Samples[0].OrderMade.Equal(ordered), ShouldBeTrue)
+			So(*got.Samples[0].LibraryTime, ShouldEqual, 5)
+			So(got.Samples[1].Platform, ShouldEqual, "PacBio, Revio")
+		}
+
+		for format, data := range contents {
+			Convey("The "+format.String()+" file should be read by extension, sniffing, and compressed", func() {
+				path := filepath.Join(dir, "in"+extensions[format])
+				So(os.WriteFile(path, data, 0600), ShouldBeNil)
+				check(path, format, false)
+
+				path = filepath.Join(dir, "in.dat")
+				So(os.WriteFile(path, data, 0600), ShouldBeNil)
+				check(path, format, false)
+
+				path = filepath.Join(dir, "in"+extensions[format]+".gz")
+				So(os.WriteFile(path, gzipped(data), 0600), ShouldBeNil)
+				check(path, format, true)
+
+				path = filepath.Join(dir, "in.gz")
+				So(os.WriteFile(path, gzipped(data), 0600), ShouldBeNil)
+				check(path, format, true)
+
+				path = filepath.Join(dir, "in"+extensions[format]+".zst")
+				So(os.WriteFile(path, zstdCompressed(data), 0600), ShouldBeNil)
+				check(path, format, true)
+			})
+		}
+
+		Convey("A file whose extension doesn't match its content should be read by its content", func() {
+			for name, format := range map[string]db.Format{
+				"json.tsv": db.FormatJSON, "jsonl.json": db.FormatJSONLines, "tsv.jsonl": db.FormatTSV,
+			} {
+				path := filepath.Join(dir, name)
+				So(os.WriteFile(path, contents[format], 0600), ShouldBeNil)
+				check(path, format, false)
+			}
+		})
+
+		Convey("A snapshot should be readable as a sample file", func() {
+			store, err := snapshot.Open(dir)
+			So(err, ShouldBeNil)
+
+			_, err = store.Save(&db.TrackedSampleCollection{Samples: samples}, "fp")
+			So(err, ShouldBeNil)
+
+			paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl.gz"))
+			So(err, ShouldBeNil)
+			So(len(paths), ShouldEqual, 1)
+
+			check(paths[0], db.FormatJSONLines, true)
+		})
+
+		Convey("Malformed JSON should be reported with the sample it was in", func() {
+			path := filepath.Join(dir, "bad.jsonl")
+			So(os.WriteFile(path, []byte(`{"SangerSampleID":"S1"}`+"\n"+`{"LibraryTime":"x"}`+"\n"), 0600),
+				ShouldBeNil)
+
+			provider, err := db.New(db.WithMockData(path))
+			So(err, ShouldBeNil)
+
+			_, err = provider.Execute()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "sample 2")
+		})
+	})
+}
@@ -0,0 +1,97 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// HealthChecker is a QueryProvider that keeps checking that its database is
+// reachable between queries.
+type HealthChecker interface {
+	// Health returns the error of the latest check, or nil if it succeeded or
+	// there hasn't been one yet.
+	Health() error
+}
+
+// Health returns the error of the latest health ping of the provider's
+// database, or nil if it succeeded, there hasn't been one yet, or its
+// connector doesn't check.
+func (p *MySQLQueryProvider) Health() error {
+	if checker, ok := p.connector.(HealthChecker); ok {
+		return checker.Health()
+	}
+
+	return nil
+}
+
+// checkHealth pings db every interval until stop is closed, recording the
+// result for Health.
+func (c *MySQLConnector) checkHealth(db *sql.DB, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err := db.PingContext(ctx)
+
+			cancel()
+			c.recordHealth(err)
+		}
+	}
+}
+
+// recordHealth records the result of a health ping, logging when the
+// database becomes unreachable or reachable again.
+func (c *MySQLConnector) recordHealth(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case err != nil && c.health == nil:
+		log.Printf("database health check failed: %v", err)
+	case err == nil && c.health != nil:
+		log.Printf("database health check recovered")
+	}
+
+	c.health = err
+}
+
+// Health returns the error of the latest health ping, or nil if it succeeded
+// or there hasn't been one yet.
+func (c *MySQLConnector) Health() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.health
+}
@@ -0,0 +1,143 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Milestone identifies one of the timestamp fields of a TrackedSample.
+type Milestone int
+
+// The milestones a sample passes through, in the order they normally happen.
+const (
+	MilestoneManifestCreated Milestone = iota
+	MilestoneManifestUploaded
+	MilestoneLabwareReceived
+	MilestoneOrderMade
+	MilestoneLibraryStart
+	MilestoneLibraryComplete
+	MilestoneSequencingRunStart
+	MilestoneSequencingQCComplete
+)
+
+// milestoneNames are the names of each Milestone, matching the TSV headers.
+var milestoneNames = [...]string{
+	"ManifestCreated",
+	"ManifestUploaded",
+	"LabwareReceived",
+	"OrderMade",
+	"LibraryStart",
+	"LibraryComplete",
+	"SequencingRunStart",
+	"SequencingQCComplete",
+}
+
+// milestoneColumns are the query columns holding each Milestone.
+var milestoneColumns = [...]string{
+	"tps.manifest_created",
+	"tps.manifest_uploaded",
+	"tps.labware_received",
+	"tps.order_made",
+	"tps.library_start",
+	"tps.library_complete",
+	"tps.sequencing_run_start",
+	"tps.sequencing_qc_complete",
+}
+
+// Milestones returns every Milestone in order.
+func Milestones() []Milestone {
+	ms := make([]Milestone, len(milestoneNames))
+	for i := range ms {
+		ms[i] = Milestone(i)
+	}
+
+	return ms
+}
+
+// ParseMilestone returns the Milestone with the given field name, ignoring
+// case.
+func ParseMilestone(name string) (Milestone, error) {
+	for _, m := range Milestones() {
+		if strings.EqualFold(m.String(), name) {
+			return m, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown milestone %q", name)
+}
+
+// String returns the name of the milestone's field.
+func (m Milestone) String() string {
+	return milestoneNames[m]
+}
+
+// Time returns the time the given sample reached this milestone, or nil if it
+// hasn't.
+func (m Milestone) Time(s *TrackedSample) *time.Time {
+	fields := [...]*time.Time{
+		s.ManifestCreated,
+		s.ManifestUploaded,
+		s.LabwareReceived,
+		s.OrderMade,
+		s.LibraryStart,
+		s.LibraryComplete,
+		s.SequencingRunStart,
+		s.SequencingQCComplete,
+	}
+
+	return fields[m]
+}
+
+// LastChanged returns the latest of the sample's milestone times, or the zero
+// time if it has none.
+func (s *TrackedSample) LastChanged() time.Time {
+	var latest time.Time
+
+	for _, m := range Milestones() {
+		if t := m.Time(s); t != nil && t.After(latest) {
+			latest = *t
+		}
+	}
+
+	return latest
+}
+
+// LatestMilestone returns the furthest milestone the sample has reached, or
+// false if it hasn't reached any.
+func (s *TrackedSample) LatestMilestone() (Milestone, bool) {
+	milestones := Milestones()
+
+	for i := len(milestones) - 1; i >= 0; i-- {
+		if milestones[i].Time(s) != nil {
+			return milestones[i], true
+		}
+	}
+
+	return 0, false
+}
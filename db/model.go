@@ -52,9 +52,74 @@ type TrackedSample struct {
 	SequencingQCComplete *time.Time
 	SequencingTime       *int // DATEDIFF result
 	QCPass               string
+
+	// Illumina, PacBio and ONT hold platform-specific run metrics, which
+	// aren't in the query results but can be added by a
+	// DetailQueryProvider. They are empty when unavailable.
+	Illumina []IlluminaLane `json:",omitempty"`
+	PacBio   []PacBioWell   `json:",omitempty"`
+	ONT      []ONTFlowcell  `json:",omitempty"`
+}
+
+// SampleKey identifies a TrackedSample: a sample may appear once per run.
+type SampleKey struct {
+	SangerSampleID string `json:"sangerSampleId"`
+	RunID          string `json:"runId"`
+}
+
+// Key returns the SampleKey of this sample.
+func (s *TrackedSample) Key() SampleKey {
+	return SampleKey{SangerSampleID: s.SangerSampleID, RunID: s.RunID}
 }
 
 // TrackedSampleCollection represents a collection of query results.
 type TrackedSampleCollection struct {
 	Samples []TrackedSample
 }
+
+// Merge returns a new collection with the samples of delta replacing every
+// sample of this collection with the same SangerSampleID, and any other
+// samples of delta appended. A sample's rows are replaced as a whole because
+// its runs can change: a sample that gains its first run no longer has a row
+// without one. Neither input collection is modified.
+func (sc *TrackedSampleCollection) Merge(delta *TrackedSampleCollection) *TrackedSampleCollection {
+	changed := delta.groupBySample()
+	merged := make([]TrackedSample, 0, len(sc.Samples)+len(delta.Samples))
+	done := make(map[string]bool, len(changed))
+
+	for _, s := range sc.Samples {
+		id := s.SangerSampleID
+
+		replacements, ok := changed[id]
+		if !ok {
+			merged = append(merged, s)
+
+			continue
+		}
+
+		if !done[id] {
+			merged = append(merged, replacements...)
+			done[id] = true
+		}
+	}
+
+	for _, s := range delta.Samples {
+		if !done[s.SangerSampleID] {
+			merged = append(merged, s)
+		}
+	}
+
+	return &TrackedSampleCollection{Samples: merged}
+}
+
+// groupBySample returns the samples of the collection grouped by their
+// SangerSampleID.
+func (sc *TrackedSampleCollection) groupBySample() map[string][]TrackedSample {
+	groups := make(map[string][]TrackedSample)
+
+	for _, s := range sc.Samples {
+		groups[s.SangerSampleID] = append(groups[s.SangerSampleID], s)
+	}
+
+	return groups
+}
@@ -0,0 +1,108 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+)
+
+func TestCollectionMerge(t *testing.T) {
+	Convey("Given a collection of samples with milestones", t, func() {
+		day1 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		day2 := day1.AddDate(0, 0, 1)
+		day3 := day1.AddDate(0, 0, 2)
+
+		collection := &db.TrackedSampleCollection{Samples: []db.TrackedSample{
+			{SangerSampleID: "S1", RunID: "R1", ManifestCreated: &day1},
+			{SangerSampleID: "S2", RunID: "R1", ManifestCreated: &day1, LibraryStart: &day2},
+			{SangerSampleID: "S3"},
+		}}
+
+		Convey("A sample's last change should be its latest milestone", func() {
+			So(collection.Samples[0].LastChanged(), ShouldEqual, day1)
+			So(collection.Samples[1].LastChanged(), ShouldEqual, day2)
+			So(collection.Samples[2].LastChanged().IsZero(), ShouldBeTrue)
+		})
+
+		Convey("When merging a delta with a changed and a new sample", func() {
+			delta := &db.TrackedSampleCollection{Samples: []db.TrackedSample{
+				{SangerSampleID: "S2", RunID: "R1", ManifestCreated: &day1, LibraryComplete: &day3},
+				{SangerSampleID: "S4", RunID: "R2", ManifestCreated: &day3},
+			}}
+
+			merged := collection.Merge(delta)
+
+			Convey("The changed sample should be replaced in place and the new one appended", func() {
+				So(len(merged.Samples), ShouldEqual, 4)
+				So(merged.Samples[0].SangerSampleID, ShouldEqual, "S1")
+				So(merged.Samples[1].SangerSampleID, ShouldEqual, "S2")
+				So(merged.Samples[1].LibraryComplete, ShouldEqual, &day3)
+				So(merged.Samples[1].LibraryStart, ShouldBeNil)
+				So(merged.Samples[2].SangerSampleID, ShouldEqual, "S3")
+				So(merged.Samples[3].SangerSampleID, ShouldEqual, "S4")
+			})
+
+			Convey("The original collection should be unchanged", func() {
+				So(len(collection.Samples), ShouldEqual, 3)
+				So(collection.Samples[1].LibraryComplete, ShouldBeNil)
+			})
+		})
+
+		Convey("When merging a delta in which a sample has gained its first run", func() {
+			delta := &db.TrackedSampleCollection{Samples: []db.TrackedSample{
+				{SangerSampleID: "S3", RunID: "R2", SequencingRunStart: &day3},
+			}}
+
+			merged := collection.Merge(delta)
+
+			Convey("The sample's row without a run should be replaced", func() {
+				So(len(merged.Samples), ShouldEqual, 3)
+				So(merged.Samples[2].SangerSampleID, ShouldEqual, "S3")
+				So(merged.Samples[2].RunID, ShouldEqual, "R2")
+			})
+		})
+
+		Convey("When merging a delta with several rows for one sample", func() {
+			delta := &db.TrackedSampleCollection{Samples: []db.TrackedSample{
+				{SangerSampleID: "S1", RunID: "R1", LabwareHumanBarcode: "A"},
+				{SangerSampleID: "S1", RunID: "R1", LabwareHumanBarcode: "B"},
+			}}
+
+			merged := collection.Merge(delta)
+
+			Convey("All of the delta's rows should replace the old ones", func() {
+				So(len(merged.Samples), ShouldEqual, 4)
+				So(merged.Samples[0].LabwareHumanBarcode, ShouldEqual, "A")
+				So(merged.Samples[1].LabwareHumanBarcode, ShouldEqual, "B")
+				So(merged.Samples[2].SangerSampleID, ShouldEqual, "S2")
+			})
+		})
+	})
+}
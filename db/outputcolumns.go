@@ -0,0 +1,162 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// outputColumn is a column of exported samples: one of the data columns, or
+// one derived from them as of some time.
+type outputColumn struct {
+	name string
+	kind columnKind
+	key  string
+
+	// value returns the column's value for s as of now, as a string,
+	// time.Time or int, or nil if it is unset.
+	value func(s *TrackedSample, now time.Time) any
+}
+
+// outputColumns are every column of exported samples, in their default order:
+// the data columns followed by those in derivedHeader.
+var outputColumns = allOutputColumns()
+
+// allOutputColumns returns the data columns and derived columns.
+func allOutputColumns() []outputColumn {
+	cols := make([]outputColumn, 0, len(columns)+len(derivedHeader))
+
+	for _, col := range columns {
+		value := col.value
+		cols = append(cols, outputColumn{
+			name:  col.name,
+			kind:  col.kind,
+			key:   col.key,
+			value: func(s *TrackedSample, _ time.Time) any { return value(s) },
+		})
+	}
+
+	return append(cols,
+		outputColumn{
+			name: derivedHeader[0], kind: stringKind, key: derivedHeader[0],
+			value: func(s *TrackedSample, _ time.Time) any { return s.Stage().String() },
+		},
+		outputColumn{
+			name: derivedHeader[1], kind: intKind, key: derivedHeader[1],
+			value: func(s *TrackedSample, now time.Time) any {
+				if days := s.DaysInStage(now); days != nil {
+					return *days
+				}
+
+				return nil
+			},
+		})
+}
+
+// OutputColumnNames returns the names of every column of exported samples, in
+// their default order.
+func OutputColumnNames() []string {
+	return outputHeader(outputColumns)
+}
+
+// selectOutputColumns returns the output columns with the given names, in
+// the given order, matching names ignoring case, or every column if there are
+// no names.
+func selectOutputColumns(names []string) ([]outputColumn, error) {
+	if len(names) == 0 {
+		return outputColumns, nil
+	}
+
+	selected := make([]outputColumn, 0, len(names))
+	seen := make(map[int]bool)
+
+	for _, name := range names {
+		i := outputColumnIndex(strings.TrimSpace(name))
+		if i < 0 {
+			return nil, fmt.Errorf("unknown column %q, expected one of %s", name,
+				strings.Join(OutputColumnNames(), ", "))
+		}
+
+		if seen[i] {
+			return nil, fmt.Errorf("column %q is selected more than once", name)
+		}
+
+		seen[i] = true
+		selected = append(selected, outputColumns[i])
+	}
+
+	return selected, nil
+}
+
+// outputColumnIndex returns the index in outputColumns of the column with the
+// given name or key, ignoring case, or -1 if there isn't one.
+func outputColumnIndex(name string) int {
+	for i, col := range outputColumns {
+		if strings.EqualFold(col.name, name) || strings.EqualFold(col.key, name) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// outputHeader returns the names of cols.
+func outputHeader(cols []outputColumn) []string {
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = col.name
+	}
+
+	return names
+}
+
+// outputValues returns the values of cols for s as of now.
+func outputValues(cols []outputColumn, s *TrackedSample, now time.Time) []any {
+	values := make([]any, len(cols))
+	for i, col := range cols {
+		values[i] = col.value(s, now)
+	}
+
+	return values
+}
+
+// formatValue formats an output value for delimited output: times in RFC3339
+// format, and nil as empty.
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case int:
+		return strconv.Itoa(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,211 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// conditionsMarker is the comment in the embedded query that is replaced by a
+// WHERE clause built from QueryParams.
+const conditionsMarker = "/* conditions */"
+
+// defaultManifestYears is how many years of manifests are queried by default.
+const defaultManifestYears = 2
+
+// defaultSponsors are the faculty sponsors whose samples are queried if no
+// others are configured.
+var defaultSponsors = []string{
+	"Carl Anderson",
+	"Emma Davenport",
+	"Matthew Hurles",
+	"Hilary Martin",
+	"Gosia Trynka",
+	"Ben Lehner",
+	"Leopold Parts",
+	"Jussi Taipale",
+}
+
+// QueryParams restrict which samples the query returns. Empty or zero fields
+// apply no restriction.
+type QueryParams struct {
+	// Sponsors are the faculty sponsors to include.
+	Sponsors []string `yaml:"sponsors"`
+
+	// Programmes are the programmes to include.
+	Programmes []string `yaml:"programmes"`
+
+	// StudyIDs are the studies to include.
+	StudyIDs []string `yaml:"study_ids"`
+
+	// ManifestYears includes only samples whose manifest was created within
+	// this many years of the query being run.
+	ManifestYears int `yaml:"manifest_years"`
+
+	// ManifestFrom includes only samples whose manifest was created at or
+	// after this time.
+	ManifestFrom time.Time `yaml:"manifest_from"`
+
+	// ManifestTo includes only samples whose manifest was created before this
+	// time.
+	ManifestTo time.Time `yaml:"manifest_to"`
+
+	// ChangedSince includes only samples that reached a milestone at or after
+	// this time. It is set for incremental fetches rather than configured.
+	ChangedSince time.Time `yaml:"-"`
+}
+
+// DefaultQueryParams returns the params used if none are configured: samples
+// of the HGI faculty sponsors with manifests from the last two years.
+func DefaultQueryParams() QueryParams {
+	return QueryParams{
+		Sponsors:      append([]string(nil), defaultSponsors...),
+		ManifestYears: defaultManifestYears,
+	}
+}
+
+// LoadQueryParams reads params from the YAML file at path. Settings missing
+// from the file keep their DefaultQueryParams values.
+func LoadQueryParams(path string) (QueryParams, error) {
+	params := DefaultQueryParams()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return params, fmt.Errorf("failed to read query params: %w", err)
+	}
+
+	if err = yaml.Unmarshal(data, &params); err != nil {
+		return params, fmt.Errorf("failed to parse query params %s: %w", path, err)
+	}
+
+	return params, nil
+}
+
+// Build returns the given query with its conditions marker replaced by a WHERE
+// clause for these params, along with the values for its placeholders. Any
+// trailing semicolon is removed, since prepared statements don't allow one.
+func (p QueryParams) Build(query string) (string, []any) {
+	var b conditionBuilder
+
+	b.in("tps.faculty_sponsor", p.Sponsors)
+	b.in("tps.programme", p.Programmes)
+	b.in("tps.study_id", p.StudyIDs)
+
+	if p.ManifestYears > 0 {
+		b.add("tps.manifest_created >= DATE_SUB(NOW(), INTERVAL ? YEAR)", p.ManifestYears)
+	}
+
+	if !p.ManifestFrom.IsZero() {
+		b.add("tps.manifest_created >= ?", p.ManifestFrom)
+	}
+
+	if !p.ManifestTo.IsZero() {
+		b.add("tps.manifest_created < ?", p.ManifestTo)
+	}
+
+	if !p.ChangedSince.IsZero() {
+		b.changedSince(p.ChangedSince)
+	}
+
+	return b.build(query)
+}
+
+// conditionBuilder accumulates SQL conditions and their placeholder values.
+type conditionBuilder struct {
+	conditions []string
+	args       []any
+}
+
+// add appends a condition containing a placeholder for each of args.
+func (b *conditionBuilder) add(condition string, args ...any) {
+	b.conditions = append(b.conditions, condition)
+	b.args = append(b.args, args...)
+}
+
+// in appends a condition that column is one of values, if there are any.
+func (b *conditionBuilder) in(column string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(values)), ",")
+	b.add(column+" IN ("+placeholders+")", args...)
+}
+
+// changedSince appends a condition that any milestone column is at or after t.
+func (b *conditionBuilder) changedSince(t time.Time) {
+	checks := make([]string, len(milestoneColumns))
+	args := make([]any, len(milestoneColumns))
+
+	for i, column := range milestoneColumns {
+		checks[i] = column + " >= ?"
+		args[i] = t
+	}
+
+	b.add("("+strings.Join(checks, " OR ")+")", args...)
+}
+
+// build returns query with its conditions marker replaced by the where()
+// clause, and the values for its placeholders.
+func (b *conditionBuilder) build(query string) (string, []any) {
+	query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+
+	return strings.Replace(query, conditionsMarker, b.where(), 1), b.args
+}
+
+// buildEach is like build, but replaces every conditions marker in query, for
+// queries made of several SELECTs that are each restricted the same way.
+func (b *conditionBuilder) buildEach(query string) (string, []any) {
+	query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+	n := strings.Count(query, conditionsMarker)
+
+	args := make([]any, 0, n*len(b.args))
+	for range n {
+		args = append(args, b.args...)
+	}
+
+	return strings.ReplaceAll(query, conditionsMarker, b.where()), args
+}
+
+// where returns a WHERE clause combining the conditions, or nothing if there
+// are none.
+func (b *conditionBuilder) where() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+
+	return "WHERE " + strings.Join(b.conditions, "\nAND ")
+}
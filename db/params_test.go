@@ -0,0 +1,136 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+)
+
+func TestQueryParams(t *testing.T) {
+	query := "SELECT x FROM tps\n/* conditions */\nORDER BY x;"
+
+	Convey("Given the default query params", t, func() {
+		params := db.DefaultQueryParams()
+
+		Convey("Building the query should bind every sponsor and the year window", func() {
+			sql, args := params.Build(query)
+
+			So(sql, ShouldContainSubstring, "WHERE tps.faculty_sponsor IN (?,?,?,?,?,?,?,?)")
+			So(sql, ShouldContainSubstring, "AND tps.manifest_created >= DATE_SUB(NOW(), INTERVAL ? YEAR)")
+			So(sql, ShouldNotContainSubstring, "/* conditions */")
+			So(sql, ShouldEndWith, "ORDER BY x")
+			So(len(args), ShouldEqual, 9)
+			So(args[0], ShouldEqual, "Carl Anderson")
+			So(args[1], ShouldEqual, "Emma Davenport")
+			So(args[8], ShouldEqual, 2)
+		})
+	})
+
+	Convey("Given params with every restriction set", t, func() {
+		from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		params := db.QueryParams{
+			Sponsors:     []string{"S1"},
+			Programmes:   []string{"P1", "P2"},
+			StudyIDs:     []string{"123"},
+			ManifestFrom: from,
+			ManifestTo:   to,
+		}
+
+		Convey("Building the query should bind them in order", func() {
+			sql, args := params.Build(query)
+
+			So(sql, ShouldContainSubstring, "tps.programme IN (?,?)")
+			So(sql, ShouldContainSubstring, "tps.study_id IN (?)")
+			So(sql, ShouldContainSubstring, "tps.manifest_created >= ?")
+			So(sql, ShouldContainSubstring, "tps.manifest_created < ?")
+			So(sql, ShouldNotContainSubstring, "INTERVAL")
+			So(args, ShouldResemble, []any{"S1", "P1", "P2", "123", from, to})
+		})
+	})
+
+	Convey("Given params for an incremental fetch", t, func() {
+		since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		params := db.QueryParams{ChangedSince: since}
+
+		Convey("Building the query should check every milestone against the watermark", func() {
+			sql, args := params.Build(query)
+
+			So(sql, ShouldContainSubstring, "(tps.manifest_created >= ? OR tps.manifest_uploaded >= ?")
+			So(sql, ShouldContainSubstring, "OR tps.sequencing_qc_complete >= ?)")
+			So(len(args), ShouldEqual, len(db.Milestones()))
+			So(args[0], ShouldEqual, since)
+		})
+	})
+
+	Convey("Given empty query params", t, func() {
+		Convey("Building the query should add no WHERE clause", func() {
+			sql, args := db.QueryParams{}.Build(query)
+
+			So(sql, ShouldNotContainSubstring, "WHERE")
+			So(args, ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given the embedded query", t, func() {
+		Convey("It should contain the conditions marker", func() {
+			So(strings.Contains(db.GetEmbeddedSQL(), "/* conditions */"), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a YAML file that sets some params", t, func() {
+		path := filepath.Join(t.TempDir(), "params.yaml")
+		yml := "sponsors:\n  - New PI\nmanifest_from: 2024-06-01\n"
+		So(os.WriteFile(path, []byte(yml), 0600), ShouldBeNil)
+
+		params, err := db.LoadQueryParams(path)
+
+		Convey("The file settings should override the defaults", func() {
+			So(err, ShouldBeNil)
+			So(params.Sponsors, ShouldResemble, []string{"New PI"})
+			So(params.ManifestFrom, ShouldEqual, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+		})
+
+		Convey("Unset params should keep their defaults", func() {
+			So(params.ManifestYears, ShouldEqual, 2)
+		})
+	})
+
+	Convey("Given a missing YAML file", t, func() {
+		_, err := db.LoadQueryParams(filepath.Join(t.TempDir(), "missing.yaml"))
+
+		Convey("Loading it should fail", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,130 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetNodes are the parquet schema nodes for each kind of column. Times are
+// UTC timestamps in milliseconds, and times and whole numbers are nullable.
+var parquetNodes = map[columnKind]parquet.Node{
+	stringKind: parquet.String(),
+	timeKind:   parquet.Optional(parquet.Timestamp(parquet.Millisecond)),
+	intKind:    parquet.Optional(parquet.Leaf(parquet.Int64Type)),
+}
+
+// parquetRowGroupSize is how many rows are buffered into each row group
+// before it is written, bounding the memory used by large exports.
+const parquetRowGroupSize = 100000
+
+// parquetColumns is a parquet schema group of columns in our order, rather
+// than the sorted order of a plain parquet.Group.
+type parquetColumns struct {
+	parquet.Group
+	names []string
+}
+
+// newParquetColumns returns the parquet schema group of the given columns.
+func newParquetColumns(cols []outputColumn) parquetColumns {
+	group := make(parquet.Group, len(cols))
+	names := make([]string, len(cols))
+
+	for i, col := range cols {
+		group[col.name] = parquetNodes[col.kind]
+		names[i] = col.name
+	}
+
+	return parquetColumns{Group: group, names: names}
+}
+
+// Fields returns the group's columns in our order.
+func (p parquetColumns) Fields() []parquet.Field {
+	fields := p.Group.Fields()
+
+	slices.SortFunc(fields, func(a, b parquet.Field) int {
+		return slices.Index(p.names, a.Name()) - slices.Index(p.names, b.Name())
+	})
+
+	return fields
+}
+
+// parquetWriter writes samples as rows of a parquet file.
+type parquetWriter struct {
+	writer *parquet.Writer
+	cols   []outputColumn
+	now    time.Time
+}
+
+// newParquetWriter returns a parquetWriter that writes the given columns to w.
+func newParquetWriter(w io.Writer, cols []outputColumn) (*parquetWriter, error) {
+	schema := parquet.NewSchema("sample", newParquetColumns(cols))
+	pw := parquet.NewWriter(w, schema, parquet.Compression(&parquet.Snappy),
+		parquet.MaxRowsPerRowGroup(parquetRowGroupSize))
+
+	return &parquetWriter{writer: pw, cols: cols, now: time.Now()}, nil
+}
+
+// Write adds the sample as a row. Rows are buffered into row groups, which are
+// only written once large enough or on Close.
+func (p *parquetWriter) Write(sample *TrackedSample) error {
+	values := outputValues(p.cols, sample, p.now)
+	row := make(parquet.Row, len(values))
+
+	for i, v := range values {
+		row[i] = parquetValue(v, i)
+	}
+
+	_, err := p.writer.WriteRows([]parquet.Row{row})
+
+	return err
+}
+
+// parquetValue returns v as the parquet value of column i. Strings are
+// required, so are at definition level 0, while times and whole numbers are
+// optional, so are at level 1 unless null.
+func parquetValue(v any, i int) parquet.Value {
+	switch v := v.(type) {
+	case nil:
+		return parquet.NullValue().Level(0, 0, i)
+	case time.Time:
+		return parquet.Int64Value(v.UnixMilli()).Level(0, 1, i)
+	case int:
+		return parquet.Int64Value(int64(v)).Level(0, 1, i)
+	default:
+		return parquet.ByteArrayValue([]byte(fmt.Sprint(v))).Level(0, 0, i)
+	}
+}
+
+// Close writes any buffered rows and the file's footer.
+func (p *parquetWriter) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,153 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+)
+
+// readParquet returns the column names and rows of the given parquet file.
+func readParquet(data []byte) ([]string, []map[string]any) {
+	f, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	So(err, ShouldBeNil)
+
+	fields := f.Schema().Fields()
+	names := make([]string, len(fields))
+
+	for i, field := range fields {
+		names[i] = field.Name()
+	}
+
+	r := parquet.NewReader(f)
+	defer r.Close()
+
+	rows := make([]map[string]any, r.NumRows())
+
+	for i := range rows {
+		rows[i] = make(map[string]any)
+		So(r.Read(&rows[i]), ShouldBeNil)
+	}
+
+	return names, rows
+}
+
+func TestParquetWriter(t *testing.T) {
+	Convey("Given samples with missing values", t, func() {
+		ordered := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		five := 5
+		samples := []db.TrackedSample{
+			{FacultySponsor: "Ann/PI", SangerSampleID: "S1", LabwareHumanBarcode: "P1",
+				ManifestCreated: &ordered, OrderMade: &ordered, LibraryTime: &five},
+			{FacultySponsor: "Bob", SangerSampleID: "S2"},
+		}
+
+		Convey("A parquet export has typed, nullable columns", func() {
+			var buf bytes.Buffer
+
+			w, err := db.NewSampleWriter(&buf, db.FormatParquet)
+			So(err, ShouldBeNil)
+
+			for i := range samples {
+				So(w.Write(&samples[i]), ShouldBeNil)
+			}
+
+			So(w.Close(), ShouldBeNil)
+			So(buf.String(), ShouldStartWith, "PAR1")
+
+			names, rows := readParquet(buf.Bytes())
+			So(len(rows), ShouldEqual, 2)
+			So(rows[0]["SangerSampleID"], ShouldEqual, "S1")
+			So(rows[0]["Plate/Tube"], ShouldEqual, "P1")
+			So(rows[0]["OrderMade"], ShouldEqual, ordered.UnixMilli())
+			So(rows[0]["LibraryTime"], ShouldEqual, 5)
+			So(rows[0]["Stage"], ShouldEqual, db.StageOrdered.String())
+			So(rows[1]["OrderMade"], ShouldBeNil)
+			So(rows[1]["LibraryTime"], ShouldBeNil)
+
+			Convey("with the columns in the same order as a TSV export", func() {
+				var tsv bytes.Buffer
+
+				w, err := db.NewSampleWriter(&tsv, db.FormatTSV)
+				So(err, ShouldBeNil)
+				So(w.Write(&samples[0]), ShouldBeNil)
+				So(w.Close(), ShouldBeNil)
+
+				header, _, _ := strings.Cut(tsv.String(), "\n")
+				So(names, ShouldResemble, strings.Split(header, "\t"))
+			})
+		})
+
+		Convey("A partitioned export has a Hive style directory per partition", func() {
+			dir := filepath.Join(t.TempDir(), "samples")
+
+			for _, test := range []struct {
+				partition db.Partition
+				dirs      []string
+			}{
+				{db.PartitionBySponsor, []string{"sponsor=Ann%2FPI", "sponsor=Bob"}},
+				{db.PartitionByMonth, []string{"month=2025-01", "month=__HIVE_DEFAULT_PARTITION__"}},
+			} {
+				w, err := db.CreatePartitionedFiles(dir, db.FormatParquet, test.partition)
+				So(err, ShouldBeNil)
+
+				for i := range samples {
+					So(w.Write(&samples[i]), ShouldBeNil)
+				}
+
+				So(w.Close(), ShouldBeNil)
+
+				for i, sub := range test.dirs {
+					data, err := os.ReadFile(filepath.Join(dir, sub, "part-0.parquet"))
+					So(err, ShouldBeNil)
+
+					_, rows := readParquet(data)
+					So(len(rows), ShouldEqual, 1)
+					So(rows[0]["SangerSampleID"], ShouldEqual, samples[i].SangerSampleID)
+				}
+
+				So(os.RemoveAll(dir), ShouldBeNil)
+			}
+		})
+
+		Convey("Partitioning can be chosen by name", func() {
+			p, err := db.ParsePartition("Month")
+			So(err, ShouldBeNil)
+			So(p, ShouldEqual, db.PartitionByMonth)
+
+			_, err = db.ParsePartition("year")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -26,6 +26,7 @@
 package db
 
 import (
+	"context"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -68,7 +69,7 @@ func TestParseRows(t *testing.T) {
 		defer rows.Close()
 
 		// Parse rows with our function
-		result, err := parseRows(rows)
+		result, err := parseRows(context.Background(), rows)
 
 		Convey("It should handle NULL values without error", func() {
 			So(err, ShouldBeNil)
@@ -0,0 +1,183 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Partition is how an export is split into a directory of files.
+type Partition int
+
+// The ways an export can be split into a directory of files.
+const (
+	PartitionNone Partition = iota
+	PartitionBySponsor
+	PartitionByMonth
+)
+
+// partitionNames are the names of each Partition, which are also the keys of
+// the partition directories.
+var partitionNames = [...]string{"", "sponsor", "month"}
+
+const (
+	// hiveDefaultPartition is the directory value of samples without one.
+	hiveDefaultPartition = "__HIVE_DEFAULT_PARTITION__"
+
+	// hiveEscapedChars are percent-encoded in directory values.
+	hiveEscapedChars = "\"#%'*/:=?\\[]^{}"
+
+	// partitionFile is the name of each partition's file, before its
+	// extension.
+	partitionFile = "part-0"
+)
+
+// ParsePartition returns the Partition with the given name: sponsor, month,
+// or empty for none.
+func ParsePartition(name string) (Partition, error) {
+	for i, n := range partitionNames {
+		if strings.EqualFold(name, n) {
+			return Partition(i), nil
+		}
+	}
+
+	return PartitionNone, fmt.Errorf("unknown partitioning %q, expected sponsor or month", name)
+}
+
+// String returns the name of the partition.
+func (p Partition) String() string {
+	return partitionNames[p]
+}
+
+// directory returns the Hive style key=value directory name of the partition
+// the sample belongs in. Samples are partitioned by month on when their
+// manifest was created.
+func (p Partition) directory(s *TrackedSample) string {
+	value := s.FacultySponsor
+
+	if p == PartitionByMonth {
+		value = ""
+
+		if s.ManifestCreated != nil {
+			value = s.ManifestCreated.UTC().Format("2006-01")
+		}
+	}
+
+	return p.String() + "=" + escapePartitionValue(value)
+}
+
+// escapePartitionValue percent-encodes the characters of v that Hive does in
+// directory values, or returns the Hive default partition if v is empty.
+func escapePartitionValue(v string) string {
+	if v == "" {
+		return hiveDefaultPartition
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(v); i++ {
+		if c := v[i]; c < ' ' || c == 0x7f || strings.IndexByte(hiveEscapedChars, c) >= 0 {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+// partitionedWriter writes samples to a file per partition.
+type partitionedWriter struct {
+	dir       string
+	format    Format
+	partition Partition
+	opts      []WriterOption
+	writers   map[string]SampleWriter
+}
+
+// CreatePartitionedFiles creates the directory dir and returns a SampleWriter
+// that writes each sample to a file like CreateSampleFile's, in a
+// subdirectory of dir for the sample's partition, named like
+// "sponsor=Carl Anderson" or "month=2025-01", so that tools like DuckDB and
+// Polars can query dir as a Hive partitioned dataset.
+func CreatePartitionedFiles(dir string, format Format, partition Partition,
+	opts ...WriterOption) (SampleWriter, error) {
+	// Check the options before creating the directory
+	if _, _, err := newWriterConfig(opts); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &partitionedWriter{
+		dir:       dir,
+		format:    format,
+		partition: partition,
+		opts:      opts,
+		writers:   make(map[string]SampleWriter),
+	}, nil
+}
+
+// Write writes the sample to its partition's file, creating it if necessary.
+func (p *partitionedWriter) Write(sample *TrackedSample) error {
+	dir := p.partition.directory(sample)
+
+	writer, ok := p.writers[dir]
+	if !ok {
+		path := filepath.Join(p.dir, dir)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return err
+		}
+
+		var err error
+
+		writer, err = CreateSampleFile(filepath.Join(path, partitionFile+p.format.Extension()), p.format, p.opts...)
+		if err != nil {
+			return err
+		}
+
+		p.writers[dir] = writer
+	}
+
+	return writer.Write(sample)
+}
+
+// Close closes every partition's file.
+func (p *partitionedWriter) Close() error {
+	errs := make([]error, 0, len(p.writers))
+
+	for _, writer := range p.writers {
+		errs = append(errs, writer.Close())
+	}
+
+	return errors.Join(errs...)
+}
@@ -26,17 +26,73 @@
 package db
 
 import (
-	"encoding/csv"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"strconv"
 	"time"
 )
 
+// SampleHandler is called with each sample as it is read. Returning an error
+// stops the read, and that error is returned to the caller.
+type SampleHandler func(sample *TrackedSample) error
+
 // QueryProvider defines an interface for executing database queries
-// and retrieving results.
+// and retrieving results. Providers that hold connections open between
+// queries also implement io.Closer, and should be closed when no longer
+// needed.
 type QueryProvider interface {
+	// Execute runs the query without a deadline.
 	Execute() (*TrackedSampleCollection, error)
+
+	// ExecuteContext runs the query, abandoning it with the context's error
+	// if the context is cancelled or its deadline passes first.
+	ExecuteContext(ctx context.Context) (*TrackedSampleCollection, error)
+}
+
+// StreamingQueryProvider is a QueryProvider that can also hand over samples
+// one at a time as they are read, for callers that don't want to hold the
+// whole result set in memory.
+type StreamingQueryProvider interface {
+	QueryProvider
+
+	// Stream runs the query and calls fn with each sample in turn.
+	Stream(ctx context.Context, fn SampleHandler) error
+}
+
+// StreamSamples calls fn with each sample of provider in turn, streaming them
+// if it is a StreamingQueryProvider, or else executing its query and going
+// through the results.
+func StreamSamples(ctx context.Context, provider QueryProvider, fn SampleHandler) error {
+	if streamer, ok := provider.(StreamingQueryProvider); ok {
+		return streamer.Stream(ctx, fn)
+	}
+
+	samples, err := provider.ExecuteContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range samples.Samples {
+		if err = fn(&samples.Samples[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IncrementalQueryProvider is a QueryProvider that can also fetch just the
+// samples that have changed recently, for merging into an earlier full result
+// with TrackedSampleCollection.Merge. Deleted samples are not reported, so a
+// full Execute is still needed from time to time.
+type IncrementalQueryProvider interface {
+	QueryProvider
+
+	// ExecuteSince returns the samples that reached a milestone at or after
+	// the given watermark.
+	ExecuteSince(ctx context.Context, watermark time.Time) (*TrackedSampleCollection, error)
 }
 
 // config holds configuration options for creating a QueryProvider.
@@ -44,12 +100,19 @@ type config struct {
 	useMock   bool
 	mockPath  string
 	connector DBConnector
+	params    QueryParams
+	strict    bool
+	retry     RetryPolicy
+	query     string
 }
 
 // Option is a function that configures a config.
 type Option func(*config)
 
-// WithMockData configures the provider to use mock data from a TSV file.
+// WithMockData configures the provider to read samples from a file instead of
+// the database. The file may be TSV, CSV, JSON or JSON Lines, optionally
+// gzipped, as detected by OpenSampleFile, so this can also serve an archived
+// export or snapshot.
 func WithMockData(path string) Option {
 	return func(c *config) {
 		c.useMock = true
@@ -64,115 +127,231 @@ func WithMySQLConnector(connector DBConnector) Option {
 	}
 }
 
+// WithConnectionConfig configures the provider to connect to the MySQL
+// database described by conn, instead of one configured by the environment,
+// retrying queries as conn.Retry says.
+func WithConnectionConfig(conn ConnectionConfig) Option {
+	return func(c *config) {
+		c.connector = NewMySQLConnector(conn)
+		c.retry = conn.Retry
+	}
+}
+
+// WithRetries configures the MySQL provider to retry queries that fail with
+// transient errors (see IsTransient) as policy says. It has no effect on mock
+// data.
+func WithRetries(policy RetryPolicy) Option {
+	return func(c *config) {
+		c.retry = policy
+	}
+}
+
+// WithQueryParams configures which samples the MySQL provider queries for,
+// instead of DefaultQueryParams(). It has no effect on mock data.
+func WithQueryParams(params QueryParams) Option {
+	return func(c *config) {
+		c.params = params
+	}
+}
+
+// WithQuery configures the MySQL provider to run query, as returned by
+// LoadQuery, instead of the embedded one, unless query is empty. It has no
+// effect on mock data.
+func WithQuery(query string) Option {
+	return func(c *config) {
+		if query != "" {
+			c.query = query
+		}
+	}
+}
+
+// WithStrictParsing makes the mock provider fail if its TSV or CSV file has
+// malformed values or short rows, reporting every one with its line and
+// column, instead of leaving those fields unset. It has no effect on the MySQL provider.
+func WithStrictParsing() Option {
+	return func(c *config) {
+		c.strict = true
+	}
+}
+
 // MySQLQueryProvider implements QueryProvider for MySQL databases.
 type MySQLQueryProvider struct {
 	connector DBConnector
+	params    QueryParams
+	query     string
 }
 
 // Execute executes the SQL query and returns the results.
 func (p *MySQLQueryProvider) Execute() (*TrackedSampleCollection, error) {
-	db, err := p.connector.Connect()
+	return p.ExecuteContext(context.Background())
+}
+
+// ExecuteContext executes the SQL query and returns the results, cancelling
+// the query if ctx is done before it completes.
+func (p *MySQLQueryProvider) ExecuteContext(ctx context.Context) (*TrackedSampleCollection, error) {
+	return collect(ctx, p.Stream)
+}
+
+// ExecuteSince executes the SQL query restricted to samples that reached a
+// milestone at or after watermark.
+func (p *MySQLQueryProvider) ExecuteSince(ctx context.Context,
+	watermark time.Time) (*TrackedSampleCollection, error) {
+	params := p.params
+	params.ChangedSince = watermark
+
+	return collect(ctx, func(ctx context.Context, fn SampleHandler) error {
+		return p.stream(ctx, params, fn)
+	})
+}
+
+// Stream executes the SQL query and calls fn with each sample as its row is
+// read, so that the full result set never has to be held in memory.
+func (p *MySQLQueryProvider) Stream(ctx context.Context, fn SampleHandler) error {
+	return p.stream(ctx, p.params, fn)
+}
+
+// stream executes the SQL query with the given params, calling fn with each
+// sample.
+func (p *MySQLQueryProvider) stream(ctx context.Context, params QueryParams, fn SampleHandler) error {
+	db, err := p.connector.Connect(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("database connection error: %w", err)
+		return fmt.Errorf("database connection error: %w", err)
 	}
-	defer p.connector.Close()
 
 	// Check for nil db connection - this protects against mock tests
 	// that don't configure a proper DB object
 	if db == nil {
-		return nil, fmt.Errorf("database connection is nil")
+		return fmt.Errorf("database connection is nil")
 	}
 
-	// Execute the embedded query
-	rows, err := db.Query(GetEmbeddedSQL())
+	// Execute our query with our params bound
+	query, args := params.Build(p.query)
+
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("query execution error: %w", err)
+		return fmt.Errorf("query execution error: %w", preferContextError(ctx, err))
 	}
 	defer rows.Close()
 
-	return parseRows(rows)
+	return scanRows(ctx, rows, fn)
 }
 
-// MockQueryProvider implements QueryProvider for testing with mock data.
-type MockQueryProvider struct {
-	tsvPath string
+// Close closes the provider's connections to the database, which are
+// otherwise kept open between queries. It should be called once the provider
+// is no longer needed.
+func (p *MySQLQueryProvider) Close() error {
+	return p.connector.Close()
 }
 
-// Execute reads sample data from a TSV file instead of the database.
-func (p *MockQueryProvider) Execute() (*TrackedSampleCollection, error) {
-	file, err := os.Open(p.tsvPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open mock data file: %w", err)
+// preferContextError returns ctx's error if it is done, since drivers report
+// cancellation in their own ways, or err otherwise.
+func preferContextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	reader.Comma = '\t'
+	return err
+}
 
-	records, err := reader.ReadAll()
+// collect gathers every sample passed on by stream into a collection.
+func collect(ctx context.Context,
+	stream func(context.Context, SampleHandler) error) (*TrackedSampleCollection, error) {
+	var samples []TrackedSample
+
+	err := stream(ctx, func(s *TrackedSample) error {
+		samples = append(samples, *s)
+
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read mock data: %w", err)
+		return nil, err
 	}
 
-	if len(records) < 2 {
-		return nil, fmt.Errorf("mock data file should contain at least header and one data row")
+	return &TrackedSampleCollection{Samples: samples}, nil
+}
+
+// MockQueryProvider implements QueryProvider by reading samples from a file,
+// for testing or for serving archived samples.
+type MockQueryProvider struct {
+	path   string
+	strict bool
+}
+
+// Execute reads sample data from the file instead of the database.
+func (p *MockQueryProvider) Execute() (*TrackedSampleCollection, error) {
+	return p.ExecuteContext(context.Background())
+}
+
+// ExecuteContext reads sample data from the file instead of the database,
+// stopping early if ctx is done.
+func (p *MockQueryProvider) ExecuteContext(ctx context.Context) (*TrackedSampleCollection, error) {
+	return collect(ctx, p.Stream)
+}
+
+// ExecuteSince reads the samples from the file that reached a milestone at
+// or after watermark.
+func (p *MockQueryProvider) ExecuteSince(ctx context.Context,
+	watermark time.Time) (*TrackedSampleCollection, error) {
+	return collect(ctx, func(ctx context.Context, fn SampleHandler) error {
+		return p.Stream(ctx, func(s *TrackedSample) error {
+			if s.LastChanged().Before(watermark) {
+				return nil
+			}
+
+			return fn(s)
+		})
+	})
+}
+
+// Stream reads sample data from the file one sample at a time, calling fn with
+// each sample. The file's format is detected like OpenSampleFile.
+func (p *MockQueryProvider) Stream(ctx context.Context, fn SampleHandler) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	file, err := OpenSampleFile(p.path, p.strict)
+	if err != nil {
+		return fmt.Errorf("failed to open mock data file: %w", err)
 	}
+	defer file.Close()
 
-	return parseMockRecords(records[1:])
+	return streamMockSamples(ctx, file, fn)
 }
 
-// parseMockRecords converts TSV records into TrackedSample objects.
-func parseMockRecords(records [][]string) (*TrackedSampleCollection, error) {
-	samples := make([]TrackedSample, 0, len(records))
+// Close does nothing, since the file is only open while it is read.
+func (p *MockQueryProvider) Close() error {
+	return nil
+}
 
-	for i, record := range records {
-		if len(record) < 21 {
-			return nil, fmt.Errorf("row %d has insufficient columns: expected 21, got %d",
-				i+1, len(record))
+// streamMockSamples passes each sample read by reader to fn.
+func streamMockSamples(ctx context.Context, reader SampleReader, fn SampleHandler) error {
+	rows := 0
+
+	for ; ; rows++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		sample, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
 		}
 
-		sample, err := recordToSample(record)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing row %d: %w", i+1, err)
+			return fmt.Errorf("failed to read mock data: %w", err)
 		}
 
-		samples = append(samples, sample)
+		if err = fn(sample); err != nil {
+			return err
+		}
 	}
 
-	return &TrackedSampleCollection{Samples: samples}, nil
-}
-
-// recordToSample converts a single TSV record to a TrackedSample.
-func recordToSample(record []string) (TrackedSample, error) {
-	sample := TrackedSample{
-		StudyID:             record[0],
-		StudyName:           record[1],
-		FacultySponsor:      record[2],
-		Programme:           record[3],
-		SangerSampleID:      record[4],
-		SupplierName:        record[5],
-		LabwareHumanBarcode: record[9],
-		RunID:               record[14],
-		Platform:            record[15],
-		Pipeline:            record[16],
-		QCPass:              record[20],
+	if rows == 0 {
+		return fmt.Errorf("mock data file should contain at least header and one data row")
 	}
 
-	// Parse date fields
-	sample.ManifestCreated = parseTime(record[6])
-	sample.ManifestUploaded = parseTime(record[7])
-	sample.LabwareReceived = parseTime(record[8])
-	sample.OrderMade = parseTime(record[10])
-	sample.LibraryStart = parseTime(record[11])
-	sample.LibraryComplete = parseTime(record[12])
-	sample.SequencingRunStart = parseTime(record[17])
-	sample.SequencingQCComplete = parseTime(record[18])
-
-	// Parse integer fields
-	sample.LibraryTime = parseInt(record[13])
-	sample.SequencingTime = parseInt(record[19])
-
-	return sample, nil
+	return nil
 }
 
 // New creates a new QueryProvider with the appropriate implementation based on options.
@@ -180,6 +359,8 @@ func New(opts ...Option) (QueryProvider, error) {
 	cfg := &config{
 		useMock:   false,
 		connector: &MySQLConnector{},
+		params:    DefaultQueryParams(),
+		query:     GetEmbeddedSQL(),
 	}
 
 	for _, opt := range opts {
@@ -196,34 +377,14 @@ func New(opts ...Option) (QueryProvider, error) {
 			return nil, fmt.Errorf("mock data file error: %w", err)
 		}
 
-		return &MockQueryProvider{tsvPath: cfg.mockPath}, nil
-	}
-
-	return &MySQLQueryProvider{connector: cfg.connector}, nil
-}
-
-func parseTime(s string) *time.Time {
-	if s == "" {
-		return nil
-	}
-
-	t, err := time.Parse(time.RFC3339, s)
-	if err != nil {
-		return nil
+		return &MockQueryProvider{path: cfg.mockPath, strict: cfg.strict}, nil
 	}
 
-	return &t
-}
-
-func parseInt(s string) *int {
-	if s == "" {
-		return nil
-	}
+	provider := &MySQLQueryProvider{connector: cfg.connector, params: cfg.params, query: cfg.query}
 
-	i, err := strconv.Atoi(s)
-	if err != nil {
-		return nil
+	if cfg.retry.enabled() {
+		return newRetryingProvider(provider, cfg.retry), nil
 	}
 
-	return &i
+	return provider, nil
 }
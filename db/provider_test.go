@@ -26,11 +26,17 @@
 package db_test
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/wtsi-hgi/gst/db"
 )
@@ -42,7 +48,7 @@ type mockConnector struct {
 	shouldFail    bool
 }
 
-func (m *mockConnector) Connect() (*sql.DB, error) {
+func (m *mockConnector) Connect(_ context.Context) (*sql.DB, error) {
 	m.connectCalled = true
 	if m.shouldFail {
 		return nil, sql.ErrConnDone
@@ -67,8 +73,11 @@ func TestQueryProvider(t *testing.T) {
 			// This will fail since we're not providing a real DB connection
 			_, err := provider.Execute()
 
-			Convey("Then the connector methods should be called", func() {
+			Convey("Then the connector should be connected but kept open until the provider is closed", func() {
 				So(mockConn.connectCalled, ShouldBeTrue)
+				So(mockConn.closeCalled, ShouldBeFalse)
+
+				So(provider.(io.Closer).Close(), ShouldBeNil)
 				So(mockConn.closeCalled, ShouldBeTrue)
 			})
 
@@ -101,6 +110,133 @@ func TestQueryProvider(t *testing.T) {
 				So(samples.Samples[0].Platform, ShouldEqual, "Illumina")
 			})
 		})
+
+		Convey("When streaming the samples", func() {
+			streamer, ok := provider.(db.StreamingQueryProvider)
+			So(ok, ShouldBeTrue)
+
+			var ids []string
+			err := streamer.Stream(context.Background(), func(s *db.TrackedSample) error {
+				ids = append(ids, s.SangerSampleID)
+
+				return nil
+			})
+
+			Convey("It should pass each sample to the handler", func() {
+				So(err, ShouldBeNil)
+				So(ids, ShouldResemble, []string{"SANG123"})
+			})
+		})
+
+		Convey("When the stream handler returns an error", func() {
+			streamer := provider.(db.StreamingQueryProvider)
+			errStop := errors.New("stop")
+
+			err := streamer.Stream(context.Background(), func(*db.TrackedSample) error {
+				return errStop
+			})
+
+			Convey("It should stop and return that error", func() {
+				So(err, ShouldEqual, errStop)
+			})
+		})
+
+		Convey("When fetching the samples changed since a watermark", func() {
+			incremental, ok := provider.(db.IncrementalQueryProvider)
+			So(ok, ShouldBeTrue)
+
+			before := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			after := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+
+			Convey("It should include samples with a milestone after it", func() {
+				samples, err := incremental.ExecuteSince(context.Background(), before)
+				So(err, ShouldBeNil)
+				So(len(samples.Samples), ShouldEqual, 1)
+			})
+
+			Convey("It should exclude samples without a milestone after it", func() {
+				samples, err := incremental.ExecuteSince(context.Background(), after)
+				So(err, ShouldBeNil)
+				So(len(samples.Samples), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When executing with a cancelled context", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			samples, err := provider.ExecuteContext(ctx)
+
+			Convey("It should return the context error", func() {
+				So(err, ShouldEqual, context.Canceled)
+				So(samples, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given a MySQL query provider with a sqlmock database", t, func() {
+		mockDB, mock, err := sqlmock.New()
+		So(err, ShouldBeNil)
+		defer mockDB.Close()
+
+		provider, err := db.New(db.WithMySQLConnector(&mockConnector{mockDB: mockDB}))
+		So(err, ShouldBeNil)
+
+		Convey("When streaming the query results", func() {
+			mock.ExpectQuery("SELECT").WillReturnRows(
+				sqlmock.NewRows(sampleColumns).
+					AddRow(sampleRow("SANG1")...).
+					AddRow(sampleRow("SANG2")...),
+			)
+
+			var ids []string
+			err := provider.(db.StreamingQueryProvider).Stream(context.Background(),
+				func(s *db.TrackedSample) error {
+					ids = append(ids, s.SangerSampleID)
+
+					return nil
+				})
+
+			Convey("It should pass each row to the handler in order", func() {
+				So(err, ShouldBeNil)
+				So(ids, ShouldResemble, []string{"SANG1", "SANG2"})
+				So(mock.ExpectationsWereMet(), ShouldBeNil)
+			})
+		})
+
+		Convey("When executing with custom query params", func() {
+			provider, err := db.New(
+				db.WithMySQLConnector(&mockConnector{mockDB: mockDB}),
+				db.WithQueryParams(db.QueryParams{Sponsors: []string{"New PI"}}),
+			)
+			So(err, ShouldBeNil)
+
+			mock.ExpectQuery(`tps\.faculty_sponsor IN \(\?\)`).WithArgs("New PI").
+				WillReturnRows(sqlmock.NewRows(sampleColumns).AddRow(sampleRow("SANG1")...))
+
+			samples, err := provider.Execute()
+
+			Convey("It should bind the params as placeholders", func() {
+				So(err, ShouldBeNil)
+				So(len(samples.Samples), ShouldEqual, 1)
+				So(mock.ExpectationsWereMet(), ShouldBeNil)
+			})
+		})
+
+		Convey("When the query deadline passes before results arrive", func() {
+			mock.ExpectQuery("SELECT").WillDelayFor(time.Second).
+				WillReturnRows(sqlmock.NewRows([]string{"study_id"}))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			_, err := provider.ExecuteContext(ctx)
+
+			Convey("It should fail with the deadline error", func() {
+				So(err, ShouldNotBeNil)
+				So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+			})
+		})
 	})
 
 	Convey("Given no options", t, func() {
@@ -114,6 +250,108 @@ func TestQueryProvider(t *testing.T) {
 	})
 }
 
+// executingProvider is a QueryProvider that can't stream.
+type executingProvider struct {
+	samples *db.TrackedSampleCollection
+}
+
+func (e *executingProvider) Execute() (*db.TrackedSampleCollection, error) {
+	return e.samples, nil
+}
+
+func (e *executingProvider) ExecuteContext(_ context.Context) (*db.TrackedSampleCollection, error) {
+	return e.samples, nil
+}
+
+func TestStreamSamples(t *testing.T) {
+	Convey("Samples can be streamed from a provider that can't stream", t, func() {
+		provider := &executingProvider{samples: &db.TrackedSampleCollection{
+			Samples: []db.TrackedSample{{SangerSampleID: "SANG1"}, {SangerSampleID: "SANG2"}},
+		}}
+
+		var ids []string
+		err := db.StreamSamples(context.Background(), provider, func(s *db.TrackedSample) error {
+			ids = append(ids, s.SangerSampleID)
+
+			return nil
+		})
+		So(err, ShouldBeNil)
+		So(ids, ShouldResemble, []string{"SANG1", "SANG2"})
+	})
+}
+
+func TestConnectionConfig(t *testing.T) {
+	Convey("Given connection details", t, func() {
+		conn := db.DefaultConnectionConfig()
+		conn.User = "gst_reader"
+		conn.Password = "secret"
+		conn.Host = "mlwh.example.com"
+		conn.Name = "mlwh"
+
+		Convey("The DSN uses them", func() {
+			dsn, err := conn.DSN()
+			So(err, ShouldBeNil)
+			So(dsn, ShouldStartWith, "gst_reader:secret@tcp(mlwh.example.com:3306)/mlwh?")
+			So(dsn, ShouldContainSubstring, "parseTime=true")
+		})
+
+		Convey("A password file is read in place of the password", func() {
+			conn.PasswordFile = filepath.Join(t.TempDir(), "pass")
+			So(os.WriteFile(conn.PasswordFile, []byte("mounted\n"), 0600), ShouldBeNil)
+
+			dsn, err := conn.DSN()
+			So(err, ShouldBeNil)
+			So(dsn, ShouldStartWith, "gst_reader:mounted@")
+
+			So(os.Remove(conn.PasswordFile), ShouldBeNil)
+
+			_, err = conn.DSN()
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("A connector fails to connect if the password file can't be read", func() {
+			conn.PasswordFile = filepath.Join(t.TempDir(), "missing")
+			connector := db.NewMySQLConnector(conn)
+
+			_, err := connector.Connect(context.Background())
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "password file")
+			So(connector.Health(), ShouldBeNil)
+			So(connector.Close(), ShouldBeNil)
+		})
+
+		Convey("The environment overrides them", func() {
+			t.Setenv("GST_SQL_PORT", "3307")
+			t.Setenv("GST_SQL_PASS_FILE", "/run/secrets/gst")
+
+			conn.ApplyEnv()
+			So(conn.Port, ShouldEqual, "3307")
+			So(conn.PasswordFile, ShouldEqual, "/run/secrets/gst")
+			So(conn.User, ShouldEqual, "gst_reader")
+		})
+	})
+}
+
+// sampleColumns are the column names returned by the embedded query.
+var sampleColumns = []string{
+	"study_id", "StudyName", "faculty_sponsor", "programme",
+	"sanger_sample_id", "supplier_name", "manifest_created",
+	"manifest_uploaded", "labware_received", "Plate/Tube",
+	"order_made", "library_start", "library_complete", "LibraryTime",
+	"RunID", "Platform", "Pipeline", "sequencing_run_start",
+	"sequencing_qc_complete", "SequencingTime", "qcPass",
+}
+
+// sampleRow returns sqlmock row values for a sample with the given ID and no
+// milestone dates.
+func sampleRow(id string) []driver.Value {
+	return []driver.Value{
+		"1234", "Test Study", "Test Sponsor", "Test Programme",
+		id, "Test Supplier", nil, nil, nil, "PLATE001", nil, nil, nil, nil,
+		"RUN001", "Illumina", "Pipeline1", nil, nil, nil, "1",
+	}
+}
+
 func createMockTSVFile(path string) {
 	// Format: tab-delimited with header row matching the fields in TrackedSample
 	data := `StudyID	StudyName	FacultySponsor	Programme	SangerSampleID	SupplierName	ManifestCreated	ManifestUploaded	LabwareReceived	Plate/Tube	OrderMade	LibraryStart	LibraryComplete	LibraryTime	RunID	Platform	Pipeline	SequencingRunStart	SequencingQCComplete	SequencingTime	QCPass
@@ -0,0 +1,132 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// queryExtension is the extension of the query files in a directory of named
+// queries.
+const queryExtension = ".sql"
+
+// QueryChecker is a QueryProvider that can check its query before running it
+// for real.
+type QueryChecker interface {
+	// CheckQuery runs the query without returning any rows, and returns a
+	// *QueryShapeError if the result columns can't be made into samples.
+	CheckQuery(ctx context.Context) error
+}
+
+// LoadQuery returns the query in the SQL file at path, for use instead of the
+// embedded one with WithQuery. Its results must have a column for each
+// TrackedSample field (named like the field, or the embedded query's column,
+// ignoring case and underscores), and it must have a /* conditions */ comment
+// where the WHERE clause built from the query params goes.
+func LoadQuery(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	query := strings.TrimSpace(string(data))
+	if !strings.Contains(query, conditionsMarker) {
+		return "", fmt.Errorf("query %s has no %s comment for the query params to go in", path, conditionsMarker)
+	}
+
+	return query, nil
+}
+
+// LoadNamedQuery returns the query in the file called name plus .sql in dir,
+// like LoadQuery.
+func LoadNamedQuery(dir, name string) (string, error) {
+	if name == "" || filepath.Base(name) != name {
+		return "", fmt.Errorf("invalid query name %q", name)
+	}
+
+	query, err := LoadQuery(filepath.Join(dir, name+queryExtension))
+	if errors.Is(err, os.ErrNotExist) {
+		names, _ := NamedQueries(dir)
+
+		return "", fmt.Errorf("no query named %q in %s, expected one of: %s", name, dir, strings.Join(names, ", "))
+	}
+
+	return query, err
+}
+
+// NamedQueries returns the names of the queries in dir, which are the names
+// of its .sql files without the extension, in order.
+func NamedQueries(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), queryExtension); ok && !entry.IsDir() {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// CheckQuery runs the query, with our params and LIMIT 0 appended so that it
+// returns no rows, and returns a *QueryShapeError if the result columns can't
+// be made into samples. This finds a bad query quickly, before a long run. The
+// LIMIT goes on its own line, so that a trailing comment can't hide it.
+func (p *MySQLQueryProvider) CheckQuery(ctx context.Context) error {
+	db, err := p.connector.Connect(ctx)
+	if err != nil {
+		return fmt.Errorf("database connection error: %w", err)
+	}
+
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	query, args := p.params.Build(p.query)
+
+	rows, err := db.QueryContext(ctx, query+"\nLIMIT 0", args...)
+	if err != nil {
+		return fmt.Errorf("query execution error: %w", preferContextError(ctx, err))
+	}
+	defer rows.Close()
+
+	_, err = newRowScanner(rows)
+
+	return err
+}
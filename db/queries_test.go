@@ -0,0 +1,149 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+)
+
+func TestQueryFiles(t *testing.T) {
+	Convey("Given a directory of query files", t, func() {
+		dir := t.TempDir()
+		custom := "SELECT * FROM samples\n/* conditions */\nORDER BY id;\n"
+		So(os.WriteFile(filepath.Join(dir, "custom.sql"), []byte(custom), 0600), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(dir, "broken.sql"), []byte("SELECT * FROM samples"), 0600), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a query"), 0600), ShouldBeNil)
+
+		Convey("Queries can be loaded by path or name", func() {
+			query, err := db.LoadQuery(filepath.Join(dir, "custom.sql"))
+			So(err, ShouldBeNil)
+			So(query, ShouldEqual, "SELECT * FROM samples\n/* conditions */\nORDER BY id;")
+
+			named, err := db.LoadNamedQuery(dir, "custom")
+			So(err, ShouldBeNil)
+			So(named, ShouldEqual, query)
+		})
+
+		Convey("The query names are listed", func() {
+			names, err := db.NamedQueries(dir)
+			So(err, ShouldBeNil)
+			So(names, ShouldResemble, []string{"broken", "custom"})
+		})
+
+		Convey("A query without a conditions comment is rejected", func() {
+			_, err := db.LoadNamedQuery(dir, "broken")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "/* conditions */")
+		})
+
+		Convey("An unknown name lists the known ones", func() {
+			_, err := db.LoadNamedQuery(dir, "missing")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "broken, custom")
+
+			_, err = db.LoadNamedQuery(dir, "../custom")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestQueryShape(t *testing.T) {
+	Convey("Given a MySQL provider with a custom query and a sqlmock database", t, func() {
+		mockDB, mock, err := sqlmock.New()
+		So(err, ShouldBeNil)
+		defer mockDB.Close()
+
+		provider, err := db.New(db.WithMySQLConnector(&mockConnector{mockDB: mockDB}),
+			db.WithQuery("SELECT custom FROM samples /* conditions */"),
+			db.WithQueryParams(db.QueryParams{}))
+		So(err, ShouldBeNil)
+
+		Convey("Columns are matched by name, in any order, ignoring extras", func() {
+			columns := append([]string{"extra"}, sampleColumns...)
+			values := append([]driver.Value{"ignored"}, sampleRow("SANG1")...)
+			slices.Reverse(columns)
+			slices.Reverse(values)
+
+			mock.ExpectQuery("SELECT custom FROM samples").
+				WillReturnRows(sqlmock.NewRows(columns).AddRow(values...))
+
+			samples, err := provider.Execute()
+			So(err, ShouldBeNil)
+			So(len(samples.Samples), ShouldEqual, 1)
+			So(samples.Samples[0].SangerSampleID, ShouldEqual, "SANG1")
+			So(samples.Samples[0].LabwareHumanBarcode, ShouldEqual, "PLATE001")
+			So(samples.Samples[0].Platform, ShouldEqual, "Illumina")
+		})
+
+		Convey("Missing columns are reported before any rows are scanned", func() {
+			mock.ExpectQuery("SELECT custom FROM samples").
+				WillReturnRows(sqlmock.NewRows(sampleColumns[2:]).AddRow(sampleRow("SANG1")[2:]...))
+
+			_, err := provider.Execute()
+
+			var shape *db.QueryShapeError
+			So(errors.As(err, &shape), ShouldBeTrue)
+			So(shape.Missing, ShouldResemble, []string{"StudyID", "StudyName"})
+			So(err.Error(), ShouldContainSubstring, "missing columns StudyID, StudyName")
+		})
+
+		Convey("The pre-flight check reports columns of the wrong type", func() {
+			defs := make([]*sqlmock.Column, len(sampleColumns))
+			for i, name := range sampleColumns {
+				defs[i] = sqlmock.NewColumn(name).OfType("VARCHAR", "")
+			}
+
+			defs[6] = sqlmock.NewColumn("manifest_created").OfType("DATETIME", time.Time{})
+			defs[13] = sqlmock.NewColumn("LibraryTime").OfType("DECIMAL", "").WithPrecisionAndScale(10, 2)
+			defs[19] = sqlmock.NewColumn("SequencingTime").OfType("DECIMAL", "").WithPrecisionAndScale(10, 0)
+
+			mock.ExpectQuery(`^SELECT custom FROM samples\s+LIMIT 0$`).
+				WillReturnRows(sqlmock.NewRowsWithColumnDefinition(defs...))
+
+			err := provider.(db.QueryChecker).CheckQuery(context.Background())
+
+			var shape *db.QueryShapeError
+			So(errors.As(err, &shape), ShouldBeTrue)
+			So(shape.Missing, ShouldBeEmpty)
+			So(shape.Mismatched, ShouldContain, "manifest_uploaded is VARCHAR, but ManifestUploaded needs a date or time")
+			So(shape.Mismatched, ShouldContain, "LibraryTime is DECIMAL, but LibraryTime needs an integer")
+			So(shape.Mismatched, ShouldNotContain, "SequencingTime is DECIMAL, but SequencingTime needs an integer")
+			So(shape.Mismatched, ShouldNotContain, "manifest_created is DATETIME, but ManifestCreated needs a date or time")
+			So(mock.ExpectationsWereMet(), ShouldBeNil)
+		})
+	})
+}
@@ -39,7 +39,6 @@ LEFT JOIN pac_bio_run_well_metrics pbw on pbw.id_pac_bio_product = pbm.id_pac_bi
 
 LEFT JOIN oseq_flowcell ofc on ofc.id_sample_tmp = sa.id_sample_tmp
 
-WHERE tps.manifest_created >= DATE_SUB(NOW(), INTERVAL 2 YEAR)
-AND tps.faculty_sponsor IN ('Carl Anderson' 'Emma Davenport','Matthew Hurles','Hilary Martin','Gosia Trynka', 'Ben Lehner', 'Leopold Parts', 'Jussi Taipale')
+/* conditions */
 
 ORDER BY COALESCE(tps.manifest_created, tps.manifest_uploaded), tps.study_id, tps.sanger_sample_id, RunID; 
\ No newline at end of file
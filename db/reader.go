@@ -0,0 +1,192 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CellError is a malformed value, or a missing one if Column is past the end
+// of the row, in a TSV file. Line and Column count from 1.
+type CellError struct {
+	Line   int
+	Column int
+	Header string
+	Err    error
+}
+
+// Error describes where the problem is and what it is.
+func (e *CellError) Error() string {
+	return fmt.Sprintf("line %d, column %d (%s): %s", e.Line, e.Column, e.Header, e.Err)
+}
+
+// Unwrap returns the underlying problem.
+func (e *CellError) Unwrap() error {
+	return e.Err
+}
+
+// ParseErrors are all the malformed values found in a TSV file.
+type ParseErrors []*CellError
+
+// Error lists every problem, one per line.
+func (e ParseErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+
+	return fmt.Sprintf("%d malformed values:\n%s", len(e), strings.Join(lines, "\n"))
+}
+
+// DelimitedReader reads samples from TSV or CSV with a header row, such as
+// that written by NewTSVWriter, matching columns to sample fields by header name.
+type DelimitedReader struct {
+	reader *csv.Reader
+	header []string
+	fields []*column
+	strict bool
+	errs   ParseErrors
+}
+
+// NewTSVReader reads the header row of tab separated values from r. Header
+// names are matched to sample fields ignoring case. Unknown columns, such as
+// the derived Stage, are ignored, and missing ones leave their field unset,
+// but SangerSampleID is required. If strict, malformed values and short rows
+// are reported by Read, otherwise they leave their fields unset.
+func NewTSVReader(r io.Reader, strict bool) (*DelimitedReader, error) {
+	return newDelimitedReader(r, '\t', strict)
+}
+
+// NewCSVReader is like NewTSVReader, but for comma separated values.
+func NewCSVReader(r io.Reader, strict bool) (*DelimitedReader, error) {
+	return newDelimitedReader(r, ',', strict)
+}
+
+// newDelimitedReader reads the header row of values separated by comma from r.
+func newDelimitedReader(r io.Reader, comma rune, strict bool) (*DelimitedReader, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	header = append([]string(nil), header...)
+
+	fields, err := mapColumns(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DelimitedReader{reader: reader, header: header, fields: fields, strict: strict}, nil
+}
+
+// mapColumns returns the column for each header name, nil for unknown ones.
+func mapColumns(header []string) ([]*column, error) {
+	fields := make([]*column, len(header))
+	seen := make(map[string]bool)
+
+	for i, name := range header {
+		// Spreadsheets may start CSV files with a byte order mark
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+
+		for j := range columns {
+			if !strings.EqualFold(name, columns[j].name) {
+				continue
+			}
+
+			if seen[columns[j].name] {
+				return nil, fmt.Errorf("header has column %s more than once", columns[j].name)
+			}
+
+			seen[columns[j].name] = true
+			fields[i] = &columns[j]
+		}
+	}
+
+	if !seen[sampleIDColumn] {
+		return nil, fmt.Errorf("header has no %s column", sampleIDColumn)
+	}
+
+	return fields, nil
+}
+
+// Read returns the next sample, or io.EOF after the last one. In strict mode,
+// rows with problems are skipped, and once all rows are read ParseErrors
+// listing every problem is returned instead of io.EOF.
+func (t *DelimitedReader) Read() (*TrackedSample, error) {
+	for {
+		record, err := t.reader.Read()
+		if errors.Is(err, io.EOF) && len(t.errs) > 0 {
+			return nil, t.errs
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		sample, errs := t.parse(record)
+		if len(errs) == 0 || !t.strict {
+			return sample, nil
+		}
+
+		t.errs = append(t.errs, errs...)
+	}
+}
+
+// parse converts record to a sample, returning the problems found.
+func (t *DelimitedReader) parse(record []string) (*TrackedSample, []*CellError) {
+	sample := &TrackedSample{}
+	line, _ := t.reader.FieldPos(0)
+
+	var errs []*CellError
+
+	for i, col := range t.fields {
+		if col == nil {
+			continue
+		}
+
+		if i >= len(record) {
+			errs = append(errs, &CellError{Line: line, Column: i + 1, Header: t.header[i],
+				Err: fmt.Errorf("missing, the row has only %d columns", len(record))})
+
+			continue
+		}
+
+		if err := col.set(sample, record[i]); err != nil {
+			errs = append(errs, &CellError{Line: line, Column: i + 1, Header: t.header[i], Err: err})
+		}
+	}
+
+	return sample, errs
+}
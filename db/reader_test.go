@@ -0,0 +1,142 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+)
+
+// readAll reads every sample from tsv, returning those read before any error.
+func readAll(tsv string, strict bool) ([]*db.TrackedSample, error) {
+	reader, err := db.NewTSVReader(strings.NewReader(tsv), strict)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []*db.TrackedSample
+
+	for {
+		sample, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return samples, nil
+		}
+
+		if err != nil {
+			return samples, err
+		}
+
+		samples = append(samples, sample)
+	}
+}
+
+func TestTSVReader(t *testing.T) {
+	Convey("Columns should be matched by header name, in any order", t, func() {
+		samples, err := readAll("Extra\tlibrarytime\tSangerSampleID\tPlate/Tube\tOrderMade\n"+
+			"x\t3\tS1\tP1\t2025-01-01T00:00:00Z\n", true)
+		So(err, ShouldBeNil)
+		So(len(samples), ShouldEqual, 1)
+		So(samples[0].SangerSampleID, ShouldEqual, "S1")
+		So(samples[0].LabwareHumanBarcode, ShouldEqual, "P1")
+		So(*samples[0].LibraryTime, ShouldEqual, 3)
+		So(samples[0].OrderMade.Year(), ShouldEqual, 2025)
+		So(samples[0].StudyID, ShouldEqual, "")
+	})
+
+	Convey("A header without SangerSampleID should be rejected", t, func() {
+		_, err := readAll("StudyID\tRunID\n1\tR\n", false)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "SangerSampleID")
+	})
+
+	Convey("A header with a column twice should be rejected", t, func() {
+		_, err := readAll("SangerSampleID\tRunID\trunid\nS1\tR\tR\n", false)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Given a TSV with malformed values and a short row", t, func() {
+		tsv := "SangerSampleID\tOrderMade\tLibraryTime\tRunID\n" +
+			"S1\t2025-01-01T00:00:00Z\t1\tR1\n" +
+			"S2\tyesterday\tfive\tR2\n" +
+			"S3\t\t\tR3\n" +
+			"S4\t2025-01-01\n"
+
+		Convey("Lenient reading should leave the bad fields unset", func() {
+			samples, err := readAll(tsv, false)
+			So(err, ShouldBeNil)
+			So(len(samples), ShouldEqual, 4)
+			So(samples[1].OrderMade, ShouldBeNil)
+			So(samples[1].LibraryTime, ShouldBeNil)
+			So(samples[1].RunID, ShouldEqual, "R2")
+			So(samples[3].RunID, ShouldEqual, "")
+		})
+
+		Convey("Strict reading should report every problem with its position", func() {
+			samples, err := readAll(tsv, true)
+			So(len(samples), ShouldEqual, 2)
+
+			var parseErrs db.ParseErrors
+			So(errors.As(err, &parseErrs), ShouldBeTrue)
+			So(len(parseErrs), ShouldEqual, 5)
+			So(parseErrs[0].Line, ShouldEqual, 3)
+			So(parseErrs[0].Column, ShouldEqual, 2)
+			So(parseErrs[0].Header, ShouldEqual, "OrderMade")
+			So(parseErrs[1].Error(), ShouldEqual, `line 3, column 3 (LibraryTime): invalid whole number "five"`)
+			So(parseErrs[2].Line, ShouldEqual, 5)
+			So(parseErrs[2].Header, ShouldEqual, "OrderMade")
+			So(parseErrs[3].Header, ShouldEqual, "LibraryTime")
+			So(parseErrs[3].Error(), ShouldContainSubstring, "missing, the row has only 2 columns")
+			So(parseErrs[4].Header, ShouldEqual, "RunID")
+			So(err.Error(), ShouldStartWith, "5 malformed values:\nline 3, column 2 (OrderMade)")
+		})
+	})
+
+	Convey("A strict mock provider should fail on malformed values", t, func() {
+		path := filepath.Join(t.TempDir(), "bad.tsv")
+		So(os.WriteFile(path, []byte("SangerSampleID\tSequencingTime\nS1\t1.5\n"), 0600), ShouldBeNil)
+
+		provider, err := db.New(db.WithMockData(path), db.WithStrictParsing())
+		So(err, ShouldBeNil)
+
+		_, err = provider.Execute()
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "line 2, column 2 (SequencingTime)")
+
+		provider, err = db.New(db.WithMockData(path))
+		So(err, ShouldBeNil)
+
+		samples, err := provider.Execute()
+		So(err, ShouldBeNil)
+		So(samples.Samples[0].SequencingTime, ShouldBeNil)
+	})
+}
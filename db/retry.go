@@ -0,0 +1,335 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ErrCircuitOpen is returned without querying the database while the circuit
+// breaker is open, after repeated transient failures.
+var ErrCircuitOpen = errors.New("database circuit breaker is open")
+
+// transientMySQLErrors are the MySQL server error numbers that are worth
+// retrying: too many connections, server shutdown, lock wait timeout and
+// deadlock.
+var transientMySQLErrors = map[uint16]bool{
+	1040: true,
+	1053: true,
+	1205: true,
+	1213: true,
+}
+
+// RetryPolicy says how queries that fail with transient errors are retried,
+// and when to stop querying a database that keeps failing.
+type RetryPolicy struct {
+	// MaxAttempts is how many times a query is tried in total (0 or 1 for no
+	// retries).
+	MaxAttempts int `yaml:"max_attempts"`
+
+	// InitialBackoff is the wait before the first retry, doubling for each
+	// retry after that, with random jitter of up to half the wait taken off.
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+
+	// MaxBackoff caps the wait between retries.
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+
+	// BreakerThreshold is how many queries in a row must fail with transient
+	// errors, after retries, to open the circuit breaker (0 for no breaker).
+	BreakerThreshold int `yaml:"breaker_threshold"`
+
+	// BreakerCooldown is how long the breaker stays open, failing queries with
+	// ErrCircuitOpen, before a single trial query is let through. The breaker
+	// closes again if that succeeds, or stays open for another cooldown if
+	// not.
+	BreakerCooldown time.Duration `yaml:"breaker_cooldown"`
+}
+
+// DefaultRetryPolicy returns the RetryPolicy used where none is configured.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:      4,
+		InitialBackoff:   500 * time.Millisecond,
+		MaxBackoff:       10 * time.Second,
+		BreakerThreshold: 3,
+		BreakerCooldown:  time.Minute,
+	}
+}
+
+// enabled returns true if the policy retries or breaks the circuit.
+func (r RetryPolicy) enabled() bool {
+	return r.MaxAttempts > 1 || r.BreakerThreshold > 0
+}
+
+// backoff returns how long to wait before the given retry, counting from 1.
+func (r RetryPolicy) backoff(retry int) time.Duration {
+	wait := r.InitialBackoff
+
+	for i := 1; i < retry && (r.MaxBackoff <= 0 || wait < r.MaxBackoff); i++ {
+		wait *= 2
+	}
+
+	if r.MaxBackoff > 0 && wait > r.MaxBackoff {
+		wait = r.MaxBackoff
+	}
+
+	if wait <= 0 {
+		return 0
+	}
+
+	return wait - rand.N(wait/2+1)
+}
+
+// IsTransient returns true if err is a database error that might not happen
+// if the query were tried again shortly, such as a broken connection, the
+// server having too many connections, or a lock wait timeout.
+func IsTransient(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return transientMySQLErrors[mysqlErr.Number]
+	}
+
+	var netErr *net.OpError
+
+	return errors.As(err, &netErr)
+}
+
+// retryingProvider is a MySQLQueryProvider that retries queries that fail
+// with transient errors, and stops querying for a while after repeated
+// failures.
+type retryingProvider struct {
+	*MySQLQueryProvider
+	policy  RetryPolicy
+	breaker *circuitBreaker
+}
+
+// newRetryingProvider wraps provider to follow policy.
+func newRetryingProvider(provider *MySQLQueryProvider, policy RetryPolicy) *retryingProvider {
+	return &retryingProvider{
+		MySQLQueryProvider: provider,
+		policy:             policy,
+		breaker:            &circuitBreaker{threshold: policy.BreakerThreshold, cooldown: policy.BreakerCooldown},
+	}
+}
+
+// Execute executes the SQL query, retrying as our policy says.
+func (r *retryingProvider) Execute() (*TrackedSampleCollection, error) {
+	return r.ExecuteContext(context.Background())
+}
+
+// ExecuteContext executes the SQL query, retrying as our policy says.
+func (r *retryingProvider) ExecuteContext(ctx context.Context) (*TrackedSampleCollection, error) {
+	var samples *TrackedSampleCollection
+
+	err := r.do(ctx, func() error {
+		var err error
+
+		samples, err = r.MySQLQueryProvider.ExecuteContext(ctx)
+
+		return err
+	})
+
+	return samples, err
+}
+
+// ExecuteSince executes the SQL query restricted to samples that reached a
+// milestone at or after watermark, retrying as our policy says.
+func (r *retryingProvider) ExecuteSince(ctx context.Context,
+	watermark time.Time) (*TrackedSampleCollection, error) {
+	var samples *TrackedSampleCollection
+
+	err := r.do(ctx, func() error {
+		var err error
+
+		samples, err = r.MySQLQueryProvider.ExecuteSince(ctx, watermark)
+
+		return err
+	})
+
+	return samples, err
+}
+
+// Stream executes the SQL query and calls fn with each sample. The query is
+// only retried if it failed before any sample was passed to fn, so that fn
+// never sees a sample twice.
+func (r *retryingProvider) Stream(ctx context.Context, fn SampleHandler) error {
+	started := false
+
+	return r.do(ctx, func() error {
+		err := r.MySQLQueryProvider.Stream(ctx, func(s *TrackedSample) error {
+			started = true
+
+			return fn(s)
+		})
+
+		if started && err != nil {
+			return &permanentError{err: err}
+		}
+
+		return err
+	})
+}
+
+// AddDetails runs the detail query, adding its metrics to samples. Samples are
+// only changed once all the metrics have been read, so it is safe to retry.
+func (r *retryingProvider) AddDetails(ctx context.Context, samples []TrackedSample) error {
+	return r.do(ctx, func() error {
+		return r.MySQLQueryProvider.AddDetails(ctx, samples)
+	})
+}
+
+// permanentError marks an error that must not be retried, even if it is
+// transient.
+type permanentError struct {
+	err error
+}
+
+// Error returns the marked error's message.
+func (p *permanentError) Error() string {
+	return p.err.Error()
+}
+
+// Unwrap returns the marked error.
+func (p *permanentError) Unwrap() error {
+	return p.err
+}
+
+// do calls query until it succeeds, fails with an error that isn't transient,
+// has been tried as many times as our policy allows, or ctx is done. It fails
+// with ErrCircuitOpen without calling query if the breaker is open. If ctx is
+// done while waiting to retry, it returns ctx's error wrapping the last one.
+func (r *retryingProvider) do(ctx context.Context, query func() error) error {
+	if err := r.breaker.allow(); err != nil {
+		return err
+	}
+
+	err := query()
+
+	for retry := 1; retry < r.policy.MaxAttempts && retryable(err); retry++ {
+		if waitErr := sleep(ctx, r.policy.backoff(retry)); waitErr != nil {
+			err = fmt.Errorf("%w while waiting to retry after: %w", waitErr, err)
+
+			break
+		}
+
+		err = query()
+	}
+
+	r.breaker.record(ctx, err)
+
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return permanent.err
+	}
+
+	return err
+}
+
+// retryable returns true if err is transient and not marked permanent.
+func retryable(err error) bool {
+	var permanent *permanentError
+
+	return IsTransient(err) && !errors.As(err, &permanent)
+}
+
+// sleep waits for d, returning ctx's error if it is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// circuitBreaker counts queries that fail in a row with transient errors, and
+// once there are threshold of them, stops queries for cooldown before letting
+// a trial query through.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trialling bool
+}
+
+// allow returns ErrCircuitOpen if a query shouldn't be made now.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return nil
+	}
+
+	if b.trialling || time.Now().Before(b.openUntil) {
+		return fmt.Errorf("%w until %s after %d failed queries",
+			ErrCircuitOpen, b.openUntil.Format(time.RFC3339), b.failures)
+	}
+
+	b.trialling = true
+
+	return nil
+}
+
+// record records the result of an allowed query. Cancelled queries say
+// nothing about the database, so don't count either way.
+func (b *circuitBreaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trialling = false
+
+	switch {
+	case ctx.Err() != nil:
+	case IsTransient(err):
+		b.failures++
+
+		if b.threshold > 0 && b.failures >= b.threshold {
+			b.openUntil = time.Now().Add(b.cooldown)
+		}
+	default:
+		b.failures = 0
+	}
+}
@@ -0,0 +1,198 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+)
+
+func TestRetries(t *testing.T) {
+	Convey("Given a MySQL provider that retries, with a sqlmock database", t, func() {
+		mockDB, mock, err := sqlmock.New()
+		So(err, ShouldBeNil)
+		defer mockDB.Close()
+
+		policy := db.RetryPolicy{
+			MaxAttempts:      3,
+			InitialBackoff:   time.Millisecond,
+			MaxBackoff:       5 * time.Millisecond,
+			BreakerThreshold: 2,
+			BreakerCooldown:  time.Hour,
+		}
+
+		newProvider := func() db.QueryProvider {
+			provider, errn := db.New(db.WithMySQLConnector(&mockConnector{mockDB: mockDB}), db.WithRetries(policy))
+			So(errn, ShouldBeNil)
+
+			return provider
+		}
+
+		lockTimeout := &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}
+		tooMany := &mysql.MySQLError{Number: 1040, Message: "Too many connections"}
+
+		Convey("A query that fails transiently is retried until it succeeds", func() {
+			mock.ExpectQuery("SELECT").WillReturnError(lockTimeout)
+			mock.ExpectQuery("SELECT").WillReturnError(tooMany)
+			mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(sampleColumns).AddRow(sampleRow("SANG1")...))
+
+			samples, err := newProvider().Execute()
+			So(err, ShouldBeNil)
+			So(len(samples.Samples), ShouldEqual, 1)
+			So(mock.ExpectationsWereMet(), ShouldBeNil)
+		})
+
+		Convey("A query that keeps failing transiently is given up on after the max attempts", func() {
+			for range policy.MaxAttempts {
+				mock.ExpectQuery("SELECT").WillReturnError(tooMany)
+			}
+
+			_, err := newProvider().Execute()
+			So(errors.Is(err, tooMany), ShouldBeTrue)
+			So(mock.ExpectationsWereMet(), ShouldBeNil)
+		})
+
+		Convey("A query that fails with a permanent error isn't retried", func() {
+			syntax := &mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}
+			mock.ExpectQuery("SELECT").WillReturnError(syntax)
+
+			_, err := newProvider().Execute()
+			So(errors.Is(err, syntax), ShouldBeTrue)
+			So(mock.ExpectationsWereMet(), ShouldBeNil)
+		})
+
+		Convey("A query cancelled while waiting to retry fails with the context's error", func() {
+			policy.InitialBackoff = time.Hour
+			policy.MaxBackoff = time.Hour
+			provider := newProvider().(db.StreamingQueryProvider)
+
+			for range policy.BreakerThreshold {
+				mock.ExpectQuery("SELECT").WillReturnError(tooMany)
+
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(10*time.Millisecond, cancel)
+
+				err := provider.Stream(ctx, func(*db.TrackedSample) error { return nil })
+				So(errors.Is(err, context.Canceled), ShouldBeTrue)
+				So(errors.Is(err, tooMany), ShouldBeTrue)
+			}
+
+			mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(sampleColumns).AddRow(sampleRow("SANG1")...))
+
+			err := provider.Stream(context.Background(), func(*db.TrackedSample) error { return nil })
+			So(err, ShouldBeNil)
+			So(mock.ExpectationsWereMet(), ShouldBeNil)
+		})
+
+		Convey("A stream that fails after passing on samples isn't retried", func() {
+			mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(sampleColumns).
+				AddRow(sampleRow("SANG1")...).
+				AddRow(sampleRow("SANG2")...).
+				RowError(1, lockTimeout))
+
+			var ids []string
+			err := newProvider().(db.StreamingQueryProvider).Stream(context.Background(),
+				func(s *db.TrackedSample) error {
+					ids = append(ids, s.SangerSampleID)
+
+					return nil
+				})
+
+			So(errors.Is(err, lockTimeout), ShouldBeTrue)
+			So(ids, ShouldResemble, []string{"SANG1"})
+			So(mock.ExpectationsWereMet(), ShouldBeNil)
+		})
+
+		Convey("Repeated failures open the circuit breaker, which stops queries", func() {
+			policy.MaxAttempts = 1
+			provider := newProvider()
+
+			for range policy.BreakerThreshold {
+				mock.ExpectQuery("SELECT").WillReturnError(tooMany)
+
+				_, err := provider.Execute()
+				So(errors.Is(err, tooMany), ShouldBeTrue)
+			}
+
+			_, err := provider.Execute()
+			So(errors.Is(err, db.ErrCircuitOpen), ShouldBeTrue)
+			So(mock.ExpectationsWereMet(), ShouldBeNil)
+
+			Convey("After the cooldown a successful trial query closes it again", func() {
+				policy.BreakerCooldown = 10 * time.Millisecond
+				provider := newProvider()
+
+				for range policy.BreakerThreshold {
+					mock.ExpectQuery("SELECT").WillReturnError(tooMany)
+					provider.Execute()
+				}
+
+				_, err := provider.Execute()
+				So(errors.Is(err, db.ErrCircuitOpen), ShouldBeTrue)
+
+				time.Sleep(2 * policy.BreakerCooldown)
+
+				mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(sampleColumns).AddRow(sampleRow("SANG1")...))
+				mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(sampleColumns).AddRow(sampleRow("SANG1")...))
+
+				_, err = provider.Execute()
+				So(err, ShouldBeNil)
+
+				_, err = provider.Execute()
+				So(err, ShouldBeNil)
+				So(mock.ExpectationsWereMet(), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestIsTransient(t *testing.T) {
+	Convey("Connection problems, too many connections, lock timeouts and deadlocks are transient", t, func() {
+		So(db.IsTransient(driver.ErrBadConn), ShouldBeTrue)
+		So(db.IsTransient(fmt.Errorf("query execution error: %w", mysql.ErrInvalidConn)), ShouldBeTrue)
+		So(db.IsTransient(&mysql.MySQLError{Number: 1040}), ShouldBeTrue)
+		So(db.IsTransient(&mysql.MySQLError{Number: 1205}), ShouldBeTrue)
+		So(db.IsTransient(&mysql.MySQLError{Number: 1213}), ShouldBeTrue)
+		So(db.IsTransient(&net.OpError{Op: "dial", Err: errors.New("connection refused")}), ShouldBeTrue)
+	})
+
+	Convey("Other errors are not transient", t, func() {
+		So(db.IsTransient(nil), ShouldBeFalse)
+		So(db.IsTransient(&mysql.MySQLError{Number: 1064}), ShouldBeFalse)
+		So(db.IsTransient(context.Canceled), ShouldBeFalse)
+		So(db.IsTransient(errors.New("error scanning row")), ShouldBeFalse)
+	})
+}
@@ -0,0 +1,251 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Run is one sequencing run of a Sample: a lane, PacBio well or ONT flowcell.
+type Run struct {
+	RunID                string
+	Platform             string
+	Pipeline             string
+	SequencingRunStart   *time.Time
+	SequencingQCComplete *time.Time
+	SequencingTime       *int // DATEDIFF result
+	QCPass               string
+}
+
+// Sample is a Sanger sample in a study, with its sample-level milestones held
+// once and a Run for each of the runs it was sequenced in.
+type Sample struct {
+	StudyID             string
+	StudyName           string
+	FacultySponsor      string
+	Programme           string
+	SangerSampleID      string
+	SupplierName        string
+	ManifestCreated     *time.Time
+	ManifestUploaded    *time.Time
+	LabwareReceived     *time.Time
+	LabwareHumanBarcode string // "Plate/Tube"
+	OrderMade           *time.Time
+	LibraryStart        *time.Time
+	LibraryComplete     *time.Time
+	LibraryTime         *int // DATEDIFF result
+	Runs                []Run
+}
+
+// sampleGroupKey identifies the rows that belong to one Sample.
+type sampleGroupKey struct {
+	studyID        string
+	sangerSampleID string
+}
+
+// GroupSamples groups per-run rows into Samples, one per Sanger sample in each
+// study, in the order each sample first appears. Sample-level fields are taken
+// from the first row that has them. Rows without a RunID only add a Run, with
+// an empty RunID, if they have sequencing times, so that those aren't lost.
+func GroupSamples(rows []TrackedSample) []Sample {
+	var samples []Sample
+
+	index := make(map[sampleGroupKey]int)
+
+	for i := range rows {
+		key := sampleGroupKey{studyID: rows[i].StudyID, sangerSampleID: rows[i].SangerSampleID}
+
+		j, ok := index[key]
+		if !ok {
+			j = len(samples)
+			index[key] = j
+			samples = append(samples, Sample{})
+		}
+
+		samples[j].add(&rows[i])
+	}
+
+	return samples
+}
+
+// add merges the given row into the sample.
+func (s *Sample) add(row *TrackedSample) {
+	fillString(&s.StudyID, row.StudyID)
+	fillString(&s.StudyName, row.StudyName)
+	fillString(&s.FacultySponsor, row.FacultySponsor)
+	fillString(&s.Programme, row.Programme)
+	fillString(&s.SangerSampleID, row.SangerSampleID)
+	fillString(&s.SupplierName, row.SupplierName)
+	fillString(&s.LabwareHumanBarcode, row.LabwareHumanBarcode)
+	fillTime(&s.ManifestCreated, row.ManifestCreated)
+	fillTime(&s.ManifestUploaded, row.ManifestUploaded)
+	fillTime(&s.LabwareReceived, row.LabwareReceived)
+	fillTime(&s.OrderMade, row.OrderMade)
+	fillTime(&s.LibraryStart, row.LibraryStart)
+	fillTime(&s.LibraryComplete, row.LibraryComplete)
+
+	if s.LibraryTime == nil {
+		s.LibraryTime = row.LibraryTime
+	}
+
+	if row.RunID != "" || hasSequencing(row) {
+		s.Runs = append(s.Runs, Run{
+			RunID:                row.RunID,
+			Platform:             row.Platform,
+			Pipeline:             row.Pipeline,
+			SequencingRunStart:   row.SequencingRunStart,
+			SequencingQCComplete: row.SequencingQCComplete,
+			SequencingTime:       row.SequencingTime,
+			QCPass:               row.QCPass,
+		})
+	}
+}
+
+// hasSequencing returns true if the row has any sequencing times.
+func hasSequencing(row *TrackedSample) bool {
+	return row.SequencingRunStart != nil || row.SequencingQCComplete != nil || row.SequencingTime != nil
+}
+
+// fillString sets *dst to src if *dst is empty.
+func fillString(dst *string, src string) {
+	if *dst == "" {
+		*dst = src
+	}
+}
+
+// fillTime sets *dst to src if *dst is nil.
+func fillTime(dst **time.Time, src *time.Time) {
+	if *dst == nil {
+		*dst = src
+	}
+}
+
+// Summary returns the whole sample as a single TrackedSample. Sequencing
+// starts at its earliest run start and its QC is complete at its latest QC
+// complete, once every run has one. SequencingTime is that of its slowest run.
+// Runs without a RunID are only used for these if there are no others.
+// RunID, Platform, Pipeline and QCPass list the distinct values of its runs,
+// separated by ", ".
+func (s *Sample) Summary() TrackedSample {
+	summary := TrackedSample{
+		StudyID:             s.StudyID,
+		StudyName:           s.StudyName,
+		FacultySponsor:      s.FacultySponsor,
+		Programme:           s.Programme,
+		SangerSampleID:      s.SangerSampleID,
+		SupplierName:        s.SupplierName,
+		ManifestCreated:     s.ManifestCreated,
+		ManifestUploaded:    s.ManifestUploaded,
+		LabwareReceived:     s.LabwareReceived,
+		LabwareHumanBarcode: s.LabwareHumanBarcode,
+		OrderMade:           s.OrderMade,
+		LibraryStart:        s.LibraryStart,
+		LibraryComplete:     s.LibraryComplete,
+		LibraryTime:         s.LibraryTime,
+		RunID:               s.joinRuns(func(r *Run) string { return r.RunID }),
+		Platform:            s.joinRuns(func(r *Run) string { return r.Platform }),
+		Pipeline:            s.joinRuns(func(r *Run) string { return r.Pipeline }),
+		QCPass:              s.joinRuns(func(r *Run) string { return r.QCPass }),
+	}
+
+	s.summariseSequencing(&summary)
+
+	return summary
+}
+
+// joinRuns returns the distinct non-empty values of field for our runs, in
+// order, separated by ", ".
+func (s *Sample) joinRuns(field func(*Run) string) string {
+	var values []string
+
+	for i := range s.Runs {
+		if v := field(&s.Runs[i]); v != "" && !slices.Contains(values, v) {
+			values = append(values, v)
+		}
+	}
+
+	return strings.Join(values, ", ")
+}
+
+// summariseSequencing sets the sequencing times of summary from our runs with
+// a RunID, or from our runs without one if there are none.
+func (s *Sample) summariseSequencing(summary *TrackedSample) {
+	runs := s.identifiedRuns()
+	if len(runs) == 0 {
+		runs = s.Runs
+	}
+
+	allQCd := len(runs) > 0
+
+	for i := range runs {
+		run := &runs[i]
+
+		if run.SequencingRunStart != nil &&
+			(summary.SequencingRunStart == nil || run.SequencingRunStart.Before(*summary.SequencingRunStart)) {
+			summary.SequencingRunStart = run.SequencingRunStart
+		}
+
+		if run.SequencingQCComplete == nil {
+			allQCd = false
+		} else if summary.SequencingQCComplete == nil || run.SequencingQCComplete.After(*summary.SequencingQCComplete) {
+			summary.SequencingQCComplete = run.SequencingQCComplete
+		}
+
+		if run.SequencingTime != nil && (summary.SequencingTime == nil || *run.SequencingTime > *summary.SequencingTime) {
+			summary.SequencingTime = run.SequencingTime
+		}
+	}
+
+	if !allQCd {
+		summary.SequencingQCComplete = nil
+	}
+}
+
+// identifiedRuns returns our runs that have a RunID.
+func (s *Sample) identifiedRuns() []Run {
+	var runs []Run
+
+	for _, run := range s.Runs {
+		if run.RunID != "" {
+			runs = append(runs, run)
+		}
+	}
+
+	return runs
+}
+
+// Summaries returns the Summary of each of the given samples.
+func Summaries(samples []Sample) []TrackedSample {
+	summaries := make([]TrackedSample, len(samples))
+
+	for i := range samples {
+		summaries[i] = samples[i].Summary()
+	}
+
+	return summaries
+}
@@ -0,0 +1,135 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+)
+
+func TestGroupSamples(t *testing.T) {
+	Convey("Given per-run rows for two samples", t, func() {
+		day1 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		day2 := day1.AddDate(0, 0, 1)
+		day5 := day1.AddDate(0, 0, 4)
+		two, four := 2, 4
+
+		rows := []db.TrackedSample{
+			{StudyID: "1", SangerSampleID: "A", OrderMade: &day1, RunID: "R1", Platform: "Illumina",
+				SequencingRunStart: &day2, SequencingQCComplete: &day5, SequencingTime: &four, QCPass: "1"},
+			{StudyID: "1", SangerSampleID: "B", OrderMade: &day1},
+			{StudyID: "1", SangerSampleID: "A", SupplierName: "supA", RunID: "R2", Platform: "Illumina",
+				SequencingRunStart: &day1, SequencingTime: &two},
+			{StudyID: "1", SangerSampleID: "A", RunID: "R3", Platform: "PacBio",
+				SequencingRunStart: &day2, SequencingQCComplete: &day2, QCPass: "1"},
+		}
+
+		samples := db.GroupSamples(rows)
+
+		Convey("Each sample should appear once, in order, with its runs nested", func() {
+			So(len(samples), ShouldEqual, 2)
+			So(samples[0].SangerSampleID, ShouldEqual, "A")
+			So(samples[0].SupplierName, ShouldEqual, "supA")
+			So(samples[0].OrderMade, ShouldEqual, &day1)
+			So(len(samples[0].Runs), ShouldEqual, 3)
+			So(samples[0].Runs[1].RunID, ShouldEqual, "R2")
+			So(samples[0].Runs[2].Platform, ShouldEqual, "PacBio")
+			So(samples[1].SangerSampleID, ShouldEqual, "B")
+			So(samples[1].Runs, ShouldBeEmpty)
+		})
+
+		Convey("A sample's summary should combine its runs", func() {
+			summary := samples[0].Summary()
+			So(summary.RunID, ShouldEqual, "R1, R2, R3")
+			So(summary.Platform, ShouldEqual, "Illumina, PacBio")
+			So(summary.QCPass, ShouldEqual, "1")
+			So(summary.SequencingRunStart, ShouldEqual, &day1)
+			So(*summary.SequencingTime, ShouldEqual, 4)
+			So(summary.SequencingQCComplete, ShouldBeNil)
+			So(summary.Stage(), ShouldEqual, db.StageSequencing)
+		})
+
+		Convey("A sample's QC is complete once all its runs' are", func() {
+			rows[2].SequencingQCComplete = &day2
+
+			summary := db.GroupSamples(rows)[0].Summary()
+			So(summary.SequencingQCComplete, ShouldEqual, &day5)
+			So(summary.Stage(), ShouldEqual, db.StageQCComplete)
+		})
+
+		Convey("Summaries should summarise every sample", func() {
+			summaries := db.Summaries(samples)
+			So(len(summaries), ShouldEqual, 2)
+			So(summaries[1].RunID, ShouldEqual, "")
+			So(summaries[1].Stage(), ShouldEqual, db.StageOrdered)
+		})
+	})
+
+	Convey("A sample with sequencing times but no RunID keeps them", t, func() {
+		day1 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		day3 := day1.AddDate(0, 0, 2)
+		two := 2
+
+		rows := []db.TrackedSample{
+			{StudyID: "1", SangerSampleID: "A", OrderMade: &day1, LibraryComplete: &day1,
+				SequencingRunStart: &day1, SequencingQCComplete: &day3, SequencingTime: &two},
+		}
+
+		samples := db.GroupSamples(rows)
+		So(len(samples[0].Runs), ShouldEqual, 1)
+		So(samples[0].Runs[0].RunID, ShouldEqual, "")
+
+		summary := samples[0].Summary()
+		So(summary.RunID, ShouldEqual, "")
+		So(summary.SequencingRunStart, ShouldEqual, &day1)
+		So(summary.SequencingQCComplete, ShouldEqual, &day3)
+		So(*summary.SequencingTime, ShouldEqual, 2)
+		So(summary.Stage(), ShouldEqual, db.StageQCComplete)
+
+		Convey("unless it also has runs with a RunID", func() {
+			day2 := day1.AddDate(0, 0, 1)
+			rows = append(rows, db.TrackedSample{StudyID: "1", SangerSampleID: "A", RunID: "R1",
+				SequencingRunStart: &day2})
+
+			summary := db.GroupSamples(rows)[0].Summary()
+			So(summary.RunID, ShouldEqual, "R1")
+			So(summary.SequencingRunStart, ShouldEqual, &day2)
+			So(summary.SequencingQCComplete, ShouldBeNil)
+			So(summary.Stage(), ShouldEqual, db.StageSequencing)
+		})
+	})
+
+	Convey("The same sample in different studies should be kept apart", t, func() {
+		samples := db.GroupSamples([]db.TrackedSample{
+			{StudyID: "1", SangerSampleID: "A", RunID: "R1"},
+			{StudyID: "2", SangerSampleID: "A", RunID: "R1"},
+		})
+		So(len(samples), ShouldEqual, 2)
+	})
+}
@@ -0,0 +1,216 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// timeTypes are the database types that can be scanned into a time field.
+var timeTypes = map[string]bool{"DATETIME": true, "TIMESTAMP": true, "DATE": true}
+
+// resultColumns are the columns that query results can fill, keyed on their
+// normalised names and keys.
+var resultColumns = func() map[string]*column {
+	m := make(map[string]*column, 2*len(columns))
+
+	for i := range columns {
+		m[normaliseColumnName(columns[i].name)] = &columns[i]
+		m[normaliseColumnName(columns[i].key)] = &columns[i]
+	}
+
+	return m
+}()
+
+// normaliseColumnName returns name in lower case without anything that isn't
+// a letter or digit, so that a result column like study_id matches the
+// StudyID column, and Plate/Tube matches Plate/Tube or LabwareHumanBarcode.
+func normaliseColumnName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+
+		return -1
+	}, name)
+}
+
+// QueryShapeError is returned when the columns of a query's results can't be
+// made into samples.
+type QueryShapeError struct {
+	// Missing are the sample columns that no result column fills.
+	Missing []string
+
+	// Mismatched describes the result columns whose database type doesn't
+	// suit their sample column, or that fill a column already filled.
+	Mismatched []string
+}
+
+// Error lists the problems.
+func (e *QueryShapeError) Error() string {
+	var problems []string
+
+	if len(e.Missing) > 0 {
+		problems = append(problems, "missing columns "+strings.Join(e.Missing, ", "))
+	}
+
+	return "query results don't match the sample columns: " +
+		strings.Join(append(problems, e.Mismatched...), "; ")
+}
+
+// rowScanner scans rows of query results into samples, matching the result
+// columns to sample columns by name.
+type rowScanner struct {
+	// cols are the sample columns of each result column, nil for result
+	// columns that aren't needed.
+	cols []*column
+}
+
+// newRowScanner returns a rowScanner for rows, or a *QueryShapeError if a
+// sample column isn't in rows, or a result column's type doesn't suit its
+// sample column.
+func newRowScanner(rows *sql.Rows) (*rowScanner, error) {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("error reading result columns: %w", err)
+	}
+
+	r := &rowScanner{cols: make([]*column, len(types))}
+	shape := &QueryShapeError{}
+	filled := make(map[string]bool)
+
+	for i, ct := range types {
+		r.cols[i] = resultColumns[normaliseColumnName(ct.Name())]
+		shape.check(ct, r.cols[i], filled)
+	}
+
+	for _, col := range columns {
+		if !filled[col.name] {
+			shape.Missing = append(shape.Missing, col.name)
+		}
+	}
+
+	if len(shape.Missing) > 0 || len(shape.Mismatched) > 0 {
+		return nil, shape
+	}
+
+	return r, nil
+}
+
+// check records a problem if the result column ct can't fill col, or col is
+// already filled, then records col as filled.
+func (e *QueryShapeError) check(ct *sql.ColumnType, col *column, filled map[string]bool) {
+	if col == nil {
+		return
+	}
+
+	dbType := strings.ToUpper(ct.DatabaseTypeName())
+
+	switch {
+	case filled[col.name]:
+		e.Mismatched = append(e.Mismatched, fmt.Sprintf("%s is a second %s column", ct.Name(), col.name))
+	case !suitsKind(ct, dbType, col.kind):
+		e.Mismatched = append(e.Mismatched, fmt.Sprintf("%s is %s, but %s needs %s",
+			ct.Name(), dbType, col.name, kindDescriptions[col.kind]))
+	}
+
+	filled[col.name] = true
+}
+
+// kindDescriptions describe the database types each kind of column needs.
+var kindDescriptions = map[columnKind]string{
+	stringKind: "any type",
+	timeKind:   "a date or time",
+	intKind:    "an integer",
+}
+
+// suitsKind returns true if the result column ct, of the given database type,
+// can be scanned into a column of the given kind. Unknown types are assumed
+// to.
+func suitsKind(ct *sql.ColumnType, dbType string, kind columnKind) bool {
+	if dbType == "" || dbType == "NULL" {
+		return true
+	}
+
+	switch kind {
+	case timeKind:
+		return timeTypes[dbType]
+	case intKind:
+		return strings.Contains(dbType, "INT") || dbType == "YEAR" || (dbType == "DECIMAL" && isWhole(ct))
+	default:
+		return true
+	}
+}
+
+// isWhole returns true if the DECIMAL column ct is known to have no decimal
+// places, so always holds whole numbers.
+func isWhole(ct *sql.ColumnType) bool {
+	_, scale, ok := ct.DecimalSize()
+
+	return ok && scale == 0
+}
+
+// scan returns the sample in the current row.
+func (r *rowScanner) scan(rows *sql.Rows) (*TrackedSample, error) {
+	var s TrackedSample
+
+	dests := make([]any, len(r.cols))
+
+	for i, col := range r.cols {
+		if col == nil {
+			dests[i] = new(sql.RawBytes)
+		} else {
+			dests[i] = col.dest(&s)
+		}
+	}
+
+	if err := rows.Scan(dests...); err != nil {
+		return nil, fmt.Errorf("error scanning row: %w", err)
+	}
+
+	return &s, nil
+}
+
+// nullableString is a sql.Scanner that scans into a string, leaving it empty
+// for NULL.
+type nullableString struct {
+	s *string
+}
+
+// Scan implements sql.Scanner.
+func (n nullableString) Scan(value any) error {
+	var ns sql.NullString
+	if err := ns.Scan(value); err != nil {
+		return err
+	}
+
+	*n.s = ns.String
+
+	return nil
+}
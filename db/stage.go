@@ -0,0 +1,151 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Stage is the point a sample has reached in its lifecycle. Each stage starts
+// at the Milestone with the same value.
+type Stage int
+
+// The stages a sample passes through, in order.
+const (
+	StageUnknown Stage = iota - 1
+	StageManifestCreated
+	StageManifestUploaded
+	StageLabwareReceived
+	StageOrdered
+	StageLibraryInProgress
+	StageLibraryComplete
+	StageSequencing
+	StageQCComplete
+)
+
+// stageNames are the names of each Stage, starting with StageManifestCreated.
+var stageNames = [...]string{
+	"manifest created",
+	"manifest uploaded",
+	"labware received",
+	"ordered",
+	"library in progress",
+	"library complete",
+	"sequencing",
+	"QC complete",
+}
+
+// hoursPerDay is used to convert durations to days.
+const hoursPerDay = 24
+
+// Stages returns every known Stage in order.
+func Stages() []Stage {
+	stages := make([]Stage, len(stageNames))
+	for i := range stages {
+		stages[i] = Stage(i)
+	}
+
+	return stages
+}
+
+// ParseStage returns the Stage with the given name, ignoring case.
+func ParseStage(name string) (Stage, error) {
+	for _, stage := range Stages() {
+		if strings.EqualFold(stage.String(), name) {
+			return stage, nil
+		}
+	}
+
+	return StageUnknown, fmt.Errorf("unknown stage %q", name)
+}
+
+// String returns the name of the stage.
+func (s Stage) String() string {
+	if s < 0 || int(s) >= len(stageNames) {
+		return "unknown"
+	}
+
+	return stageNames[s]
+}
+
+// Milestone returns the milestone that starts this stage.
+func (s Stage) Milestone() Milestone {
+	return Milestone(s)
+}
+
+// Stage returns the stage the sample is currently in, derived from the
+// furthest milestone it has reached.
+func (s *TrackedSample) Stage() Stage {
+	m, ok := s.LatestMilestone()
+	if !ok {
+		return StageUnknown
+	}
+
+	return Stage(m)
+}
+
+// TimeInStage returns how long the sample has been in its current stage as of
+// now, or false if its stage is unknown.
+func (s *TrackedSample) TimeInStage(now time.Time) (time.Duration, bool) {
+	stage := s.Stage()
+	if stage == StageUnknown {
+		return 0, false
+	}
+
+	return now.Sub(*stage.Milestone().Time(s)), true
+}
+
+// DaysInStage returns the whole days the sample has been in its current stage
+// as of now, or nil if its stage is unknown.
+func (s *TrackedSample) DaysInStage(now time.Time) *int {
+	d, ok := s.TimeInStage(now)
+	if !ok {
+		return nil
+	}
+
+	days := int(d.Hours() / hoursPerDay)
+
+	return &days
+}
+
+// MarshalJSON encodes the sample's fields along with its derived Stage. Its
+// DaysInStage is left out, since it depends on when the sample is encoded, so
+// that snapshots and diffs of the same samples encode the same way; exports
+// include it as of a time chosen by the caller.
+func (s TrackedSample) MarshalJSON() ([]byte, error) {
+	type fields TrackedSample
+
+	return json.Marshal(struct {
+		fields
+		Stage string
+	}{
+		fields: fields(s),
+		Stage:  s.Stage().String(),
+	})
+}
@@ -0,0 +1,93 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+)
+
+func TestStage(t *testing.T) {
+	Convey("Given samples at different points of their lifecycle", t, func() {
+		day1 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		day3 := day1.AddDate(0, 0, 2)
+		now := day3.Add(80 * time.Hour)
+
+		none := db.TrackedSample{}
+		ordered := db.TrackedSample{ManifestCreated: &day1, OrderMade: &day3}
+		done := db.TrackedSample{ManifestCreated: &day1, LibraryStart: &day1, SequencingQCComplete: &day3}
+
+		Convey("Their stage should follow their furthest milestone", func() {
+			So(none.Stage(), ShouldEqual, db.StageUnknown)
+			So(ordered.Stage(), ShouldEqual, db.StageOrdered)
+			So(done.Stage(), ShouldEqual, db.StageQCComplete)
+			So(ordered.Stage().String(), ShouldEqual, "ordered")
+			So(db.StageLibraryInProgress.String(), ShouldEqual, "library in progress")
+			So(none.Stage().String(), ShouldEqual, "unknown")
+		})
+
+		Convey("Their time in stage should be measured from entering it", func() {
+			d, ok := ordered.TimeInStage(now)
+			So(ok, ShouldBeTrue)
+			So(d, ShouldEqual, 80*time.Hour)
+			So(*ordered.DaysInStage(now), ShouldEqual, 3)
+
+			_, ok = none.TimeInStage(now)
+			So(ok, ShouldBeFalse)
+			So(none.DaysInStage(now), ShouldBeNil)
+		})
+
+		Convey("Their JSON should include the stage, but not the time-dependent days in it", func() {
+			data, err := json.Marshal(ordered)
+			So(err, ShouldBeNil)
+
+			var decoded map[string]any
+			So(json.Unmarshal(data, &decoded), ShouldBeNil)
+			So(decoded["Stage"], ShouldEqual, "ordered")
+			So(decoded, ShouldNotContainKey, "DaysInStage")
+			So(decoded["SangerSampleID"], ShouldEqual, "")
+
+			var roundTrip db.TrackedSample
+			So(json.Unmarshal(data, &roundTrip), ShouldBeNil)
+			So(roundTrip.OrderMade.Equal(day3), ShouldBeTrue)
+		})
+	})
+
+	Convey("Stages should parse from their names ignoring case", t, func() {
+		stage, err := db.ParseStage("Library In Progress")
+		So(err, ShouldBeNil)
+		So(stage, ShouldEqual, db.StageLibraryInProgress)
+
+		_, err = db.ParseStage("nonsense")
+		So(err, ShouldNotBeNil)
+
+		So(len(db.Stages()), ShouldEqual, len(db.Milestones()))
+	})
+}
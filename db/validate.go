@@ -0,0 +1,155 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ValidationRule is a named check for one kind of data problem in a sample.
+type ValidationRule struct {
+	Name        string
+	Description string
+
+	// Check returns a description of the problem, or false if the sample
+	// doesn't have it.
+	Check func(s *TrackedSample) (string, bool)
+}
+
+// Violation is a problem found in a sample by a ValidationRule.
+type Violation struct {
+	Rule    string    `json:"rule"`
+	Sample  SampleKey `json:"sample"`
+	StudyID string    `json:"studyId"`
+	Message string    `json:"message"`
+}
+
+// String describes the violation.
+func (v Violation) String() string {
+	return fmt.Sprintf("%s (run %s, study %s): %s: %s",
+		v.Sample.SangerSampleID, v.Sample.RunID, v.StudyID, v.Rule, v.Message)
+}
+
+// ValidationRules returns the built-in rules, which look for milestones
+// recorded out of order and negative times.
+func ValidationRules() []ValidationRule {
+	return []ValidationRule{
+		orderRule("manifest-uploaded-before-created", MilestoneManifestUploaded, MilestoneManifestCreated),
+		orderRule("labware-received-before-manifest", MilestoneLabwareReceived, MilestoneManifestCreated),
+		orderRule("library-started-before-order", MilestoneLibraryStart, MilestoneOrderMade),
+		orderRule("library-completed-before-start", MilestoneLibraryComplete, MilestoneLibraryStart),
+		orderRule("sequencing-started-before-library", MilestoneSequencingRunStart, MilestoneLibraryComplete),
+		orderRule("qc-completed-before-run-start", MilestoneSequencingQCComplete, MilestoneSequencingRunStart),
+		negativeRule("negative-library-time", "LibraryTime",
+			func(s *TrackedSample) *int { return s.LibraryTime }),
+		negativeRule("negative-sequencing-time", "SequencingTime",
+			func(s *TrackedSample) *int { return s.SequencingTime }),
+	}
+}
+
+// SelectValidationRules returns the built-in rules with the given names, or
+// all of them if no names are given.
+func SelectValidationRules(names ...string) ([]ValidationRule, error) {
+	all := ValidationRules()
+	if len(names) == 0 {
+		return all, nil
+	}
+
+	rules := make([]ValidationRule, 0, len(names))
+
+	for _, name := range names {
+		i := slices.IndexFunc(all, func(r ValidationRule) bool { return r.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown validation rule %q", name)
+		}
+
+		rules = append(rules, all[i])
+	}
+
+	return rules, nil
+}
+
+// orderRule returns a rule that a sample's later milestone must not be before
+// its earlier one, when both have been reached.
+func orderRule(name string, later, earlier Milestone) ValidationRule {
+	return ValidationRule{
+		Name:        name,
+		Description: fmt.Sprintf("%s must not be before %s", later, earlier),
+		Check: func(s *TrackedSample) (string, bool) {
+			l, e := later.Time(s), earlier.Time(s)
+			if l == nil || e == nil || !l.Before(*e) {
+				return "", false
+			}
+
+			return fmt.Sprintf("%s %s is before %s %s",
+				later, l.Format(time.RFC3339), earlier, e.Format(time.RFC3339)), true
+		},
+	}
+}
+
+// negativeRule returns a rule that the given field, when set, must not be
+// negative.
+func negativeRule(name, field string, value func(*TrackedSample) *int) ValidationRule {
+	return ValidationRule{
+		Name:        name,
+		Description: field + " must not be negative",
+		Check: func(s *TrackedSample) (string, bool) {
+			v := value(s)
+			if v == nil || *v >= 0 {
+				return "", false
+			}
+
+			return fmt.Sprintf("%s is %d", field, *v), true
+		},
+	}
+}
+
+// Validate checks every sample in the collection against the given rules, or
+// the built-in ones if none are given, returning the violations found in
+// sample order.
+func (sc *TrackedSampleCollection) Validate(rules ...ValidationRule) []Violation {
+	if len(rules) == 0 {
+		rules = ValidationRules()
+	}
+
+	var violations []Violation
+
+	for i := range sc.Samples {
+		s := &sc.Samples[i]
+
+		for _, rule := range rules {
+			if msg, bad := rule.Check(s); bad {
+				violations = append(violations, Violation{
+					Rule: rule.Name, Sample: s.Key(), StudyID: s.StudyID, Message: msg,
+				})
+			}
+		}
+	}
+
+	return violations
+}
@@ -0,0 +1,80 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+)
+
+func TestValidate(t *testing.T) {
+	Convey("Given samples with and without data problems", t, func() {
+		day1 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		day3 := day1.AddDate(0, 0, 2)
+		one, minusTwo := 1, -2
+
+		collection := &db.TrackedSampleCollection{Samples: []db.TrackedSample{
+			{SangerSampleID: "good", RunID: "1", ManifestCreated: &day1, LabwareReceived: &day3,
+				LibraryStart: &day1, LibraryComplete: &day3, LibraryTime: &one},
+			{SangerSampleID: "backwards", RunID: "2", StudyID: "9", LibraryStart: &day3, LibraryComplete: &day1},
+			{SangerSampleID: "early", RunID: "3", ManifestCreated: &day3, LabwareReceived: &day1,
+				SequencingTime: &minusTwo},
+		}}
+
+		Convey("Validating with the built-in rules should find every problem", func() {
+			violations := collection.Validate()
+			So(len(violations), ShouldEqual, 3)
+
+			So(violations[0].Rule, ShouldEqual, "library-completed-before-start")
+			So(violations[0].Sample, ShouldResemble, db.SampleKey{SangerSampleID: "backwards", RunID: "2"})
+			So(violations[0].StudyID, ShouldEqual, "9")
+			So(violations[0].Message, ShouldEqual,
+				"LibraryComplete 2025-01-01T00:00:00Z is before LibraryStart 2025-01-03T00:00:00Z")
+
+			So(violations[1].Rule, ShouldEqual, "labware-received-before-manifest")
+			So(violations[2].Rule, ShouldEqual, "negative-sequencing-time")
+			So(violations[2].String(), ShouldEqual,
+				"early (run 3, study ): negative-sequencing-time: SequencingTime is -2")
+		})
+
+		Convey("Validating with selected rules should only apply those", func() {
+			rules, err := db.SelectValidationRules("negative-sequencing-time")
+			So(err, ShouldBeNil)
+
+			violations := collection.Validate(rules...)
+			So(len(violations), ShouldEqual, 1)
+			So(violations[0].Sample.SangerSampleID, ShouldEqual, "early")
+		})
+
+		Convey("Selecting an unknown rule should fail", func() {
+			_, err := db.SelectValidationRules("nonsense")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
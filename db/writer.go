@@ -27,84 +27,140 @@ package db
 
 import (
 	"encoding/csv"
-	"os"
+	"io"
 	"strconv"
 	"time"
+
+	"github.com/wtsi-hgi/gst/output"
 )
 
-// ToTSV writes the collection of samples to a TSV file at the specified path.
+// tsvHeader names the data fields of a sample, in the order they are written
+// in TSV output.
+var tsvHeader = columnNames(columns)
+
+// derivedHeader names the fields derived from a sample's data, which are
+// written after its data fields.
+var derivedHeader = []string{
+	"Stage",
+	"DaysInStage",
+}
+
+// DelimitedWriter writes samples as TSV or CSV rows one at a time, so that
+// output can be produced while samples are still being read. Times are
+// written in RFC3339 format, and missing values as empty cells.
+type DelimitedWriter struct {
+	writer *csv.Writer
+	cols   []outputColumn
+	now    time.Time
+}
+
+// NewTSVWriter returns a DelimitedWriter that writes tab separated values to
+// w, having first written the header row. Times in stage are calculated as of
+// the writer's creation.
+func NewTSVWriter(w io.Writer) (*DelimitedWriter, error) {
+	return newDelimitedWriter(w, '\t', outputColumns)
+}
+
+// NewCSVWriter is like NewTSVWriter, but writes comma separated values. If bom
+// is true, a UTF-8 byte order mark is written first, so that Excel detects the
+// encoding.
+func NewCSVWriter(w io.Writer, bom bool) (*DelimitedWriter, error) {
+	return newCSVWriter(w, bom, outputColumns)
+}
+
+// newCSVWriter is like NewCSVWriter, but only writes the given columns.
+func newCSVWriter(w io.Writer, bom bool, cols []outputColumn) (*DelimitedWriter, error) {
+	if bom {
+		if _, err := io.WriteString(w, utf8BOM); err != nil {
+			return nil, err
+		}
+	}
+
+	return newDelimitedWriter(w, ',', cols)
+}
+
+// utf8BOM is the UTF-8 encoded byte order mark.
+const utf8BOM = "\ufeff"
+
+// newDelimitedWriter returns a DelimitedWriter that writes the given columns
+// separated by comma to w, having first written the header row.
+func newDelimitedWriter(w io.Writer, comma rune, cols []outputColumn) (*DelimitedWriter, error) {
+	writer := csv.NewWriter(w)
+	writer.Comma = comma
+
+	if err := writer.Write(outputHeader(cols)); err != nil {
+		return nil, err
+	}
+
+	return &DelimitedWriter{writer: writer, cols: cols, now: time.Now()}, nil
+}
+
+// Write writes the given sample as a row. Rows are buffered; call Flush once
+// all samples have been written.
+func (t *DelimitedWriter) Write(sample *TrackedSample) error {
+	values := outputValues(t.cols, sample, t.now)
+	record := make([]string, len(values))
+
+	for i, v := range values {
+		record[i] = formatValue(v)
+	}
+
+	return t.writer.Write(record)
+}
+
+// Flush writes any buffered rows to the underlying writer.
+func (t *DelimitedWriter) Flush() error {
+	t.writer.Flush()
+
+	return t.writer.Error()
+}
+
+// Close is the same as Flush; it does not close the underlying writer.
+func (t *DelimitedWriter) Close() error {
+	return t.Flush()
+}
+
+// ToTSV writes the collection of samples to a TSV file at the specified path,
+// replacing any existing file only once the new one is complete.
 func (sc *TrackedSampleCollection) ToTSV(path string) error {
-	f, err := os.Create(path)
+	f, err := output.Create(path, output.WithForce())
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-
-	writer := csv.NewWriter(f)
-	writer.Comma = '\t'
-	defer writer.Flush()
-
-	// Write header
-	header := []string{
-		"StudyID",
-		"StudyName",
-		"FacultySponsor",
-		"Programme",
-		"SangerSampleID",
-		"SupplierName",
-		"ManifestCreated",
-		"ManifestUploaded",
-		"LabwareReceived",
-		"Plate/Tube",
-		"OrderMade",
-		"LibraryStart",
-		"LibraryComplete",
-		"LibraryTime",
-		"RunID",
-		"Platform",
-		"Pipeline",
-		"SequencingRunStart",
-		"SequencingQCComplete",
-		"SequencingTime",
-		"QCPass",
-	}
 
-	if err := writer.Write(header); err != nil {
+	if err = sc.writeTSV(f); err != nil {
+		f.Abort()
+
 		return err
 	}
 
-	// Format and write each sample
-	for _, sample := range sc.Samples {
-		row := []string{
-			sample.StudyID,
-			sample.StudyName,
-			sample.FacultySponsor,
-			sample.Programme,
-			sample.SangerSampleID,
-			sample.SupplierName,
-			formatTimePointer(sample.ManifestCreated),
-			formatTimePointer(sample.ManifestUploaded),
-			formatTimePointer(sample.LabwareReceived),
-			sample.LabwareHumanBarcode,
-			formatTimePointer(sample.OrderMade),
-			formatTimePointer(sample.LibraryStart),
-			formatTimePointer(sample.LibraryComplete),
-			formatIntPointer(sample.LibraryTime),
-			sample.RunID,
-			sample.Platform,
-			sample.Pipeline,
-			formatTimePointer(sample.SequencingRunStart),
-			formatTimePointer(sample.SequencingQCComplete),
-			formatIntPointer(sample.SequencingTime),
-			sample.QCPass,
-		}
+	return f.Close()
+}
+
+// writeTSV writes the collection of samples to w as TSV.
+func (sc *TrackedSampleCollection) writeTSV(w io.Writer) error {
+	writer, err := NewTSVWriter(w)
+	if err != nil {
+		return err
+	}
 
-		if err := writer.Write(row); err != nil {
+	for i := range sc.Samples {
+		if err := writer.Write(&sc.Samples[i]); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return writer.Flush()
+}
+
+// sampleToRecord formats a sample's fields in tsvHeader order.
+func sampleToRecord(sample *TrackedSample) []string {
+	record := make([]string, len(columns))
+	for i, col := range columns {
+		record[i] = col.get(sample)
+	}
+
+	return record
 }
 
 // Helper functions to format pointers for output.
@@ -26,6 +26,7 @@
 package db_test
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"strings"
@@ -70,6 +71,28 @@ func TestWriter(t *testing.T) {
 
 		collection := db.TrackedSampleCollection{Samples: samples}
 
+		Convey("When streaming to a TSV writer", func() {
+			var buf bytes.Buffer
+			writer, err := db.NewTSVWriter(&buf)
+			So(err, ShouldBeNil)
+
+			for i := range samples {
+				So(writer.Write(&samples[i]), ShouldBeNil)
+			}
+
+			So(writer.Flush(), ShouldBeNil)
+
+			Convey("It should write the header then one line per sample", func() {
+				lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+				So(len(lines), ShouldEqual, 2)
+				So(lines[0], ShouldStartWith, "StudyID\tStudyName")
+				So(lines[1], ShouldStartWith, "1234\tTest Study")
+				So(lines[1], ShouldContainSubstring, "2025-01-01T12:00:00Z")
+				So(lines[0], ShouldEndWith, "QCPass\tStage\tDaysInStage")
+				So(lines[1], ShouldContainSubstring, "\t1\tQC complete\t")
+			})
+		})
+
 		Convey("When writing to a TSV file", func() {
 			tmpDir, err := os.MkdirTemp("", "gst_test")
 			So(err, ShouldBeNil)
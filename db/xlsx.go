@@ -0,0 +1,380 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// SheetGroup is how an XLSX export splits samples into extra sheets.
+type SheetGroup int
+
+// The ways an XLSX export can split samples into extra sheets.
+const (
+	SheetsNone SheetGroup = iota
+	SheetsPerStudy
+	SheetsPerSponsor
+)
+
+// sheetGroupNames are the names of each SheetGroup.
+var sheetGroupNames = [...]string{"", "study", "sponsor"}
+
+// ParseSheetGroup returns the SheetGroup with the given name: study, sponsor,
+// or empty for none.
+func ParseSheetGroup(name string) (SheetGroup, error) {
+	for i, n := range sheetGroupNames {
+		if strings.EqualFold(name, n) {
+			return SheetGroup(i), nil
+		}
+	}
+
+	return SheetsNone, fmt.Errorf("unknown sheet grouping %q, expected study or sponsor", name)
+}
+
+// WithSheets makes XLSX output have a summary sheet and a sheet of samples
+// per study or sponsor, after the sheet of all samples. It has no effect on
+// other formats.
+func WithSheets(group SheetGroup) WriterOption {
+	return func(c *writerConfig) {
+		c.sheets = group
+	}
+}
+
+const (
+	allSamplesSheet = "Samples"
+	summarySheet    = "Summary"
+	maxSheetName    = 31
+	maxColumnWidth  = 50
+	dateColumnWidth = 18
+	xlsxDateFormat  = "yyyy-mm-dd hh:mm"
+	noGroupLabel    = "(none)"
+)
+
+// summaryLabels are the headers of the group column in the summary sheet for
+// each SheetGroup.
+var summaryLabels = [...]string{"", "Study", "Sponsor"}
+
+// xlsxWriter collects samples and writes them as an Excel workbook on Close,
+// since a workbook can only be written whole.
+type xlsxWriter struct {
+	w       io.Writer
+	cols    []outputColumn
+	now     time.Time
+	sheets  SheetGroup
+	samples []*TrackedSample
+}
+
+// Write adds a copy of the sample to the workbook.
+func (x *xlsxWriter) Write(sample *TrackedSample) error {
+	s := *sample
+	x.samples = append(x.samples, &s)
+
+	return nil
+}
+
+// Close writes the workbook.
+func (x *xlsxWriter) Close() error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := x.build(f); err != nil {
+		return err
+	}
+
+	_, err := f.WriteTo(x.w)
+
+	return err
+}
+
+// build adds the sheets of samples, and the summary if grouping, to f.
+func (x *xlsxWriter) build(f *excelize.File) error {
+	dateStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &[]string{xlsxDateFormat}[0]})
+	if err != nil {
+		return err
+	}
+
+	headerStyle, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+
+	sheet := &xlsxSheet{file: f, cols: x.cols, dateStyle: dateStyle, headerStyle: headerStyle, now: x.now}
+
+	if err = f.SetSheetName(f.GetSheetName(0), allSamplesSheet); err != nil {
+		return err
+	}
+
+	if err = sheet.writeSamples(allSamplesSheet, x.samples); err != nil {
+		return err
+	}
+
+	if x.sheets == SheetsNone {
+		return nil
+	}
+
+	return x.buildGroups(sheet)
+}
+
+// buildGroups adds a summary sheet and a sheet per group of samples.
+func (x *xlsxWriter) buildGroups(sheet *xlsxSheet) error {
+	labels, groups := groupForSheets(x.samples, x.sheets)
+
+	if _, err := sheet.file.NewSheet(summarySheet); err != nil {
+		return err
+	}
+
+	if err := sheet.writeSummary(x.sheets, labels, groups); err != nil {
+		return err
+	}
+
+	names := map[string]bool{strings.ToLower(allSamplesSheet): true, strings.ToLower(summarySheet): true}
+
+	for _, label := range labels {
+		name := uniqueSheetName(label, names)
+
+		if _, err := sheet.file.NewSheet(name); err != nil {
+			return err
+		}
+
+		if err := sheet.writeSamples(name, groups[label]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// groupForSheets returns the sorted labels of the study or sponsor groups of
+// samples, and the samples in each.
+func groupForSheets(samples []*TrackedSample, by SheetGroup) ([]string, map[string][]*TrackedSample) {
+	groups := make(map[string][]*TrackedSample)
+
+	for _, s := range samples {
+		label := s.FacultySponsor
+		if by == SheetsPerStudy {
+			label = strings.TrimSpace(s.StudyID + " " + s.StudyName)
+		}
+
+		if label == "" {
+			label = noGroupLabel
+		}
+
+		groups[label] = append(groups[label], s)
+	}
+
+	labels := make([]string, 0, len(groups))
+	for label := range groups {
+		labels = append(labels, label)
+	}
+
+	sort.Strings(labels)
+
+	return labels, groups
+}
+
+// sheetNameReplacer replaces the characters Excel doesn't allow in sheet
+// names.
+var sheetNameReplacer = strings.NewReplacer(
+	":", "-", "\\", "-", "/", "-", "?", "", "*", "", "[", "(", "]", ")", "'", "")
+
+// uniqueSheetName returns label made into a valid sheet name that isn't
+// already used, ignoring case, and adds it to the lower-cased used names.
+func uniqueSheetName(label string, used map[string]bool) string {
+	base := []rune(sheetNameReplacer.Replace(label))
+	if len(base) > maxSheetName {
+		base = base[:maxSheetName]
+	}
+
+	name := string(base)
+
+	for i := 2; used[strings.ToLower(name)]; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		name = string(base[:min(len(base), maxSheetName-len(suffix))]) + suffix
+	}
+
+	used[strings.ToLower(name)] = true
+
+	return name
+}
+
+// xlsxSheet writes sheets of a workbook with shared styles.
+type xlsxSheet struct {
+	file        *excelize.File
+	cols        []outputColumn
+	dateStyle   int
+	headerStyle int
+	now         time.Time
+}
+
+// writeSamples writes a row per sample to the named sheet, below a frozen
+// header row with an autofilter.
+func (x *xlsxSheet) writeSamples(name string, samples []*TrackedSample) error {
+	header := outputHeader(x.cols)
+	rows := make([][]any, len(samples))
+
+	for i, s := range samples {
+		rows[i] = outputValues(x.cols, s, x.now)
+	}
+
+	last, err := excelize.CoordinatesToCellName(len(header), len(rows)+1)
+	if err != nil {
+		return err
+	}
+
+	// The autofilter must be set before streaming the sheet
+	if err = x.file.AutoFilter(name, "A1:"+last, nil); err != nil {
+		return err
+	}
+
+	return x.stream(name, header, rows)
+}
+
+// stream writes the header and rows to the named sheet, freezing the header
+// and sizing the columns to fit their values.
+func (x *xlsxSheet) stream(name string, header []string, rows [][]any) error {
+	sw, err := x.file.NewStreamWriter(name)
+	if err != nil {
+		return err
+	}
+
+	if err = layoutColumns(sw, header, rows); err != nil {
+		return err
+	}
+
+	headerValues := make([]any, len(header))
+	for i, h := range header {
+		headerValues[i] = h
+	}
+
+	if err = sw.SetRow("A1", x.cells(headerValues, x.headerStyle)); err != nil {
+		return err
+	}
+
+	for i, row := range rows {
+		if err = sw.SetRow(fmt.Sprintf("A%d", i+2), x.cells(row, 0)); err != nil {
+			return err
+		}
+	}
+
+	return sw.Flush()
+}
+
+// layoutColumns freezes the header row of sw and sizes its columns to fit
+// their header and values.
+func layoutColumns(sw *excelize.StreamWriter, header []string, rows [][]any) error {
+	err := sw.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+	if err != nil {
+		return err
+	}
+
+	for i, width := range columnWidths(header, rows) {
+		if err = sw.SetColWidth(i+1, i+1, width); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cells returns values as cells with the given style, except for times,
+// which have the date style.
+func (x *xlsxSheet) cells(values []any, style int) []any {
+	cells := make([]any, len(values))
+
+	for i, v := range values {
+		cell := excelize.Cell{StyleID: style, Value: v}
+		if _, ok := v.(time.Time); ok {
+			cell.StyleID = x.dateStyle
+		}
+
+		cells[i] = cell
+	}
+
+	return cells
+}
+
+// columnWidths returns the width of each column needed to fit its header and
+// values, up to a maximum.
+func columnWidths(header []string, rows [][]any) []float64 {
+	widths := make([]float64, len(header))
+
+	for i, h := range header {
+		widths[i] = float64(len(h) + 2)
+	}
+
+	for _, row := range rows {
+		for i, v := range row {
+			widths[i] = max(widths[i], valueWidth(v))
+		}
+	}
+
+	return widths
+}
+
+// valueWidth returns the width needed to display v.
+func valueWidth(v any) float64 {
+	switch v := v.(type) {
+	case time.Time:
+		return dateColumnWidth
+	case nil:
+		return 0
+	default:
+		return min(float64(len([]rune(fmt.Sprint(v)))+2), maxColumnWidth)
+	}
+}
+
+// writeSummary writes the summary sheet, with a row per group giving its
+// number of samples in total and in each stage.
+func (x *xlsxSheet) writeSummary(by SheetGroup, labels []string, groups map[string][]*TrackedSample) error {
+	stages := append(Stages(), StageUnknown)
+	header := []string{summaryLabels[by], "Samples"}
+
+	for _, stage := range stages {
+		header = append(header, stage.String())
+	}
+
+	rows := make([][]any, len(labels))
+
+	for i, label := range labels {
+		counts := make(map[Stage]int)
+		for _, s := range groups[label] {
+			counts[s.Stage()]++
+		}
+
+		rows[i] = []any{label, len(groups[label])}
+		for _, stage := range stages {
+			rows[i] = append(rows[i], counts[stage])
+		}
+	}
+
+	return x.stream(summarySheet, header, rows)
+}
@@ -0,0 +1,137 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package db_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestXLSXWriter(t *testing.T) {
+	Convey("ParseSheetGroup accepts study, sponsor or nothing", t, func() {
+		group, err := db.ParseSheetGroup("Sponsor")
+		So(err, ShouldBeNil)
+		So(group, ShouldEqual, db.SheetsPerSponsor)
+
+		group, err = db.ParseSheetGroup("")
+		So(err, ShouldBeNil)
+		So(group, ShouldEqual, db.SheetsNone)
+
+		_, err = db.ParseSheetGroup("platform")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Given samples from two sponsors", t, func() {
+		ordered := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		five := 5
+		samples := []db.TrackedSample{
+			{FacultySponsor: "Ann: PI", SangerSampleID: "S1", OrderMade: &ordered, LibraryTime: &five},
+			{FacultySponsor: "Bob", SangerSampleID: "S2"},
+			{FacultySponsor: "Bob", SangerSampleID: "S3", OrderMade: &ordered},
+		}
+
+		write := func(opts ...db.WriterOption) *excelize.File {
+			var buf bytes.Buffer
+
+			w, err := db.NewSampleWriter(&buf, db.FormatXLSX, opts...)
+			So(err, ShouldBeNil)
+
+			for i := range samples {
+				So(w.Write(&samples[i]), ShouldBeNil)
+			}
+
+			So(buf.Len(), ShouldEqual, 0)
+			So(w.Close(), ShouldBeNil)
+
+			f, err := excelize.OpenReader(&buf)
+			So(err, ShouldBeNil)
+
+			return f
+		}
+
+		Convey("An XLSX export has one sheet of typed cells with a frozen, filtered header", func() {
+			f := write()
+			defer f.Close()
+
+			So(f.GetSheetList(), ShouldResemble, []string{"Samples"})
+
+			rows, err := f.GetRows("Samples")
+			So(err, ShouldBeNil)
+			So(len(rows), ShouldEqual, 4)
+			So(rows[0][0], ShouldEqual, "StudyID")
+			So(rows[0][len(rows[0])-1], ShouldEqual, "DaysInStage")
+
+			value, err := f.GetCellValue("Samples", "K2")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "2025-01-02 03:04")
+
+			value, err = f.GetCellValue("Samples", "N2")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "5")
+
+			cellType, err := f.GetCellType("Samples", "N2")
+			So(err, ShouldBeNil)
+			So(cellType, ShouldNotEqual, excelize.CellTypeSharedString)
+
+			value, err = f.GetCellValue("Samples", "K3")
+			So(err, ShouldBeNil)
+			So(value, ShouldBeEmpty)
+
+			panes, err := f.GetPanes("Samples")
+			So(err, ShouldBeNil)
+			So(panes.Freeze, ShouldBeTrue)
+			So(panes.YSplit, ShouldEqual, 1)
+
+			So(f.GetDefinedName(), ShouldHaveLength, 1)
+			So(f.GetDefinedName()[0].RefersTo, ShouldEqual, "'Samples'!$A$1:$W$4")
+		})
+
+		Convey("An XLSX export can have a summary and a sheet per sponsor", func() {
+			f := write(db.WithSheets(db.SheetsPerSponsor))
+			defer f.Close()
+
+			So(f.GetSheetList(), ShouldResemble, []string{"Samples", "Summary", "Ann- PI", "Bob"})
+
+			rows, err := f.GetRows("Summary")
+			So(err, ShouldBeNil)
+			So(len(rows), ShouldEqual, 3)
+			So(rows[0][:2], ShouldResemble, []string{"Sponsor", "Samples"})
+			So(rows[0][5], ShouldEqual, "ordered")
+			So(rows[2][:2], ShouldResemble, []string{"Bob", "2"})
+			So(rows[2][5], ShouldEqual, "1")
+			So(rows[2][len(rows[2])-1], ShouldEqual, "1")
+
+			rows, err = f.GetRows("Bob")
+			So(err, ShouldBeNil)
+			So(len(rows), ShouldEqual, 3)
+		})
+	})
+}
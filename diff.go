@@ -0,0 +1,112 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/wtsi-hgi/gst/db"
+)
+
+// runDiff implements the diff subcommand, comparing two exports or snapshots.
+func runDiff(args []string) {
+	diffCmd := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := diffCmd.String("format", "text", "Output format: text or json")
+	strict := diffCmd.Bool("strict", false, "Fail on malformed values in either file, listing each one")
+	diffCmd.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: gst diff [--format text|json] [--strict] old new")
+		diffCmd.PrintDefaults()
+	}
+
+	diffCmd.Parse(args)
+
+	if diffCmd.NArg() != 2 {
+		diffCmd.Usage()
+		os.Exit(1)
+	}
+
+	diff, err := diffFiles(diffCmd.Arg(0), diffCmd.Arg(1), strictOptions(*strict)...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error comparing samples: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err = writeDiff(diff, *format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing diff: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// diffFiles loads the samples in two sample files, with any extra opts, and
+// compares them.
+func diffFiles(oldPath, newPath string, opts ...db.Option) (*db.Diff, error) {
+	old, err := loadSamples(oldPath, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	new, err := loadSamples(newPath, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.Compare(old, new), nil
+}
+
+// loadSamples reads all the samples in a sample file, with any extra opts.
+func loadSamples(path string, opts ...db.Option) (*db.TrackedSampleCollection, error) {
+	provider, err := db.New(append([]db.Option{db.WithMockData(path)}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer closeProvider(provider)
+
+	samples, err := provider.Execute()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return samples, nil
+}
+
+// writeDiff writes diff to stdout in the given format.
+func writeDiff(diff *db.Diff, format string) error {
+	switch format {
+	case "text":
+		return diff.WriteText(os.Stdout)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(diff)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
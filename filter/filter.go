@@ -0,0 +1,182 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// Package filter selects samples by who they belong to, what stage they are
+// in and when they reached a milestone, so that the command line and the web
+// server agree on which samples match.
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wtsi-hgi/gst/db"
+)
+
+// dateLayout is the format of dates that ParseTime accepts besides RFC3339.
+const dateLayout = "2006-01-02"
+
+// Filter describes the samples to keep. Its zero value keeps every sample,
+// and each field that is set must match.
+type Filter struct {
+	// Sponsor is the faculty sponsor samples must have.
+	Sponsor string
+
+	// Study is the name or ID of the study samples must be in.
+	Study string
+
+	// Programme is the programme samples must be in, ignoring case.
+	Programme string
+
+	// Platform must be part of the samples' platform, ignoring case, so that
+	// "PacBio" matches "PacBio, Revio".
+	Platform string
+
+	// Stage is the name of the stage samples must be in, ignoring case.
+	Stage string
+
+	// Milestone is the milestone that Since and Until apply to,
+	// ManifestCreated by default.
+	Milestone db.Milestone
+
+	// Since, if not zero, is when samples must have reached Milestone at or
+	// after.
+	Since time.Time
+
+	// Until, if not zero, is when samples must have reached Milestone
+	// before.
+	Until time.Time
+}
+
+// Apply returns the samples that match the filter, which is all of them if
+// the filter is empty.
+func (f *Filter) Apply(samples []db.TrackedSample) []db.TrackedSample {
+	if *f == (Filter{}) {
+		return samples
+	}
+
+	var filtered []db.TrackedSample
+
+	for i := range samples {
+		if f.Match(&samples[i]) {
+			filtered = append(filtered, samples[i])
+		}
+	}
+
+	return filtered
+}
+
+// Match returns true if the sample matches every field of the filter that is
+// set.
+func (f *Filter) Match(s *db.TrackedSample) bool {
+	return f.matchesOwner(s) &&
+		(f.Platform == "" || strings.Contains(strings.ToLower(s.Platform), strings.ToLower(f.Platform))) &&
+		(f.Stage == "" || strings.EqualFold(s.Stage().String(), f.Stage)) &&
+		f.matchesTime(s)
+}
+
+// matchesOwner returns true if the sample has the filter's sponsor, study and
+// programme.
+func (f *Filter) matchesOwner(s *db.TrackedSample) bool {
+	return (f.Sponsor == "" || s.FacultySponsor == f.Sponsor) &&
+		(f.Study == "" || s.StudyName == f.Study || s.StudyID == f.Study) &&
+		(f.Programme == "" || strings.EqualFold(s.Programme, f.Programme))
+}
+
+// matchesTime returns true if there are no Since and Until times, or the
+// sample reached the filter's milestone within them.
+func (f *Filter) matchesTime(s *db.TrackedSample) bool {
+	if f.Since.IsZero() && f.Until.IsZero() {
+		return true
+	}
+
+	t := f.Milestone.Time(s)
+
+	return t != nil && (f.Since.IsZero() || !t.Before(f.Since)) && (f.Until.IsZero() || t.Before(f.Until))
+}
+
+// Parse returns the filter described by the "sponsor", "study", "programme",
+// "platform" and "stage" parameters, and the "since" and "until" times of the
+// "milestone" parameter (ManifestCreated if empty), using get to look up each
+// parameter's value. Parameters whose value is empty are not filtered on. The
+// web server's query parameters and gst export's flags have these names, so
+// that they select samples the same way.
+func Parse(get func(name string) string) (Filter, error) {
+	f := Filter{
+		Sponsor:   get("sponsor"),
+		Study:     get("study"),
+		Programme: get("programme"),
+		Platform:  get("platform"),
+		Stage:     get("stage"),
+	}
+
+	var err error
+
+	if f.Stage != "" {
+		if _, err = db.ParseStage(f.Stage); err != nil {
+			return f, err
+		}
+	}
+
+	if name := get("milestone"); name != "" {
+		if f.Milestone, err = db.ParseMilestone(name); err != nil {
+			return f, err
+		}
+	}
+
+	if f.Since, err = parseOptionalTime(get("since")); err != nil {
+		return f, err
+	}
+
+	f.Until, err = parseOptionalTime(get("until"))
+
+	return f, err
+}
+
+// parseOptionalTime parses value like ParseTime, returning the zero time if
+// value is empty.
+func parseOptionalTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	return ParseTime(value)
+}
+
+// ParseTime parses value as an RFC3339 time or a YYYY-MM-DD date (midnight
+// UTC), for Since and Until.
+func ParseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(dateLayout, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("invalid time %q, expected YYYY-MM-DD or a format like 2006-01-02T15:04:05Z", value)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,115 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package filter_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+	"github.com/wtsi-hgi/gst/filter"
+)
+
+// ids returns the Sanger sample IDs of samples.
+func ids(samples []db.TrackedSample) []string {
+	var ids []string
+	for _, s := range samples {
+		ids = append(ids, s.SangerSampleID)
+	}
+
+	return ids
+}
+
+func TestFilter(t *testing.T) {
+	Convey("Given samples of different sponsors, studies, platforms and stages", t, func() {
+		day1 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		day2 := day1.AddDate(0, 0, 1)
+		samples := []db.TrackedSample{
+			{FacultySponsor: "Sponsor A", StudyID: "1", StudyName: "Study 1", Programme: "Human Genetics",
+				SangerSampleID: "S1", ManifestCreated: &day1},
+			{FacultySponsor: "Sponsor A", StudyID: "2", StudyName: "Study 2", Programme: "Human Genetics",
+				SangerSampleID: "S2", ManifestCreated: &day2, LibraryStart: &day2},
+			{FacultySponsor: "Sponsor B", StudyID: "3", StudyName: "Study 3", Platform: "PacBio, Revio",
+				SangerSampleID: "S3", ManifestCreated: &day2, LibraryStart: &day2},
+		}
+
+		Convey("An empty filter keeps every sample", func() {
+			So(ids((&filter.Filter{}).Apply(samples)), ShouldResemble, []string{"S1", "S2", "S3"})
+		})
+
+		Convey("Sponsor and study must both match, with studies matched by name or ID", func() {
+			f := filter.Filter{Sponsor: "Sponsor A", Study: "Study 2"}
+			So(ids(f.Apply(samples)), ShouldResemble, []string{"S2"})
+
+			f = filter.Filter{Study: "3"}
+			So(ids(f.Apply(samples)), ShouldResemble, []string{"S3"})
+
+			f = filter.Filter{Sponsor: "Sponsor B", Study: "Study 1"}
+			So(f.Apply(samples), ShouldBeEmpty)
+		})
+
+		Convey("Programme, platform and stage are matched ignoring case", func() {
+			f := filter.Filter{Programme: "human genetics"}
+			So(ids(f.Apply(samples)), ShouldResemble, []string{"S1", "S2"})
+
+			f = filter.Filter{Platform: "pacbio"}
+			So(ids(f.Apply(samples)), ShouldResemble, []string{"S3"})
+
+			f = filter.Filter{Stage: "Library in progress"}
+			So(ids(f.Apply(samples)), ShouldResemble, []string{"S2", "S3"})
+
+			f = filter.Filter{Sponsor: "Sponsor A", Stage: "manifest created"}
+			So(ids(f.Apply(samples)), ShouldResemble, []string{"S1"})
+		})
+
+		Convey("Since and until apply to manifest creation by default", func() {
+			f := filter.Filter{Since: day2}
+			So(ids(f.Apply(samples)), ShouldResemble, []string{"S2", "S3"})
+
+			f = filter.Filter{Until: day2}
+			So(ids(f.Apply(samples)), ShouldResemble, []string{"S1"})
+		})
+
+		Convey("Since and until can apply to any milestone, which must be reached", func() {
+			f := filter.Filter{Milestone: db.MilestoneLibraryStart, Until: day2.Add(time.Hour)}
+			So(ids(f.Apply(samples)), ShouldResemble, []string{"S2", "S3"})
+		})
+	})
+
+	Convey("ParseTime accepts dates and RFC3339 times", t, func() {
+		d, err := filter.ParseTime("2025-01-02")
+		So(err, ShouldBeNil)
+		So(d, ShouldEqual, time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC))
+
+		d, err = filter.ParseTime("2025-01-02T03:04:05Z")
+		So(err, ShouldBeNil)
+		So(d, ShouldEqual, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+
+		_, err = filter.ParseTime("yesterday")
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,75 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package main
+
+import (
+	"flag"
+
+	"github.com/wtsi-hgi/gst/db"
+	"github.com/wtsi-hgi/gst/filter"
+)
+
+// filterFlags are the command line options that choose which of the queried
+// samples are output, and which of their columns. The filter flags are named
+// like the web server's filter parameters, and are read the same way.
+type filterFlags struct {
+	fs      *flag.FlagSet
+	columns *string
+}
+
+// addFilterFlags defines the filter flags on fs.
+func addFilterFlags(fs *flag.FlagSet) *filterFlags {
+	fs.String("sponsor", "", "Only output samples of this faculty sponsor")
+	fs.String("study", "", "Only output samples of the study with this name or ID")
+	fs.String("programme", "", "Only output samples of this programme, ignoring case")
+	fs.String("platform", "", "Only output samples sequenced on a platform including this text")
+	fs.String("stage", "", "Only output samples in this stage, eg. \"library in progress\"")
+	fs.String("milestone", db.MilestoneManifestCreated.String(), "Milestone that --since and --until apply to")
+	fs.String("since", "", "Only output samples that reached --milestone on or after this date")
+	fs.String("until", "", "Only output samples that reached --milestone before this date")
+
+	return &filterFlags{
+		fs:      fs,
+		columns: fs.String("columns", "", "Comma separated columns to output, in order (empty for all)"),
+	}
+}
+
+// filter returns the filter described by the flags.
+func (f *filterFlags) filter() (filter.Filter, error) {
+	return filter.Parse(func(name string) string {
+		return f.fs.Lookup(name).Value.String()
+	})
+}
+
+// writerOptions returns the writer options that select the columns chosen by
+// the flags.
+func (f *filterFlags) writerOptions() []db.WriterOption {
+	if *f.columns == "" {
+		return nil
+	}
+
+	return []db.WriterOption{db.WithColumns(splitList(*f.columns)...)}
+}
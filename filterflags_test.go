@@ -0,0 +1,143 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+	"github.com/wtsi-hgi/gst/server"
+)
+
+// samplesProvider is a db.QueryProvider of fixed samples.
+type samplesProvider struct {
+	samples *db.TrackedSampleCollection
+}
+
+func (p *samplesProvider) Execute() (*db.TrackedSampleCollection, error) {
+	return p.samples, nil
+}
+
+func (p *samplesProvider) ExecuteContext(_ context.Context) (*db.TrackedSampleCollection, error) {
+	return p.samples, nil
+}
+
+// serverIDs returns the Sanger sample IDs of the samples that srv exports
+// with the given filter parameters.
+func serverIDs(srv *server.Server, params url.Values) []string {
+	query := url.Values{"format": {"jsonl"}}
+	for name := range params {
+		query.Set(name, params.Get(name))
+	}
+
+	resp := httptest.NewRecorder()
+	srv.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/export?"+query.Encode(), nil))
+	So(resp.Code, ShouldEqual, http.StatusOK)
+
+	var ids []string
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var sample db.TrackedSample
+		So(json.Unmarshal(scanner.Bytes(), &sample), ShouldBeNil)
+
+		ids = append(ids, sample.SangerSampleID)
+	}
+
+	return ids
+}
+
+// exportIDs returns the Sanger sample IDs of the samples that gst export's
+// filter flags, given the filter parameters as flags, keep.
+func exportIDs(samples []db.TrackedSample, params url.Values) []string {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags := addFilterFlags(fs)
+
+	var args []string
+	for name := range params {
+		args = append(args, "--"+name, params.Get(name))
+	}
+
+	So(fs.Parse(args), ShouldBeNil)
+
+	f, err := flags.filter()
+	So(err, ShouldBeNil)
+
+	var ids []string
+	for _, s := range f.Apply(samples) {
+		ids = append(ids, s.SangerSampleID)
+	}
+
+	return ids
+}
+
+func TestFilterFlags(t *testing.T) {
+	Convey("Given samples and a server of them", t, func() {
+		day1 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		day2 := day1.AddDate(0, 0, 1)
+		samples := []db.TrackedSample{
+			{FacultySponsor: "Sponsor A", StudyID: "1", StudyName: "Study 1", Programme: "Human Genetics",
+				SangerSampleID: "S1", ManifestCreated: &day1},
+			{FacultySponsor: "Sponsor A", StudyID: "2", StudyName: "Study 2", Programme: "Human Genetics",
+				SangerSampleID: "S2", ManifestCreated: &day2, LibraryStart: &day2},
+			{FacultySponsor: "Sponsor B", StudyID: "3", StudyName: "Study 3", Platform: "PacBio, Revio",
+				SangerSampleID: "S3", ManifestCreated: &day2, LibraryStart: &day2},
+		}
+
+		srv, err := server.New(server.Config{
+			QueryProvider: &samplesProvider{samples: &db.TrackedSampleCollection{Samples: samples}},
+		})
+		So(err, ShouldBeNil)
+
+		Convey("Export's filter flags select the same samples as the server's filter parameters", func() {
+			for _, params := range []url.Values{
+				{"sponsor": {"Sponsor A"}},
+				{"study": {"Study 2"}},
+				{"study": {"3"}},
+				{"sponsor": {"Sponsor B"}, "study": {"Study 1"}},
+				{"programme": {"human genetics"}},
+				{"platform": {"pacbio"}},
+				{"stage": {"library in progress"}},
+				{"since": {"2025-01-02"}},
+				{"milestone": {"LibraryStart"}, "until": {"2025-01-03"}},
+			} {
+				expected := serverIDs(srv, params)
+				So(exportIDs(samples, params), ShouldResemble, expected)
+			}
+
+			So(exportIDs(samples, url.Values{"study": {"3"}}), ShouldResemble, []string{"S3"})
+		})
+	})
+}
@@ -26,19 +26,30 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/wtsi-hgi/gst/db"
+	"github.com/wtsi-hgi/gst/filter"
+	"github.com/wtsi-hgi/gst/metrics"
+	"github.com/wtsi-hgi/gst/notify"
+	"github.com/wtsi-hgi/gst/output"
 	"github.com/wtsi-hgi/gst/server"
+	"github.com/wtsi-hgi/gst/sla"
+	"github.com/wtsi-hgi/gst/snapshot"
 )
 
 func main() {
-	// Load environment variables from .env file
+	// Load environment variables from a .env file, if there is one
 	godotenv.Load()
 
 	// Subcommands
@@ -46,75 +57,433 @@ func main() {
 	serverCmd := flag.NewFlagSet("server", flag.ExitOnError)
 
 	// Export command flags
-	outputPath := exportCmd.String("output", "samples.tsv", "Path to output TSV file")
+	outputPath := exportCmd.String("output", "",
+		"Path to output file, or - for stdout (default samples with the --format's extension, like samples.tsv)")
+	exportFormat := exportCmd.String("format", "tsv", "Output format: tsv, csv, json, jsonl, xlsx or parquet")
+	exportBOM := exportCmd.Bool("bom", false, "Start csv output with a byte order mark, for Excel")
+	exportSheets := exportCmd.String("sheets", "",
+		"Add a summary sheet and a sheet per study or sponsor to xlsx output")
+	exportPartition := exportCmd.String("partition", "",
+		"Write a directory at the output path with a file per sponsor or month (of manifest creation)")
+	exportCompress := exportCmd.String("compress", "",
+		"Compress the output with gzip or zstd (default from a .gz or .zst output path)")
+	exportForce := exportCmd.Bool("force", false, "Replace the output if it already exists")
+	timeout := exportCmd.Duration("timeout", 0, "Abandon the query if it takes longer than this (0 for no limit)")
+	exportQuery := addQueryFlags(exportCmd)
+	exportFilter := addFilterFlags(exportCmd)
+	exportSnapshots := addSnapshotFlags(exportCmd)
 
 	// Server command flags
 	serverPort := serverCmd.Int("port", 8080, "Port to run the server on")
-	serverMockPath := serverCmd.String("mock", "samples.tsv", "Path to mock data TSV file")
+	serverMockPath := serverCmd.String("mock", "samples.tsv", "Path to a sample file (TSV, CSV, JSON or JSON Lines, optionally gzipped, such as a snapshot) to serve instead of the database")
+	serverStrict := serverCmd.Bool("strict", false,
+		"Fail on malformed values in the --mock file, listing each one, instead of leaving them empty")
 	cacheTTL := serverCmd.Duration("cacheTTL", 5*time.Minute, "Duration to cache data before refreshing")
+	fullRefresh := serverCmd.Duration("fullRefresh", time.Hour,
+		"Only fetch changed samples on refresh, doing a full refresh this often (0 to always do full refreshes)")
+	holidays := serverCmd.String("holidays", "",
+		"Path to a file of YYYY-MM-DD holiday dates, one per line, excluded from working-day turnaround")
+	slaPath := serverCmd.String("sla", "", "Path to a YAML file of SLA rules to highlight overdue samples with")
+	serverNotify := addNotifyFlags(serverCmd)
+	serverQuery := addQueryFlags(serverCmd)
+	serverSnapshots := addSnapshotFlags(serverCmd)
 
 	// Check which subcommand is being used
 	if len(os.Args) < 2 {
-		fmt.Println("Expected 'export' or 'server' subcommand")
+		fmt.Println("Expected 'export', 'server', 'diff', 'stats', 'check', 'validate' or 'config' subcommand")
 		os.Exit(1)
 	}
 
 	switch os.Args[1] {
 	case "export":
 		exportCmd.Parse(os.Args[2:])
-		runExport(outputPath)
+		conf := mustConfig(*exportQuery.config, exportCmd)
+		format, partition := mustExportFormat(*exportFormat), mustPartition(*exportPartition)
+		setDefaultOutput(outputPath, format, partition)
+		runExport(outputPath, exportOutput{
+			format:    format,
+			partition: partition,
+			opts:      append(mustWriterOptions(*exportBOM, *exportSheets), exportFilter.writerOptions()...),
+			filter:    mustFilter(exportFilter),
+			files:     mustOutputOptions(*outputPath, *exportCompress, *exportForce),
+		}, timeout, exportQuery.mustSpec(conf), exportSnapshots)
 	case "server":
 		serverCmd.Parse(os.Args[2:])
-		runServer(serverPort, serverMockPath, cacheTTL)
+		conf := mustConfig(*serverQuery.config, serverCmd)
+		calendar := mustCalendar(*holidays)
+		rules := mustRules(*slaPath, calendar)
+		runServer(serverPort, serverMockPath, strictOptions(*serverStrict), cacheTTL, fullRefresh,
+			serverQuery.mustSpec(conf), serverSnapshots, calendar, rules, serverNotify.mustNotifier(rules))
+	case "diff":
+		runDiff(os.Args[2:])
+	case "stats":
+		runStats(os.Args[2:])
+	case "check":
+		runCheck(os.Args[2:])
+	case "validate":
+		runValidate(os.Args[2:])
+	case "config":
+		runConfig(os.Args[2:], exportCmd, serverCmd)
 	default:
-		fmt.Println("Expected 'export' or 'server' subcommand")
+		fmt.Println("Expected 'export', 'server', 'diff', 'stats', 'check', 'validate' or 'config' subcommand")
 		os.Exit(1)
 	}
 }
 
-func runExport(outputPath *string) {
-	fmt.Println("Executing database query. This may take several minutes...")
-	provider, err := db.New()
+// mustParams returns the query params configured by q on top of the given
+// ones, exiting on error.
+func mustParams(q *queryFlags, configured db.QueryParams) db.QueryParams {
+	params, err := q.params(configured)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating query provider: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error in query params: %v\n", err)
+		os.Exit(1)
+	}
+
+	return params
+}
+
+// mustFilter returns the sample filter configured by f, exiting on error.
+func mustFilter(f *filterFlags) filter.Filter {
+	sf, err := f.filter()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in filters: %v\n", err)
+		os.Exit(1)
+	}
+
+	return sf
+}
+
+// mustCalendar returns a working-day calendar with the holidays listed in the
+// file at path, or no holidays if path is empty, exiting on error.
+func mustCalendar(path string) *metrics.Calendar {
+	if path == "" {
+		return metrics.NewCalendar()
+	}
+
+	cal, err := metrics.LoadCalendar(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading holidays: %v\n", err)
+		os.Exit(1)
+	}
+
+	return cal
+}
+
+// mustRules returns the SLA rules in the file at path, counting working days
+// with calendar, or nil if path is empty, exiting on error.
+func mustRules(path string, calendar *metrics.Calendar) *sla.Rules {
+	if path == "" {
+		return nil
+	}
+
+	rules, err := sla.Load(path, calendar)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading SLA rules: %v\n", err)
+		os.Exit(1)
+	}
+
+	return rules
+}
+
+// mustExportFormat returns the export format with the given name, exiting on
+// error.
+func mustExportFormat(name string) db.Format {
+	format, err := db.ParseFormat(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in format: %v\n", err)
 		os.Exit(1)
 	}
 
-	samples, err := provider.Execute()
+	return format
+}
+
+// defaultOutput is the name of the export output, less the format's
+// extension, when --output isn't given.
+const defaultOutput = "samples"
+
+// setDefaultOutput sets *path, if empty, to the default output for the given
+// format: a directory called defaultOutput if partitioned, or else a file
+// with the format's extension.
+func setDefaultOutput(path *string, format db.Format, partition db.Partition) {
+	if *path != "" {
+		return
+	}
+
+	*path = defaultOutput
+	if partition == db.PartitionNone {
+		*path += format.Extension()
+	}
+}
+
+// mustWriterOptions returns the options for writing exported samples, exiting
+// if sheets isn't a known sheet grouping.
+func mustWriterOptions(bom bool, sheets string) []db.WriterOption {
+	group, err := db.ParseSheetGroup(sheets)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error executing query: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error in sheets: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Retrieved %d sample records\n", len(samples.Samples))
+	opts := []db.WriterOption{db.WithSheets(group)}
+	if bom {
+		opts = append(opts, db.WithBOM())
+	}
+
+	return opts
+}
 
-	// Ensure output directory exists
-	outputDir := filepath.Dir(*outputPath)
-	if outputDir != "" && outputDir != "." {
-		if err := os.MkdirAll(outputDir, 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
+// mustPartition returns the export partitioning with the given name, exiting
+// on error.
+func mustPartition(name string) db.Partition {
+	partition, err := db.ParsePartition(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in partition: %v\n", err)
+		os.Exit(1)
+	}
+
+	return partition
+}
+
+// mustOutputOptions returns the options for creating the export output at
+// path, exiting if compress isn't a known compression. Without compress, the
+// compression is detected from path's extension.
+func mustOutputOptions(path, compress string, force bool) []output.Option {
+	compression := output.DetectCompression(path)
+
+	if compress != "" {
+		var err error
+
+		compression, err = output.ParseCompression(compress)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error in compress: %v\n", err)
 			os.Exit(1)
 		}
 	}
 
-	// Write results to TSV
-	if err := samples.ToTSV(*outputPath); err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing TSV file: %v\n", err)
+	opts := []output.Option{output.WithCompression(compression)}
+	if force {
+		opts = append(opts, output.WithForce())
+	}
+
+	return opts
+}
+
+// exportOutput describes which exported samples are written, and how.
+type exportOutput struct {
+	format    db.Format
+	partition db.Partition
+	opts      []db.WriterOption
+	filter    filter.Filter
+	files     []output.Option
+}
+
+// exportDestination is an output file or directory that only appears once
+// closed.
+type exportDestination interface {
+	Close() error
+	Abort()
+}
+
+// create returns a SampleWriter for the file at path, or for the directory at
+// path if partitioning, along with the destination that must be closed after
+// the writer to make the output appear at path.
+func (o exportOutput) create(path string) (db.SampleWriter, exportDestination, error) {
+	if o.partition != db.PartitionNone {
+		return o.createDir(path)
+	}
+
+	file, err := output.Create(path, o.files...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	writer, err := db.NewSampleWriter(file, o.format, o.opts...)
+	if err != nil {
+		file.Abort()
+
+		return nil, nil, err
+	}
+
+	return writer, file, nil
+}
+
+// createDir returns a SampleWriter for the partitioned directory at path.
+func (o exportOutput) createDir(path string) (db.SampleWriter, exportDestination, error) {
+	dir, err := output.CreateDir(path, o.files...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	writer, err := db.CreatePartitionedFiles(dir.Path(), o.format, o.partition, o.opts...)
+	if err != nil {
+		dir.Abort()
+
+		return nil, nil, err
+	}
+
+	return writer, dir, nil
+}
+
+func runExport(outputPath *string, out exportOutput, timeout *time.Duration, spec querySpec,
+	snapshots *snapshotFlags) {
+	ctx, cancel := exportContext(*timeout)
+	defer cancel()
+
+	// Keep stdout for the samples if they're being written there
+	progress := os.Stdout
+	if *outputPath == output.Stdout {
+		progress = os.Stderr
+	}
+
+	fmt.Fprintln(progress, "Executing database query. This may take several minutes...")
+	provider, err := db.New(spec.options()...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating query provider: %v\n", err)
+		os.Exit(1)
+	}
+
+	defer closeProvider(provider)
+
+	spec.mustCheck(ctx, provider)
+	mustOutputDir(*outputPath)
+
+	snap, err := snapshots.create(queryFingerprint("", spec))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating snapshot: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Stream results straight into the output (and snapshot) as they arrive
+	count, err := streamToFile(ctx, provider, *outputPath, snap, out)
+	if err != nil {
+		if snap != nil {
+			snap.Abort()
+		}
+
+		fmt.Fprintf(os.Stderr, "Error exporting samples: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err = snapshots.commit(snap); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving snapshot: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Results written to %s\n", *outputPath)
+	fmt.Fprintf(progress, "Wrote %d sample records to %s\n", count, *outputPath)
+}
+
+// closeProvider closes provider if it holds connections open, reporting any
+// failure.
+func closeProvider(provider db.QueryProvider) {
+	closer, ok := provider.(io.Closer)
+	if !ok {
+		return
+	}
+
+	if err := closer.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error closing query provider: %v\n", err)
+	}
+}
+
+// mustOutputDir creates the directory that the output at path will be written
+// in, exiting on error.
+func mustOutputDir(path string) {
+	outputDir := filepath.Dir(path)
+	if path == output.Stdout || outputDir == "" || outputDir == "." {
+		return
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
+		os.Exit(1)
+	}
 }
 
-func runServer(port *int, mockPath *string, cacheTTL *time.Duration) {
+// streamToFile writes each sample from provider that matches out's filter to
+// the file or directory at path as described by out, as soon as it is read,
+// returning the number of samples written. If snap isn't nil, every sample is
+// also written to it, whether it matches or not. Nothing appears at path
+// unless every sample was written.
+func streamToFile(ctx context.Context, provider db.QueryProvider, path string,
+	snap *snapshot.Writer, out exportOutput) (int, error) {
+	writer, dest, err := out.create(path)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	err = db.StreamSamples(ctx, provider, func(sample *db.TrackedSample) error {
+		if snap != nil {
+			if err := snap.Write(sample); err != nil {
+				return err
+			}
+		}
+
+		if !out.filter.Match(sample) {
+			return nil
+		}
+
+		count++
+
+		return writer.Write(sample)
+	})
+	if err == nil {
+		err = writer.Close()
+	} else {
+		writer.Close()
+	}
+
+	if err != nil {
+		dest.Abort()
+
+		return count, err
+	}
+
+	return count, dest.Close()
+}
+
+// exportContext returns a context that is cancelled on SIGINT or SIGTERM, and
+// after the given timeout if it is non-zero.
+func exportContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if timeout <= 0 {
+		return ctx, stop
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// mustReadMock reads the samples of the mock provider, exiting if that fails,
+// so that a server given a malformed file with --strict fails at once rather
+// than on every request.
+func mustReadMock(provider db.QueryProvider) {
+	if _, err := provider.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading mock data: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// shutdownTimeout is how long the server is given to finish requests in
+// progress when shutting down.
+const shutdownTimeout = 30 * time.Second
+
+func runServer(port *int, mockPath *string, mockOpts []db.Option, cacheTTL, fullRefresh *time.Duration,
+	spec querySpec, snapshots *snapshotFlags, calendar *metrics.Calendar, rules *sla.Rules,
+	notifier *notify.Notifier) {
 	// Create query provider
 	var provider db.QueryProvider
 	var err error
 
 	if *mockPath != "" {
-		provider, err = db.New(db.WithMockData(*mockPath))
+		provider, err = db.New(append([]db.Option{db.WithMockData(*mockPath)}, mockOpts...)...)
 	} else {
-		provider, err = db.New()
+		provider, err = db.New(spec.options()...)
 	}
 
 	if err != nil {
@@ -122,13 +491,37 @@ func runServer(port *int, mockPath *string, cacheTTL *time.Duration) {
 		os.Exit(1)
 	}
 
+	spec.mustCheck(context.Background(), provider)
+
+	if *mockPath != "" && len(mockOpts) > 0 {
+		mustReadMock(provider)
+	}
+
+	store, err := snapshots.store()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening snapshot store: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Create and start server
-	srv, err := server.New(server.Config{
-		QueryProvider: provider,
-		Port:          *port,
-		CacheTTL:      *cacheTTL,
-	})
+	config := server.Config{
+		QueryProvider:       provider,
+		Port:                *port,
+		CacheTTL:            *cacheTTL,
+		FullRefreshInterval: *fullRefresh,
+		Snapshots:           store,
+		SnapshotFingerprint: queryFingerprint(*mockPath, spec),
+		SnapshotKeep:        *snapshots.keep,
+		Calendar:            calendar,
+		SLA:                 rules,
+	}
 
+	// Avoid setting a non-nil interface holding a nil pointer
+	if notifier != nil {
+		config.Notifier = notifier
+	}
+
+	srv, err := server.New(config)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating server: %v\n", err)
 		os.Exit(1)
@@ -136,8 +529,32 @@ func runServer(port *int, mockPath *string, cacheTTL *time.Duration) {
 
 	fmt.Printf("Starting server on port %d...\n", *port)
 
-	if err := srv.Start(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error starting server: %v\n", err)
+	if err := serveUntilSignalled(srv); err != nil {
+		fmt.Fprintf(os.Stderr, "Error running server: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// serveUntilSignalled runs srv until it fails or SIGINT or SIGTERM is
+// received, then shuts it down gracefully, closing its database connections.
+func serveUntilSignalled(srv *server.Server) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+
+	go func() { errs <- srv.Start() }()
+
+	var err error
+
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		fmt.Println("Shutting down...")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return errors.Join(err, srv.Shutdown(shutdownCtx))
+}
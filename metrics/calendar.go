@@ -0,0 +1,143 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// Package metrics measures turnaround times between sample milestones, in
+// calendar days, working days or hours.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// dateLayout is the format of dates in holiday files.
+	dateLayout = "2006-01-02"
+
+	// daysPerWeek and workingDaysPerWeek are used to count whole weeks of
+	// working days at once.
+	daysPerWeek        = 7
+	workingDaysPerWeek = 5
+
+	// hoursPerDay converts durations to days.
+	hoursPerDay = 24
+)
+
+// Calendar knows which days are working days: weekdays that aren't holidays.
+type Calendar struct {
+	holidays map[time.Time]bool
+}
+
+// NewCalendar returns a Calendar with the given holidays. Only the date of
+// each holiday is used.
+func NewCalendar(holidays ...time.Time) *Calendar {
+	c := &Calendar{holidays: make(map[time.Time]bool, len(holidays))}
+
+	for _, h := range holidays {
+		c.holidays[dateOf(h)] = true
+	}
+
+	return c
+}
+
+// LoadCalendar reads holidays from a file containing one YYYY-MM-DD date per
+// line. Blank lines and text after a # are ignored.
+func LoadCalendar(path string) (*Calendar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open holiday calendar: %w", err)
+	}
+	defer f.Close()
+
+	var holidays []time.Time
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		if text = strings.TrimSpace(text); text == "" {
+			continue
+		}
+
+		day, err := time.Parse(dateLayout, text)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: invalid date %q", path, line, text)
+		}
+
+		holidays = append(holidays, day)
+	}
+
+	return NewCalendar(holidays...), scanner.Err()
+}
+
+// IsWorkingDay returns true if t falls on a weekday that isn't a holiday.
+func (c *Calendar) IsWorkingDay(t time.Time) bool {
+	return !isWeekend(t) && !c.holidays[dateOf(t)]
+}
+
+// WorkingDays returns the number of working days after from's date up to and
+// including to's date. It is negative if to is before from.
+func (c *Calendar) WorkingDays(from, to time.Time) int {
+	start, end := dateOf(from), dateOf(to)
+	if end.Before(start) {
+		return -c.WorkingDays(to, from)
+	}
+
+	days := daysBetween(start, end)
+	count := (days / daysPerWeek) * workingDaysPerWeek
+
+	for d := start.AddDate(0, 0, days-days%daysPerWeek+1); !d.After(end); d = d.AddDate(0, 0, 1) {
+		if !isWeekend(d) {
+			count++
+		}
+	}
+
+	for h := range c.holidays {
+		if h.After(start) && !h.After(end) && !isWeekend(h) {
+			count--
+		}
+	}
+
+	return count
+}
+
+// isWeekend returns true if t falls on a Saturday or Sunday.
+func isWeekend(t time.Time) bool {
+	return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+}
+
+// dateOf returns midnight UTC on t's date in its own location.
+func dateOf(t time.Time) time.Time {
+	y, m, d := t.Date()
+
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// daysBetween returns the number of calendar days from one date to another.
+func daysBetween(from, to time.Time) int {
+	return int(dateOf(to).Sub(dateOf(from)).Hours() / hoursPerDay)
+}
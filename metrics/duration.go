@@ -0,0 +1,106 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wtsi-hgi/gst/db"
+)
+
+// Unit is a unit that durations can be measured in.
+type Unit int
+
+// The units durations can be measured in.
+const (
+	// Days counts calendar days between dates, like SQL's DATEDIFF.
+	Days Unit = iota
+
+	// WorkingDays counts the working days after the first date up to and
+	// including the second.
+	WorkingDays
+
+	// Hours measures the exact elapsed time in hours.
+	Hours
+)
+
+// unitNames are the names of each Unit.
+var unitNames = [...]string{"days", "working days", "hours"}
+
+// Units returns every Unit.
+func Units() []Unit {
+	return []Unit{Days, WorkingDays, Hours}
+}
+
+// ParseUnit returns the Unit with the given name, ignoring case, spaces,
+// hyphens and underscores, so that "working days" and "workingdays" are the
+// same.
+func ParseUnit(name string) (Unit, error) {
+	for _, u := range Units() {
+		if normaliseUnitName(u.String()) == normaliseUnitName(name) {
+			return u, nil
+		}
+	}
+
+	return Days, fmt.Errorf("unknown unit %q", name)
+}
+
+// normaliseUnitName lower-cases name and strips spaces, hyphens and
+// underscores.
+func normaliseUnitName(name string) string {
+	return strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.ToLower(name))
+}
+
+// String returns the name of the unit.
+func (u Unit) String() string {
+	return unitNames[u]
+}
+
+// Duration returns the time from one time to another in the given unit. It is
+// negative if to is before from.
+func (c *Calendar) Duration(from, to time.Time, unit Unit) float64 {
+	switch unit {
+	case WorkingDays:
+		return float64(c.WorkingDays(from, to))
+	case Hours:
+		return to.Sub(from).Hours()
+	default:
+		return float64(daysBetween(from, to))
+	}
+}
+
+// Between returns the time the sample took to get from one milestone to
+// another in the given unit, or false if it hasn't reached both.
+func (c *Calendar) Between(s *db.TrackedSample, from, to db.Milestone, unit Unit) (float64, bool) {
+	start, end := from.Time(s), to.Time(s)
+	if start == nil || end == nil {
+		return 0, false
+	}
+
+	return c.Duration(*start, *end, unit), true
+}
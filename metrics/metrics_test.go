@@ -0,0 +1,241 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package metrics_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+	"github.com/wtsi-hgi/gst/metrics"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestCalendar(t *testing.T) {
+	Convey("Given a calendar with a holiday on Wednesday 2025-01-08", t, func() {
+		cal := metrics.NewCalendar(date(2025, 1, 8))
+
+		Convey("Weekends and holidays should not be working days", func() {
+			So(cal.IsWorkingDay(date(2025, 1, 6)), ShouldBeTrue)
+			So(cal.IsWorkingDay(date(2025, 1, 8)), ShouldBeFalse)
+			So(cal.IsWorkingDay(date(2025, 1, 11)), ShouldBeFalse)
+			So(cal.IsWorkingDay(date(2025, 1, 12)), ShouldBeFalse)
+		})
+
+		Convey("Working days should be counted after the start up to the end", func() {
+			So(cal.WorkingDays(date(2025, 1, 6), date(2025, 1, 6)), ShouldEqual, 0)
+			So(cal.WorkingDays(date(2025, 1, 3), date(2025, 1, 6)), ShouldEqual, 1)
+			So(cal.WorkingDays(date(2025, 1, 6), date(2025, 1, 10)), ShouldEqual, 3)
+			So(cal.WorkingDays(date(2025, 1, 6), date(2025, 1, 20)), ShouldEqual, 9)
+			So(cal.WorkingDays(date(2025, 1, 20), date(2025, 1, 6)), ShouldEqual, -9)
+		})
+
+		Convey("Working days over long spans should match a day by day count", func() {
+			start := date(2024, 3, 14)
+
+			for _, days := range []int{1, 6, 7, 8, 13, 100, 366} {
+				end := start.AddDate(0, 0, days)
+				expected := 0
+
+				for d := start.AddDate(0, 0, 1); !d.After(end); d = d.AddDate(0, 0, 1) {
+					if cal.IsWorkingDay(d) {
+						expected++
+					}
+				}
+
+				So(cal.WorkingDays(start, end), ShouldEqual, expected)
+			}
+		})
+
+		Convey("Durations should be measured in the requested unit", func() {
+			from := date(2025, 1, 6).Add(9 * time.Hour)
+			to := date(2025, 1, 10).Add(21 * time.Hour)
+
+			So(cal.Duration(from, to, metrics.Days), ShouldEqual, 4)
+			So(cal.Duration(from, to, metrics.WorkingDays), ShouldEqual, 3)
+			So(cal.Duration(from, to, metrics.Hours), ShouldEqual, 108)
+		})
+	})
+
+	Convey("Given a holiday file", t, func() {
+		path := filepath.Join(t.TempDir(), "holidays.txt")
+		content := "# Sanger closure days\n2025-12-25\n\n2025-12-26 # Boxing Day\n"
+		So(os.WriteFile(path, []byte(content), 0600), ShouldBeNil)
+
+		cal, err := metrics.LoadCalendar(path)
+
+		Convey("Its dates should be holidays", func() {
+			So(err, ShouldBeNil)
+			So(cal.IsWorkingDay(date(2025, 12, 24)), ShouldBeTrue)
+			So(cal.IsWorkingDay(date(2025, 12, 25)), ShouldBeFalse)
+			So(cal.IsWorkingDay(date(2025, 12, 26)), ShouldBeFalse)
+		})
+
+		Convey("A bad date should be reported with its line", func() {
+			So(os.WriteFile(path, []byte("2025-12-25\nChristmas\n"), 0600), ShouldBeNil)
+
+			_, err := metrics.LoadCalendar(path)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "line 2")
+		})
+	})
+}
+
+func TestBetween(t *testing.T) {
+	Convey("Given a sample with some milestones", t, func() {
+		cal := metrics.NewCalendar()
+		start := date(2025, 1, 3)
+		end := date(2025, 1, 7)
+		sample := &db.TrackedSample{LibraryStart: &start, LibraryComplete: &end}
+
+		Convey("The time between reached milestones should be returned", func() {
+			d, ok := cal.Between(sample, db.MilestoneLibraryStart, db.MilestoneLibraryComplete, metrics.WorkingDays)
+			So(ok, ShouldBeTrue)
+			So(d, ShouldEqual, 2)
+		})
+
+		Convey("Milestones not reached should give no duration", func() {
+			_, ok := cal.Between(sample, db.MilestoneLibraryStart, db.MilestoneSequencingQCComplete, metrics.Days)
+			So(ok, ShouldBeFalse)
+		})
+	})
+
+	Convey("Units should parse from their names", t, func() {
+		for name, unit := range map[string]metrics.Unit{
+			"days": metrics.Days, "Working days": metrics.WorkingDays,
+			"workingdays": metrics.WorkingDays, "working_days": metrics.WorkingDays, "HOURS": metrics.Hours,
+		} {
+			u, err := metrics.ParseUnit(name)
+			So(err, ShouldBeNil)
+			So(u, ShouldEqual, unit)
+		}
+
+		_, err := metrics.ParseUnit("weeks")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestStats(t *testing.T) {
+	Convey("Summaries should describe the distribution of values", t, func() {
+		s := metrics.Summarise([]float64{4, 1, 3, 2, 10})
+		So(s.Count, ShouldEqual, 5)
+		So(s.Median, ShouldEqual, 3)
+		So(s.Mean, ShouldEqual, 4)
+		So(s.P90, ShouldAlmostEqual, 7.6)
+		So(s.Max, ShouldEqual, 10)
+
+		So(metrics.Summarise(nil), ShouldResemble, metrics.Summary{})
+		So(metrics.Summarise([]float64{1, 2}).Median, ShouldEqual, 1.5)
+	})
+
+	Convey("Given samples from two platforms", t, func() {
+		one, two, three := 1, 2, 3
+		created := date(2025, 1, 1)
+		qc := date(2025, 1, 11)
+		samples := []db.TrackedSample{
+			{SangerSampleID: "S1", RunID: "R1", Platform: "Illumina", FacultySponsor: "A", LibraryTime: &one,
+				SequencingTime: &two, ManifestCreated: &created, SequencingQCComplete: &qc},
+			{SangerSampleID: "S2", RunID: "R2", Platform: "PacBio", FacultySponsor: "A", LibraryTime: &three},
+			{SangerSampleID: "S3", RunID: "R3", Platform: "Illumina", FacultySponsor: "B", LibraryTime: &three},
+		}
+
+		Convey("Stats grouped by platform should summarise each platform's times", func() {
+			stats := metrics.Stats(samples, metrics.GroupByPlatform)
+			So(len(stats), ShouldEqual, 2)
+			So(stats[0].Group, ShouldEqual, "Illumina")
+			So(stats[0].LibraryTime.Count, ShouldEqual, 2)
+			So(stats[0].LibraryTime.Median, ShouldEqual, 2)
+			So(stats[0].LibraryTime.P90, ShouldAlmostEqual, 2.8)
+			So(stats[0].LibraryTime.Max, ShouldEqual, 3)
+			So(stats[0].SequencingTime.Count, ShouldEqual, 1)
+			So(stats[0].EndToEnd, ShouldResemble,
+				metrics.Summary{Count: 1, Median: 10, Mean: 10, P90: 10, Max: 10})
+			So(stats[1].Group, ShouldEqual, "PacBio")
+			So(stats[1].SequencingTime.Count, ShouldEqual, 0)
+		})
+
+		Convey("Stats grouped by sponsor should group on sponsor", func() {
+			stats := metrics.Stats(samples, metrics.GroupBySponsor)
+			So(len(stats), ShouldEqual, 2)
+			So(stats[0].LibraryTime.Count, ShouldEqual, 2)
+			So(stats[1].Group, ShouldEqual, "B")
+		})
+	})
+
+	Convey("Given a sample sequenced in three runs", t, func() {
+		five := 5
+		created := date(2025, 1, 1)
+		var samples []db.TrackedSample
+
+		for _, run := range []string{"R1", "R2", "R3"} {
+			qc := date(2025, 1, 11)
+			samples = append(samples, db.TrackedSample{SangerSampleID: "S1", RunID: run,
+				FacultySponsor: "A", Platform: "Illumina", LibraryTime: &five,
+				ManifestCreated: &created, SequencingQCComplete: &qc})
+		}
+
+		Convey("Stats should count the sample once", func() {
+			stats := metrics.Stats(samples, metrics.GroupBySponsor)
+			So(len(stats), ShouldEqual, 1)
+			So(stats[0].LibraryTime, ShouldResemble,
+				metrics.Summary{Count: 1, Median: 5, Mean: 5, P90: 5, Max: 5})
+			So(stats[0].EndToEnd.Count, ShouldEqual, 1)
+
+			So(metrics.Stats(samples, metrics.GroupByPlatform)[0].LibraryTime.Count, ShouldEqual, 1)
+		})
+	})
+
+	Convey("Given a sample with sequencing times but no RunID", t, func() {
+		three := 3
+		created, start, qc := date(2025, 1, 1), date(2025, 1, 8), date(2025, 1, 11)
+		samples := []db.TrackedSample{{SangerSampleID: "S1", FacultySponsor: "A",
+			ManifestCreated: &created, SequencingRunStart: &start, SequencingQCComplete: &qc,
+			SequencingTime: &three}}
+
+		Convey("Stats should still count its sequencing", func() {
+			stats := metrics.Stats(samples, metrics.GroupBySponsor)
+			So(len(stats), ShouldEqual, 1)
+			So(stats[0].SequencingTime, ShouldResemble,
+				metrics.Summary{Count: 1, Median: 3, Mean: 3, P90: 3, Max: 3})
+			So(stats[0].EndToEnd.Count, ShouldEqual, 1)
+		})
+	})
+
+	Convey("Groupings should parse from their names", t, func() {
+		g, err := metrics.ParseGroupBy("Programme")
+		So(err, ShouldBeNil)
+		So(g, ShouldEqual, metrics.GroupByProgramme)
+
+		_, err = metrics.ParseGroupBy("colour")
+		So(err, ShouldNotBeNil)
+	})
+}
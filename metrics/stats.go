@@ -0,0 +1,211 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/wtsi-hgi/gst/db"
+)
+
+// GroupBy is a sample field that statistics can be grouped on.
+type GroupBy int
+
+// The fields statistics can be grouped on.
+const (
+	GroupBySponsor GroupBy = iota
+	GroupByStudy
+	GroupByProgramme
+	GroupByPlatform
+	GroupByPipeline
+)
+
+// groupByNames are the names of each GroupBy.
+var groupByNames = [...]string{"sponsor", "study", "programme", "platform", "pipeline"}
+
+// GroupBys returns every GroupBy.
+func GroupBys() []GroupBy {
+	return []GroupBy{GroupBySponsor, GroupByStudy, GroupByProgramme, GroupByPlatform, GroupByPipeline}
+}
+
+// ParseGroupBy returns the GroupBy with the given name, ignoring case.
+func ParseGroupBy(name string) (GroupBy, error) {
+	for _, g := range GroupBys() {
+		if strings.EqualFold(g.String(), name) {
+			return g, nil
+		}
+	}
+
+	return GroupBySponsor, fmt.Errorf("unknown grouping %q", name)
+}
+
+// String returns the name of the grouping.
+func (g GroupBy) String() string {
+	return groupByNames[g]
+}
+
+// Of returns the value of the grouped field for the given sample.
+func (g GroupBy) Of(s *db.TrackedSample) string {
+	switch g {
+	case GroupByStudy:
+		return s.StudyName
+	case GroupByProgramme:
+		return s.Programme
+	case GroupByPlatform:
+		return s.Platform
+	case GroupByPipeline:
+		return s.Pipeline
+	default:
+		return s.FacultySponsor
+	}
+}
+
+// Summary describes the distribution of a set of durations. Everything but
+// Count is 0 if Count is.
+type Summary struct {
+	Count  int     `json:"count"`
+	Median float64 `json:"median"`
+	Mean   float64 `json:"mean"`
+	P90    float64 `json:"p90"`
+	Max    float64 `json:"max"`
+}
+
+// Summarise returns a Summary of the given values. Percentiles interpolate
+// linearly between the closest ranks.
+func Summarise(values []float64) Summary {
+	if len(values) == 0 {
+		return Summary{}
+	}
+
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+
+	return Summary{
+		Count:  len(sorted),
+		Median: percentile(sorted, 0.5),
+		Mean:   sum / float64(len(sorted)),
+		P90:    percentile(sorted, 0.9),
+		Max:    sorted[len(sorted)-1],
+	}
+}
+
+// percentile returns the p'th (0..1) percentile of the given sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// GroupStats summarises the turnaround of the samples in one group. Times are
+// in days.
+type GroupStats struct {
+	Group          string  `json:"group"`
+	LibraryTime    Summary `json:"libraryTime"`
+	SequencingTime Summary `json:"sequencingTime"`
+	EndToEnd       Summary `json:"endToEnd"`
+}
+
+// Stats groups the given samples on the given field and summarises the
+// library time, sequencing time and end-to-end time from manifest creation to
+// sequencing QC of each group. A sample with several runs in a group is
+// counted once, as its db.Sample Summary, so that it doesn't skew the group's
+// times. Samples missing a time are left out of that time's summary. Groups
+// are sorted by name.
+func Stats(samples []db.TrackedSample, by GroupBy) []GroupStats {
+	groups := make(map[string][]db.TrackedSample)
+
+	for i := range samples {
+		key := by.Of(&samples[i])
+		groups[key] = append(groups[key], samples[i])
+	}
+
+	stats := make([]GroupStats, 0, len(groups))
+	for name, rows := range groups {
+		stats = append(stats, groupStats(name, rows))
+	}
+
+	slices.SortFunc(stats, func(a, b GroupStats) int {
+		return strings.Compare(a.Group, b.Group)
+	})
+
+	return stats
+}
+
+// groupStats summarises the times of the samples of the given rows under the
+// given name.
+func groupStats(name string, rows []db.TrackedSample) GroupStats {
+	var times groupTimes
+
+	summaries := db.Summaries(db.GroupSamples(rows))
+	for i := range summaries {
+		times.add(&summaries[i])
+	}
+
+	return times.stats(name)
+}
+
+// groupTimes collects the times of the samples in a group.
+type groupTimes struct {
+	library    []float64
+	sequencing []float64
+	endToEnd   []float64
+}
+
+// add adds the times the given sample has to the group.
+func (g *groupTimes) add(s *db.TrackedSample) {
+	if s.LibraryTime != nil {
+		g.library = append(g.library, float64(*s.LibraryTime))
+	}
+
+	if s.SequencingTime != nil {
+		g.sequencing = append(g.sequencing, float64(*s.SequencingTime))
+	}
+
+	if s.ManifestCreated != nil && s.SequencingQCComplete != nil {
+		g.endToEnd = append(g.endToEnd,
+			float64(daysBetween(*s.ManifestCreated, *s.SequencingQCComplete)))
+	}
+}
+
+// stats summarises the group's times under the given name.
+func (g *groupTimes) stats(name string) GroupStats {
+	return GroupStats{
+		Group:          name,
+		LibraryTime:    Summarise(g.library),
+		SequencingTime: Summarise(g.sequencing),
+		EndToEnd:       Summarise(g.endToEnd),
+	}
+}
@@ -0,0 +1,78 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Email is a Channel that sends digests as plain text email via an SMTP
+// server.
+type Email struct {
+	// Addr is the host:port of the SMTP server.
+	Addr string
+
+	// From is the sender's address, and To the recipients'.
+	From string
+	To   []string
+
+	// Auth, if not nil, is used to authenticate with the server.
+	Auth smtp.Auth
+}
+
+// Send emails the digest to the recipients. The context is only checked
+// before sending, since net/smtp has no way to cancel a send.
+func (e *Email) Send(ctx context.Context, digest Digest) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := smtp.SendMail(e.Addr, e.Auth, e.From, e.To, e.message(digest)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// message returns the digest as an email message with headers.
+func (e *Email) message(digest Digest) []byte {
+	headers := []string{
+		"From: " + e.From,
+		"To: " + strings.Join(e.To, ", "),
+		"Subject: " + digest.Subject(),
+		"Date: " + digest.Time.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+	}
+
+	body := strings.ReplaceAll(digest.Text(), "\n", "\r\n")
+
+	return []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + body)
+}
@@ -0,0 +1,177 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// Package notify sends digests of samples that have newly breached their SLA
+// to channels such as email and webhooks.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wtsi-hgi/gst/db"
+	"github.com/wtsi-hgi/gst/sla"
+)
+
+// Digest is a batch of breaches to notify about.
+type Digest struct {
+	Time     time.Time    `json:"time"`
+	Breaches []sla.Breach `json:"breaches"`
+}
+
+// Subject summarises the digest in a line.
+func (d Digest) Subject() string {
+	if len(d.Breaches) == 1 {
+		return "gst: 1 sample is newly overdue"
+	}
+
+	return fmt.Sprintf("gst: %d samples are newly overdue", len(d.Breaches))
+}
+
+// Text describes each breach in the digest on its own line.
+func (d Digest) Text() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "As of %s these samples have breached their SLA:\n\n",
+		d.Time.Format(time.RFC1123))
+
+	for _, breach := range d.Breaches {
+		fmt.Fprintf(&b, "%s\n", breach)
+	}
+
+	return b.String()
+}
+
+// Channel is somewhere digests can be sent.
+type Channel interface {
+	// Send delivers the digest, returning an error if it couldn't be.
+	Send(ctx context.Context, digest Digest) error
+}
+
+// breachKey identifies a breach for de-duplication: the same sample can only
+// breach once per stage.
+type breachKey struct {
+	sample db.SampleKey
+	stage  string
+}
+
+// Notifier checks samples against SLA rules and sends any breaches to its
+// channels, sending each breach to each channel only once while it lasts.
+// Breaches that are already current when it first checks are assumed to have
+// been sent before, such as by a previous run of the server, so aren't sent.
+type Notifier struct {
+	rules    *sla.Rules
+	channels []Channel
+	sent     []map[breachKey]bool
+	seeded   bool
+	mu       sync.Mutex
+}
+
+// New returns a Notifier that checks samples against rules and notifies the
+// given channels.
+func New(rules *sla.Rules, channels ...Channel) *Notifier {
+	sent := make([]map[breachKey]bool, len(channels))
+	for i := range sent {
+		sent[i] = make(map[breachKey]bool)
+	}
+
+	return &Notifier{rules: rules, channels: channels, sent: sent}
+}
+
+// Notify sends each channel a digest of the breaches among samples as of now
+// that it hasn't already been sent. A breach that fails to send to a channel
+// is tried again next time. Breaches that have ended are forgotten, so they'll
+// be sent again if they recur. Errors from all channels are joined.
+//
+// The first call sends nothing, only recording the current breaches as sent,
+// so that restarting doesn't resend every breach.
+func (n *Notifier) Notify(ctx context.Context, samples []db.TrackedSample, now time.Time) error {
+	breaches := n.rules.Overdue(samples, now)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.seeded {
+		n.seed(breaches)
+
+		return nil
+	}
+
+	var errs []error
+
+	for i, channel := range n.channels {
+		if err := n.notifyChannel(ctx, i, channel, breaches, now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// seed records breaches as already sent to every channel.
+func (n *Notifier) seed(breaches []sla.Breach) {
+	for i := range n.sent {
+		for _, b := range breaches {
+			n.sent[i][breachKey{sample: b.Sample.Key(), stage: b.Stage}] = true
+		}
+	}
+
+	n.seeded = true
+}
+
+// notifyChannel sends the breaches that channel i hasn't been sent yet, then
+// updates its record of what was sent.
+func (n *Notifier) notifyChannel(ctx context.Context, i int, channel Channel,
+	breaches []sla.Breach, now time.Time) error {
+	current := make(map[breachKey]bool, len(breaches))
+
+	var unsent []sla.Breach
+
+	for _, b := range breaches {
+		key := breachKey{sample: b.Sample.Key(), stage: b.Stage}
+		current[key] = n.sent[i][key]
+
+		if !current[key] {
+			unsent = append(unsent, b)
+		}
+	}
+
+	var err error
+	if len(unsent) > 0 {
+		err = channel.Send(ctx, Digest{Time: now, Breaches: unsent})
+	}
+
+	for key := range current {
+		current[key] = current[key] || err == nil
+	}
+
+	n.sent[i] = current
+
+	return err
+}
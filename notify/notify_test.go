@@ -0,0 +1,247 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+	"github.com/wtsi-hgi/gst/metrics"
+	"github.com/wtsi-hgi/gst/notify"
+	"github.com/wtsi-hgi/gst/sla"
+)
+
+// smtpStandIn is a minimal SMTP server that records the messages sent to it.
+type smtpStandIn struct {
+	listener net.Listener
+	messages []string
+	mu       sync.Mutex
+}
+
+// startSMTP starts an smtpStandIn on a random local port.
+func startSMTP(t *testing.T) *smtpStandIn {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &smtpStandIn{listener: l}
+	t.Cleanup(func() { l.Close() })
+
+	go s.serve()
+
+	return s
+}
+
+func (s *smtpStandIn) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.handle(conn)
+	}
+}
+
+// handle speaks just enough SMTP for net/smtp.SendMail.
+func (s *smtpStandIn) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	conn.Write([]byte("220 stand-in\r\n"))
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+		case "DATA":
+			conn.Write([]byte("354 go ahead\r\n"))
+			s.readData(r)
+			conn.Write([]byte("250 queued\r\n"))
+		case "QUIT":
+			conn.Write([]byte("221 bye\r\n"))
+
+			return
+		default:
+			conn.Write([]byte("250 ok\r\n"))
+		}
+	}
+}
+
+// readData reads a message up to its terminating dot line and records it.
+func (s *smtpStandIn) readData(r *bufio.Reader) {
+	var msg strings.Builder
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil || line == ".\r\n" {
+			break
+		}
+
+		msg.WriteString(line)
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, msg.String())
+	s.mu.Unlock()
+}
+
+func (s *smtpStandIn) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.messages...)
+}
+
+// webhookStandIn records the digests posted to it, failing with the given
+// status if it isn't 0.
+type webhookStandIn struct {
+	digests []notify.Digest
+	fail    int
+	mu      sync.Mutex
+}
+
+func (w *webhookStandIn) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.fail != 0 {
+		rw.WriteHeader(w.fail)
+
+		return
+	}
+
+	var d notify.Digest
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	w.digests = append(w.digests, d)
+}
+
+func TestNotifier(t *testing.T) {
+	Convey("Given a notifier with email and webhook channels", t, func() {
+		rules, err := sla.New([]sla.Rule{{Stage: "ordered", Max: 2}}, metrics.NewCalendar())
+		So(err, ShouldBeNil)
+
+		mail := startSMTP(t)
+		hook := &webhookStandIn{}
+		hookServer := httptest.NewServer(hook)
+		defer hookServer.Close()
+
+		n := notify.New(rules,
+			&notify.Email{Addr: mail.listener.Addr().String(), From: "gst@example.com",
+				To: []string{"a@example.com", "b@example.com"}},
+			&notify.Webhook{URL: hookServer.URL})
+
+		ordered := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		later := ordered.AddDate(0, 0, 5)
+		samples := []db.TrackedSample{
+			{SangerSampleID: "late", RunID: "1", OrderMade: &ordered},
+			{SangerSampleID: "ontime", RunID: "1", OrderMade: &later},
+		}
+		now := ordered.AddDate(0, 0, 6)
+		ctx := context.Background()
+
+		So(n.Notify(ctx, nil, now), ShouldBeNil)
+
+		Convey("Notifying should send a digest of the breaches to every channel", func() {
+			So(n.Notify(ctx, samples, now), ShouldBeNil)
+
+			msgs := mail.received()
+			So(len(msgs), ShouldEqual, 1)
+			So(msgs[0], ShouldContainSubstring, "Subject: gst: 1 sample is newly overdue")
+			So(msgs[0], ShouldContainSubstring, "To: a@example.com, b@example.com")
+			So(msgs[0], ShouldContainSubstring, "late (run 1): ordered for 6.0 days")
+			So(msgs[0], ShouldNotContainSubstring, "ontime")
+
+			So(len(hook.digests), ShouldEqual, 1)
+			So(len(hook.digests[0].Breaches), ShouldEqual, 1)
+			So(hook.digests[0].Breaches[0].Sample.SangerSampleID, ShouldEqual, "late")
+
+			Convey("Notifying again should not resend the same breaches", func() {
+				So(n.Notify(ctx, samples, now.Add(time.Hour)), ShouldBeNil)
+				So(len(mail.received()), ShouldEqual, 1)
+				So(len(hook.digests), ShouldEqual, 1)
+			})
+
+			Convey("New breaches should be sent on their own", func() {
+				So(n.Notify(ctx, samples, now.AddDate(0, 0, 5)), ShouldBeNil)
+				So(len(hook.digests), ShouldEqual, 2)
+				So(len(hook.digests[1].Breaches), ShouldEqual, 1)
+				So(hook.digests[1].Breaches[0].Sample.SangerSampleID, ShouldEqual, "ontime")
+			})
+
+			Convey("Breaches that end and recur should be sent again", func() {
+				So(n.Notify(ctx, samples[1:], now), ShouldBeNil)
+				So(n.Notify(ctx, samples, now), ShouldBeNil)
+				So(len(hook.digests), ShouldEqual, 2)
+			})
+		})
+
+		Convey("A new notifier should not send breaches that were already known", func() {
+			restarted := notify.New(rules, &notify.Webhook{URL: hookServer.URL})
+			So(restarted.Notify(ctx, samples, now), ShouldBeNil)
+			So(restarted.Notify(ctx, samples, now.Add(time.Hour)), ShouldBeNil)
+			So(hook.digests, ShouldBeEmpty)
+
+			So(restarted.Notify(ctx, samples, now.AddDate(0, 0, 5)), ShouldBeNil)
+			So(len(hook.digests), ShouldEqual, 1)
+			So(hook.digests[0].Breaches[0].Sample.SangerSampleID, ShouldEqual, "ontime")
+		})
+
+		Convey("Breaches a channel fails to send should be retried for just that channel", func() {
+			hook.fail = http.StatusInternalServerError
+			err := n.Notify(ctx, samples, now)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "500")
+			So(len(mail.received()), ShouldEqual, 1)
+
+			hook.fail = 0
+			So(n.Notify(ctx, samples, now), ShouldBeNil)
+			So(len(mail.received()), ShouldEqual, 1)
+			So(len(hook.digests), ShouldEqual, 1)
+		})
+	})
+}
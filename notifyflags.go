@@ -0,0 +1,115 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+
+	"github.com/wtsi-hgi/gst/notify"
+	"github.com/wtsi-hgi/gst/sla"
+)
+
+// notifyFlags are the command line options for notifying people about SLA
+// breaches.
+type notifyFlags struct {
+	smtpAddr *string
+	from     *string
+	to       *string
+	webhook  *string
+}
+
+// addNotifyFlags defines the notification flags on fs.
+func addNotifyFlags(fs *flag.FlagSet) *notifyFlags {
+	return &notifyFlags{
+		smtpAddr: fs.String("smtp", "", "host:port of the SMTP server to email breach notifications through"),
+		from:     fs.String("notifyFrom", "gst@localhost", "Sender address of breach notification emails"),
+		to:       fs.String("notifyTo", "", "Comma separated addresses to email breach notifications to"),
+		webhook:  fs.String("notifyWebhook", "", "URL to POST breach notifications to as JSON"),
+	}
+}
+
+// channels returns the notification channels configured, if any.
+func (f *notifyFlags) channels() ([]notify.Channel, error) {
+	var channels []notify.Channel
+
+	if *f.to != "" {
+		if *f.smtpAddr == "" {
+			return nil, fmt.Errorf("--notifyTo needs --smtp")
+		}
+
+		channels = append(channels, &notify.Email{
+			Addr: *f.smtpAddr, From: *f.from, To: splitList(*f.to), Auth: smtpAuth(*f.smtpAddr),
+		})
+	}
+
+	if *f.webhook != "" {
+		channels = append(channels, &notify.Webhook{URL: *f.webhook})
+	}
+
+	return channels, nil
+}
+
+// smtpAuth returns PLAIN authentication for the SMTP server at addr using the
+// GST_SMTP_USER and GST_SMTP_PASS environment variables, or nil if no user is
+// set.
+func smtpAuth(addr string) smtp.Auth {
+	user := os.Getenv("GST_SMTP_USER")
+	if user == "" {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	return smtp.PlainAuth("", user, os.Getenv("GST_SMTP_PASS"), host)
+}
+
+// mustNotifier returns a notifier for the configured channels that checks
+// samples against rules, or nil if no channels are configured, exiting on
+// error.
+func (f *notifyFlags) mustNotifier(rules *sla.Rules) *notify.Notifier {
+	channels, err := f.channels()
+	if err == nil && len(channels) > 0 && rules == nil {
+		err = fmt.Errorf("notifications need --sla")
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in notification options: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(channels) == 0 {
+		return nil
+	}
+
+	return notify.New(rules, channels...)
+}
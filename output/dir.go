@@ -0,0 +1,184 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package output
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// dirMarker is the name of the empty file put in directories written by
+// CreateDir, so that only those are replaced when forced.
+const dirMarker = ".gst-output"
+
+// Dir is a directory being written. The directory only appears at its
+// destination once it is closed.
+type Dir struct {
+	path  string
+	tmp   string
+	force bool
+}
+
+// CreateDir starts writing a directory at path. Files should be written to
+// the directory returned by Path. It returns an error wrapping ErrExists if
+// the directory already exists, unless WithForce is given, in which case it
+// can only replace a directory that CreateDir wrote. Directories can't be
+// written to stdout or compressed.
+func CreateDir(path string, opts ...Option) (*Dir, error) {
+	cfg := newConfig(opts)
+
+	if path == Stdout {
+		return nil, errors.New("a directory can't be written to stdout")
+	}
+
+	if cfg.compression != None {
+		return nil, errors.New("a directory can't be compressed")
+	}
+
+	if err := cfg.checkDirDestination(path); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.MkdirTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.WriteFile(filepath.Join(tmp, dirMarker), nil, fileMode); err != nil {
+		os.RemoveAll(tmp)
+
+		return nil, err
+	}
+
+	return &Dir{path: path, tmp: tmp, force: cfg.force}, nil
+}
+
+// checkDirDestination returns ErrExists if path exists and cfg doesn't allow
+// replacing it, or an error if it would be replaced but CreateDir didn't
+// write it.
+func (cfg *config) checkDirDestination(path string) error {
+	if err := cfg.checkDestination(path); err != nil || !cfg.force {
+		return err
+	}
+
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if _, err = os.Lstat(filepath.Join(path, dirMarker)); !info.IsDir() || err != nil {
+		return fmt.Errorf("refusing to replace %s, which is not a previous output directory", path)
+	}
+
+	return nil
+}
+
+// Path returns the temporary directory to write files to.
+func (d *Dir) Path() string {
+	return d.tmp
+}
+
+// Close moves the directory to its destination. Without WithForce it returns
+// an error wrapping ErrExists if something has appeared there since the
+// directory was created, leaving that alone. With WithForce any previous
+// output directory there is moved aside, and only deleted once the new one has
+// replaced it. If this fails the directory is discarded and any previous one
+// is left in place.
+func (d *Dir) Close() error {
+	cfg := &config{force: d.force}
+
+	err := os.Chmod(d.tmp, 0755)
+	if err == nil {
+		err = cfg.checkDirDestination(d.path)
+	}
+
+	if err == nil && d.force {
+		err = d.replace()
+	} else if err == nil {
+		err = d.place()
+	}
+
+	if err != nil {
+		d.Abort()
+	}
+
+	return err
+}
+
+// place renames the directory to its destination, which must not exist. A
+// rename can't replace a file or non-empty directory, so checking just before
+// it leaves only an empty directory appearing in between to be replaced.
+func (d *Dir) place() error {
+	_, err := os.Lstat(d.path)
+	if err == nil {
+		return fmt.Errorf("%w: %s", ErrExists, d.path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	err = os.Rename(d.tmp, d.path)
+	if errors.Is(err, os.ErrExist) || errors.Is(err, syscall.ENOTEMPTY) || errors.Is(err, syscall.ENOTDIR) {
+		return fmt.Errorf("%w: %s", ErrExists, d.path)
+	}
+
+	return err
+}
+
+// replace renames the directory to its destination, first moving any
+// existing directory there aside, and deleting that once replaced. Failing to
+// delete it is only logged, since the new directory is in place by then.
+func (d *Dir) replace() error {
+	old := d.tmp + ".old"
+
+	err := os.Rename(d.path, old)
+	if errors.Is(err, os.ErrNotExist) {
+		return d.place()
+	} else if err != nil {
+		return err
+	}
+
+	if err = os.Rename(d.tmp, d.path); err != nil {
+		return errors.Join(err, os.Rename(old, d.path))
+	}
+
+	if err = os.RemoveAll(old); err != nil {
+		log.Printf("warning: replaced %s, but failed to delete the old one at %s: %v", d.path, old, err)
+	}
+
+	return nil
+}
+
+// Abort discards the directory, leaving any existing one at its destination
+// alone.
+func (d *Dir) Abort() {
+	os.RemoveAll(d.tmp)
+}
@@ -0,0 +1,293 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// Package output writes files safely, so that a failed or interrupted write
+// never leaves a partial file behind: files are written to a temporary file
+// that only replaces the destination once complete. Files can instead be
+// written to stdout, and can be compressed with gzip or zstd.
+package output
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Stdout is the path that means standard output.
+const Stdout = "-"
+
+// fileMode is the permissions of created files.
+const fileMode = 0644
+
+// ErrExists is returned when the destination already exists and replacing it
+// wasn't allowed.
+var ErrExists = errors.New("output already exists")
+
+// Compression is a way of compressing output.
+type Compression int
+
+// The ways output can be compressed.
+const (
+	None Compression = iota
+	Gzip
+	Zstd
+)
+
+// compressionNames are the names of each Compression.
+var compressionNames = [...]string{"", "gzip", "zstd"}
+
+// compressionExtensions are the file extensions of each Compression.
+var compressionExtensions = [...]string{"", ".gz", ".zst"}
+
+// ParseCompression returns the Compression with the given name: gzip, zstd,
+// or empty for none.
+func ParseCompression(name string) (Compression, error) {
+	for i, n := range compressionNames {
+		if strings.EqualFold(name, n) {
+			return Compression(i), nil
+		}
+	}
+
+	return None, fmt.Errorf("unknown compression %q, expected gzip or zstd", name)
+}
+
+// String returns the name of the compression.
+func (c Compression) String() string {
+	return compressionNames[c]
+}
+
+// DetectCompression returns the Compression indicated by path's extension,
+// .gz or .zst, or None.
+func DetectCompression(path string) Compression {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	for i, e := range compressionExtensions {
+		if e != "" && ext == e {
+			return Compression(i)
+		}
+	}
+
+	return None
+}
+
+// config holds the options for Create and CreateDir.
+type config struct {
+	compression Compression
+	force       bool
+}
+
+// Option is a function that configures a config.
+type Option func(*config)
+
+// WithCompression compresses the output.
+func WithCompression(c Compression) Option {
+	return func(cfg *config) {
+		cfg.compression = c
+	}
+}
+
+// WithForce allows an existing destination to be replaced, instead of
+// returning ErrExists.
+func WithForce() Option {
+	return func(cfg *config) {
+		cfg.force = true
+	}
+}
+
+// newConfig returns the config set by opts.
+func newConfig(opts []Option) *config {
+	cfg := &config{}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+// checkDestination returns ErrExists if path exists and cfg doesn't allow
+// replacing it.
+func (cfg *config) checkDestination(path string) error {
+	if cfg.force {
+		return nil
+	}
+
+	if _, err := os.Lstat(path); err == nil {
+		return fmt.Errorf("%w: %s", ErrExists, path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// compressor returns a writer that compresses to w as cfg says, or nil if
+// there's no compression.
+func (cfg *config) compressor(w io.Writer) (io.WriteCloser, error) {
+	switch cfg.compression {
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Zstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, nil
+	}
+}
+
+// File is a file being written. The file only appears at its destination
+// once it is closed.
+type File struct {
+	path       string
+	tmp        *os.File
+	buf        *bufio.Writer
+	compressor io.WriteCloser
+	w          io.Writer
+	force      bool
+}
+
+// Create starts writing a file at path, or to stdout if path is Stdout. It
+// returns an error wrapping ErrExists if the file already exists, unless
+// WithForce is given.
+func Create(path string, opts ...Option) (*File, error) {
+	cfg := newConfig(opts)
+	f := &File{path: path, force: cfg.force}
+
+	var dest io.Writer = os.Stdout
+
+	if path != Stdout {
+		if err := cfg.checkDestination(path); err != nil {
+			return nil, err
+		}
+
+		tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+		if err != nil {
+			return nil, err
+		}
+
+		f.tmp, dest = tmp, tmp
+	}
+
+	f.buf = bufio.NewWriter(dest)
+	f.w = f.buf
+
+	return f, f.compress(cfg)
+}
+
+// compress makes f compress what is written to it as cfg says, aborting f if
+// that fails.
+func (f *File) compress(cfg *config) error {
+	c, err := cfg.compressor(f.buf)
+	if err != nil {
+		f.Abort()
+
+		return err
+	}
+
+	if c != nil {
+		f.compressor, f.w = c, c
+	}
+
+	return nil
+}
+
+// Write writes p to the file.
+func (f *File) Write(p []byte) (int, error) {
+	return f.w.Write(p)
+}
+
+// Close finishes writing the file and moves it to its destination. Without
+// WithForce it returns an error wrapping ErrExists if a file has appeared
+// there since Create, instead of replacing it. If this fails the file is
+// discarded.
+func (f *File) Close() error {
+	err := f.finish()
+	if err == nil && f.tmp != nil {
+		err = f.move()
+	}
+
+	f.remove()
+
+	return err
+}
+
+// move moves the finished temporary file to its destination. Unless forced,
+// it is linked there, which unlike renaming fails if the destination exists.
+func (f *File) move() error {
+	if f.force {
+		return os.Rename(f.tmp.Name(), f.path)
+	}
+
+	err := os.Link(f.tmp.Name(), f.path)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%w: %s", ErrExists, f.path)
+	}
+
+	return err
+}
+
+// finish flushes everything written to the temporary file or stdout, and
+// closes the temporary file.
+func (f *File) finish() error {
+	var errs []error
+
+	if f.compressor != nil {
+		errs = append(errs, f.compressor.Close())
+	}
+
+	errs = append(errs, f.buf.Flush())
+
+	if f.tmp != nil {
+		errs = append(errs, f.tmp.Chmod(fileMode), f.tmp.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// Abort discards the file, leaving any existing file at its destination
+// alone. Anything already written to stdout can't be taken back.
+func (f *File) Abort() {
+	if f.compressor != nil {
+		f.compressor.Close()
+	}
+
+	f.remove()
+}
+
+// remove closes and deletes the temporary file, if there is one.
+func (f *File) remove() {
+	if f.tmp == nil {
+		return
+	}
+
+	f.tmp.Close()
+	os.Remove(f.tmp.Name())
+}
@@ -0,0 +1,283 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package output_test
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/output"
+)
+
+func TestCreate(t *testing.T) {
+	Convey("Given a directory to write to", t, func() {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "samples.tsv")
+
+		Convey("A file only appears once it is closed", func() {
+			f, err := output.Create(path)
+			So(err, ShouldBeNil)
+
+			_, err = f.Write([]byte("data\n"))
+			So(err, ShouldBeNil)
+			So(path, shouldNotExist)
+
+			So(f.Close(), ShouldBeNil)
+			So(readFile(path), ShouldEqual, "data\n")
+			So(dirEntries(dir), ShouldEqual, 1)
+		})
+
+		Convey("An aborted file leaves nothing behind", func() {
+			f, err := output.Create(path)
+			So(err, ShouldBeNil)
+
+			_, err = f.Write([]byte("partial"))
+			So(err, ShouldBeNil)
+
+			f.Abort()
+			So(path, shouldNotExist)
+			So(dirEntries(dir), ShouldEqual, 0)
+		})
+
+		Convey("Given an existing file", func() {
+			So(os.WriteFile(path, []byte("old\n"), 0600), ShouldBeNil)
+
+			Convey("It is not replaced by default", func() {
+				_, err := output.Create(path)
+				So(errors.Is(err, output.ErrExists), ShouldBeTrue)
+				So(readFile(path), ShouldEqual, "old\n")
+			})
+
+			Convey("It is replaced when forced, but only on close", func() {
+				f, err := output.Create(path, output.WithForce())
+				So(err, ShouldBeNil)
+
+				_, err = f.Write([]byte("new\n"))
+				So(err, ShouldBeNil)
+				So(readFile(path), ShouldEqual, "old\n")
+
+				So(f.Close(), ShouldBeNil)
+				So(readFile(path), ShouldEqual, "new\n")
+			})
+
+			Convey("It is not replaced if it appears while an unforced write is open", func() {
+				So(os.Remove(path), ShouldBeNil)
+
+				f, err := output.Create(path)
+				So(err, ShouldBeNil)
+				So(os.WriteFile(path, []byte("other\n"), 0600), ShouldBeNil)
+
+				_, err = f.Write([]byte("new\n"))
+				So(err, ShouldBeNil)
+
+				err = f.Close()
+				So(errors.Is(err, output.ErrExists), ShouldBeTrue)
+				So(readFile(path), ShouldEqual, "other\n")
+				So(dirEntries(dir), ShouldEqual, 1)
+			})
+
+			Convey("It is left alone when a forced write is aborted", func() {
+				f, err := output.Create(path, output.WithForce())
+				So(err, ShouldBeNil)
+
+				f.Abort()
+				So(readFile(path), ShouldEqual, "old\n")
+			})
+		})
+
+		Convey("Output can be gzip compressed", func() {
+			writeCompressed(path+".gz", output.Gzip)
+
+			file, err := os.Open(path + ".gz")
+			So(err, ShouldBeNil)
+
+			defer file.Close()
+
+			r, err := gzip.NewReader(file)
+			So(err, ShouldBeNil)
+
+			b, err := io.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, "compressed\n")
+		})
+
+		Convey("Output can be zstd compressed", func() {
+			writeCompressed(path+".zst", output.Zstd)
+
+			file, err := os.Open(path + ".zst")
+			So(err, ShouldBeNil)
+
+			defer file.Close()
+
+			r, err := zstd.NewReader(file)
+			So(err, ShouldBeNil)
+
+			defer r.Close()
+
+			b, err := io.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(string(b), ShouldEqual, "compressed\n")
+		})
+	})
+}
+
+// writeCompressed writes a line to a file at path with the given compression.
+func writeCompressed(path string, c output.Compression) {
+	f, err := output.Create(path, output.WithCompression(c))
+	So(err, ShouldBeNil)
+
+	_, err = f.Write([]byte("compressed\n"))
+	So(err, ShouldBeNil)
+	So(f.Close(), ShouldBeNil)
+}
+
+// readFile returns the content of the file at path.
+func readFile(path string) string {
+	b, err := os.ReadFile(path)
+	So(err, ShouldBeNil)
+
+	return string(b)
+}
+
+// dirEntries returns the number of entries in dir.
+func dirEntries(dir string) int {
+	entries, err := os.ReadDir(dir)
+	So(err, ShouldBeNil)
+
+	return len(entries)
+}
+
+// shouldNotExist is a goconvey assertion that the path doesn't exist.
+func shouldNotExist(actual any, _ ...any) string {
+	if _, err := os.Stat(actual.(string)); !errors.Is(err, os.ErrNotExist) {
+		return actual.(string) + " should not exist"
+	}
+
+	return ""
+}
+
+func TestCompression(t *testing.T) {
+	Convey("Compression is parsed by name and detected from extensions", t, func() {
+		c, err := output.ParseCompression("zstd")
+		So(err, ShouldBeNil)
+		So(c, ShouldEqual, output.Zstd)
+		So(c.String(), ShouldEqual, "zstd")
+
+		_, err = output.ParseCompression("bzip2")
+		So(err, ShouldNotBeNil)
+
+		So(output.DetectCompression("samples.tsv.gz"), ShouldEqual, output.Gzip)
+		So(output.DetectCompression("samples.jsonl.ZST"), ShouldEqual, output.Zstd)
+		So(output.DetectCompression("samples.tsv"), ShouldEqual, output.None)
+	})
+}
+
+func TestCreateDir(t *testing.T) {
+	Convey("Given a directory to write a directory in", t, func() {
+		parent := t.TempDir()
+		path := filepath.Join(parent, "export")
+
+		Convey("The directory only appears once closed", func() {
+			d, err := output.CreateDir(path)
+			So(err, ShouldBeNil)
+			So(os.WriteFile(filepath.Join(d.Path(), "part-0.tsv"), []byte("data\n"), 0600), ShouldBeNil)
+			So(path, shouldNotExist)
+
+			So(d.Close(), ShouldBeNil)
+			So(readFile(filepath.Join(path, "part-0.tsv")), ShouldEqual, "data\n")
+			So(dirEntries(parent), ShouldEqual, 1)
+		})
+
+		Convey("A previous output directory is only replaced when forced", func() {
+			d, err := output.CreateDir(path)
+			So(err, ShouldBeNil)
+			So(os.WriteFile(filepath.Join(d.Path(), "old.tsv"), []byte("old\n"), 0600), ShouldBeNil)
+			So(d.Close(), ShouldBeNil)
+
+			_, err = output.CreateDir(path)
+			So(errors.Is(err, output.ErrExists), ShouldBeTrue)
+
+			d, err = output.CreateDir(path, output.WithForce())
+			So(err, ShouldBeNil)
+			So(os.WriteFile(filepath.Join(d.Path(), "new.tsv"), []byte("new\n"), 0600), ShouldBeNil)
+			So(d.Close(), ShouldBeNil)
+			So(readFile(filepath.Join(path, "new.tsv")), ShouldEqual, "new\n")
+			So(filepath.Join(path, "old.tsv"), shouldNotExist)
+			So(dirEntries(parent), ShouldEqual, 1)
+		})
+
+		Convey("Anything appearing before an unforced close is left alone", func() {
+			d, err := output.CreateDir(path)
+			So(err, ShouldBeNil)
+
+			So(os.Mkdir(path, 0755), ShouldBeNil)
+			So(os.WriteFile(filepath.Join(path, "other.tsv"), []byte("other\n"), 0600), ShouldBeNil)
+
+			err = d.Close()
+			So(errors.Is(err, output.ErrExists), ShouldBeTrue)
+			So(readFile(filepath.Join(path, "other.tsv")), ShouldEqual, "other\n")
+			So(dirEntries(path), ShouldEqual, 1)
+			So(dirEntries(parent), ShouldEqual, 1)
+		})
+
+		Convey("Other directories and files are never replaced", func() {
+			So(os.MkdirAll(filepath.Join(path, "other"), 0755), ShouldBeNil)
+
+			_, err := output.CreateDir(path, output.WithForce())
+			So(err, ShouldNotBeNil)
+			So(dirEntries(path), ShouldEqual, 1)
+
+			file := filepath.Join(parent, "file")
+			So(os.WriteFile(file, []byte("data\n"), 0600), ShouldBeNil)
+
+			_, err = output.CreateDir(file, output.WithForce())
+			So(err, ShouldNotBeNil)
+			So(readFile(file), ShouldEqual, "data\n")
+		})
+
+		Convey("An aborted directory leaves nothing behind", func() {
+			d, err := output.CreateDir(path)
+			So(err, ShouldBeNil)
+
+			d.Abort()
+			So(dirEntries(parent), ShouldEqual, 0)
+		})
+
+		Convey("It can't be compressed or written to stdout", func() {
+			_, err := output.CreateDir(path, output.WithCompression(output.Gzip))
+			So(err, ShouldNotBeNil)
+
+			_, err = output.CreateDir(output.Stdout)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
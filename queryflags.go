@@ -0,0 +1,208 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wtsi-hgi/gst/config"
+	"github.com/wtsi-hgi/gst/db"
+)
+
+// configUsage describes the --config flag.
+const configUsage = "Path to a YAML config file, which may also hold query params (default $" + config.EnvPath + ")"
+
+// dateLayout is the format of dates given on the command line.
+const dateLayout = "2006-01-02"
+
+// queryFlags are the command line options that control which samples are
+// queried from MLWH.
+type queryFlags struct {
+	fs            *flag.FlagSet
+	config        *string
+	sponsors      *string
+	programmes    *string
+	studies       *string
+	manifestYears *int
+	manifestFrom  *string
+	manifestTo    *string
+	queryFile     *string
+	queryDir      *string
+	queryName     *string
+}
+
+// addQueryFlags defines the query flags on fs.
+func addQueryFlags(fs *flag.FlagSet) *queryFlags {
+	return &queryFlags{
+		fs:            fs,
+		config:        fs.String("config", os.Getenv(config.EnvPath), configUsage),
+		sponsors:      fs.String("sponsors", "", "Comma separated faculty sponsors to query (empty for all)"),
+		programmes:    fs.String("programmes", "", "Comma separated programmes to query (empty for all)"),
+		studies:       fs.String("studies", "", "Comma separated study IDs to query (empty for all)"),
+		manifestYears: fs.Int("manifestYears", 0, "Only query manifests created in this many past years (0 for all)"),
+		manifestFrom:  fs.String("manifestFrom", "", "Only query manifests created on or after this YYYY-MM-DD date"),
+		manifestTo:    fs.String("manifestTo", "", "Only query manifests created before this YYYY-MM-DD date"),
+		queryFile:     fs.String("queryFile", "", "Path to a SQL file to query with instead of the built in query"),
+		queryDir:      fs.String("queryDir", "", "Directory of SQL files to choose a --queryName from"),
+		queryName:     fs.String("queryName", "", "Name of the SQL file in --queryDir (without .sql) to query with"),
+	}
+}
+
+// query returns the SQL given by --queryFile or --queryName, or empty for
+// the built in query.
+func (q *queryFlags) query() (string, error) {
+	switch {
+	case *q.queryFile != "" && *q.queryName != "":
+		return "", fmt.Errorf("--queryFile and --queryName can't both be given")
+	case *q.queryFile != "":
+		return db.LoadQuery(*q.queryFile)
+	case *q.queryName != "":
+		if *q.queryDir == "" {
+			return "", fmt.Errorf("--queryName needs --queryDir")
+		}
+
+		return db.LoadNamedQuery(*q.queryDir, *q.queryName)
+	default:
+		return "", nil
+	}
+}
+
+// querySpec is everything needed to query MLWH for samples.
+type querySpec struct {
+	// query is the SQL to query with, or empty for the built in query.
+	query  string
+	params db.QueryParams
+	conn   db.ConnectionConfig
+}
+
+// mustSpec returns the querySpec configured by q and conf, exiting on error.
+func (q *queryFlags) mustSpec(conf *config.Config) querySpec {
+	query, err := q.query()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in query: %v\n", err)
+		os.Exit(1)
+	}
+
+	return querySpec{query: query, params: mustParams(q, conf.Query), conn: conf.Database}
+}
+
+// options returns the options for a db provider that queries as s says.
+func (s querySpec) options() []db.Option {
+	return []db.Option{db.WithQueryParams(s.params), db.WithConnectionConfig(s.conn), db.WithQuery(s.query)}
+}
+
+// sql returns the SQL that s queries with.
+func (s querySpec) sql() string {
+	if s.query == "" {
+		return db.GetEmbeddedSQL()
+	}
+
+	return s.query
+}
+
+// mustCheck checks a custom query's result columns against the samples'
+// before a long run, exiting on error.
+func (s querySpec) mustCheck(ctx context.Context, provider db.QueryProvider) {
+	checker, ok := provider.(db.QueryChecker)
+	if s.query == "" || !ok {
+		return
+	}
+
+	if err := checker.CheckQuery(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Error checking query: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// params returns the given QueryParams from the config file, overridden by
+// any query flags given on the command line.
+func (q *queryFlags) params(params db.QueryParams) (db.QueryParams, error) {
+	var err error
+
+	q.fs.Visit(func(f *flag.Flag) {
+		if err == nil {
+			err = q.apply(&params, f.Name)
+		}
+	})
+
+	return params, err
+}
+
+// apply sets the field of params that corresponds to the named flag.
+func (q *queryFlags) apply(params *db.QueryParams, name string) error {
+	switch name {
+	case "sponsors":
+		params.Sponsors = splitList(*q.sponsors)
+	case "programmes":
+		params.Programmes = splitList(*q.programmes)
+	case "studies":
+		params.StudyIDs = splitList(*q.studies)
+	case "manifestYears":
+		params.ManifestYears = *q.manifestYears
+	case "manifestFrom":
+		return parseDate(name, *q.manifestFrom, &params.ManifestFrom)
+	case "manifestTo":
+		return parseDate(name, *q.manifestTo, &params.ManifestTo)
+	}
+
+	return nil
+}
+
+// splitList splits a comma separated list, ignoring blank entries.
+func splitList(list string) []string {
+	var items []string
+
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// parseDate parses value as a date into t, leaving t zero if value is empty.
+func parseDate(name, value string, t *time.Time) error {
+	if value == "" {
+		*t = time.Time{}
+
+		return nil
+	}
+
+	parsed, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return fmt.Errorf("invalid --%s date %q: %w", name, value, err)
+	}
+
+	*t = parsed
+
+	return nil
+}
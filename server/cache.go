@@ -26,58 +26,306 @@
 package server
 
 import (
+	"context"
+	"log"
 	"sort"
 	"sync"
 	"time"
 
 	"github.com/wtsi-hgi/gst/db"
+	"github.com/wtsi-hgi/gst/snapshot"
 )
 
+// watermarkMargin is how long before the start of the previous fetch an
+// incremental refresh looks for changes from, so that milestones recorded a
+// while after the time they are dated are still picked up. Anything older is
+// left for the next full refresh.
+const watermarkMargin = 24 * time.Hour
+
+// maxRetryInterval is the longest the cache waits before trying again after a
+// failed refresh, while it serves the samples it already has.
+const maxRetryInterval = time.Minute
+
 // Cache provides a time-based caching mechanism for sample data.
 type Cache struct {
-	provider    db.QueryProvider
-	ttl         time.Duration
-	samples     *db.TrackedSampleCollection
-	lastFetched time.Time
-	mu          sync.RWMutex
+	provider     db.QueryProvider
+	ttl          time.Duration
+	fullInterval time.Duration
+	snapshots    *snapshot.Store
+	fingerprint  string
+	keep         int
+	hooks        []func(*db.TrackedSampleCollection)
+	samples      *db.TrackedSampleCollection
+	lastFetched  time.Time
+	lastFull     time.Time
+	watermark    time.Time
+	retryAfter   time.Time
+	pending      chan struct{}
+	mu           sync.RWMutex
+	refreshMu    sync.Mutex
+}
+
+// CacheOption is a function that configures a Cache.
+type CacheOption func(*Cache)
+
+// WithFullRefreshInterval makes the cache, when its provider supports it,
+// refresh by fetching only the samples that changed since the last fetch and
+// merging them in. A full refresh, which also drops deleted samples, is done
+// once the given interval has passed since the last one. Without this option
+// every refresh is a full one.
+func WithFullRefreshInterval(interval time.Duration) CacheOption {
+	return func(c *Cache) {
+		c.fullInterval = interval
+	}
+}
+
+// WithSnapshots makes the cache save a snapshot of the samples to store after
+// every refresh, recording the given query fingerprint. If keep is more than
+// zero, only the newest keep snapshots are then kept. Failure to save is
+// logged rather than failing the refresh.
+func WithSnapshots(store *snapshot.Store, fingerprint string, keep int) CacheOption {
+	return func(c *Cache) {
+		c.snapshots = store
+		c.fingerprint = fingerprint
+		c.keep = keep
+	}
+}
+
+// WithRefreshHook makes the cache call fn with the new samples after every
+// refresh. fn is called in the background so that it doesn't hold up the
+// request that caused the refresh, but only once the hooks of earlier
+// refreshes have returned, so it sees refreshes in order. It must not modify
+// the samples.
+func WithRefreshHook(fn func(*db.TrackedSampleCollection)) CacheOption {
+	return func(c *Cache) {
+		c.hooks = append(c.hooks, fn)
+	}
 }
 
 // NewCache creates a new cache with the specified provider and TTL.
-func NewCache(provider db.QueryProvider, ttl time.Duration) *Cache {
-	return &Cache{
+func NewCache(provider db.QueryProvider, ttl time.Duration, opts ...CacheOption) *Cache {
+	c := &Cache{
 		provider: provider,
 		ttl:      ttl,
 	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
 }
 
 // GetSamples returns sample data, either from the cache if it's still valid
-// or by fetching fresh data from the provider.
-func (c *Cache) GetSamples() (*db.TrackedSampleCollection, error) {
-	c.mu.RLock()
-	if c.samples != nil && time.Since(c.lastFetched) < c.ttl {
-		defer c.mu.RUnlock()
-		return c.samples, nil
+// or by fetching fresh data from the provider. A fetch is abandoned if ctx is
+// done before it completes, leaving the cache as it was.
+//
+// Only one fetch happens at a time, and while it does, other callers get the
+// expired samples rather than waiting for it. If a fetch fails, the failure
+// is logged and the expired samples are returned, and another fetch isn't
+// tried until a retry interval has passed. An error is only returned if there
+// are no samples to return.
+func (c *Cache) GetSamples(ctx context.Context) (*db.TrackedSampleCollection, error) {
+	samples, fresh := c.cached()
+	if fresh {
+		return samples, nil
+	}
+
+	if samples == nil {
+		c.refreshMu.Lock()
+	} else if !c.refreshMu.TryLock() {
+		return samples, nil
+	}
+	defer c.refreshMu.Unlock()
+
+	// Check again in case another goroutine refreshed while we were waiting
+	if samples, fresh = c.cached(); fresh {
+		return samples, nil
+	}
+
+	if err := c.refresh(ctx); err != nil {
+		return c.refreshFailed(ctx, err)
 	}
-	c.mu.RUnlock()
 
-	// Need to refresh the cache
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Check again in case another goroutine refreshed while we were waiting
-	if c.samples != nil && time.Since(c.lastFetched) < c.ttl {
-		return c.samples, nil
+	c.afterRefresh(c.samples)
+
+	return c.samples, nil
+}
+
+// cached returns the cached samples, which are nil if there aren't any, and
+// whether they are still valid or a failed refresh is not to be retried yet.
+func (c *Cache) cached() (*db.TrackedSampleCollection, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.samples, c.samples != nil && time.Now().Before(c.expiry())
+}
+
+// refreshFailed returns the expired samples if there are any, logging err and
+// putting off the next refresh, or err if there aren't. The caller must hold
+// refreshMu.
+func (c *Cache) refreshFailed(ctx context.Context, err error) (*db.TrackedSampleCollection, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.samples == nil {
+		return nil, err
+	}
+
+	if ctx.Err() == nil {
+		c.retryAfter = time.Now().Add(min(c.ttl, maxRetryInterval))
+
+		log.Printf("failed to refresh cache, serving samples from %s until a retry at %s: %v",
+			c.lastFetched.Format(time.RFC3339), c.retryAfter.Format(time.RFC3339), err)
+	}
+
+	return c.samples, nil
+}
+
+// expires returns when the cached samples expire, or a failed refresh is to
+// be retried, which is in the past if there aren't any samples.
+func (c *Cache) expires() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.expiry()
+}
+
+// expiry returns what expires returns. The caller must hold a lock.
+func (c *Cache) expiry() time.Time {
+	expiry := c.lastFetched.Add(c.ttl)
+	if c.retryAfter.After(expiry) {
+		return c.retryAfter
+	}
+
+	return expiry
+}
+
+// afterRefresh saves samples to our snapshot store, if we have one, and calls
+// our refresh hooks with them, in the background so that requests aren't held
+// up. This is done for one refresh at a time, in the order of the refreshes,
+// so that older samples never overwrite newer ones. The caller must hold the
+// write lock, and samples must not be modified afterwards.
+func (c *Cache) afterRefresh(samples *db.TrackedSampleCollection) {
+	if c.snapshots == nil && len(c.hooks) == 0 {
+		return
 	}
 
-	// Fetch fresh data
-	samples, err := c.provider.Execute()
+	previous := c.pending
+	done := make(chan struct{})
+	c.pending = done
+
+	go func() {
+		defer close(done)
+
+		if previous != nil {
+			<-previous
+		}
+
+		if c.snapshots != nil {
+			c.saveSnapshot(samples)
+		}
+
+		for _, hook := range c.hooks {
+			hook(samples)
+		}
+	}()
+}
+
+// saveSnapshot saves samples to our snapshot store and prunes old snapshots,
+// logging any failure.
+func (c *Cache) saveSnapshot(samples *db.TrackedSampleCollection) {
+	if _, err := c.snapshots.Save(samples, c.fingerprint); err != nil {
+		log.Printf("failed to save snapshot: %v", err)
+
+		return
+	}
+
+	if c.keep <= 0 {
+		return
+	}
+
+	if _, err := c.snapshots.Prune(c.keep); err != nil {
+		log.Printf("failed to prune snapshots: %v", err)
+	}
+}
+
+// wait waits until the snapshots and hooks of every refresh so far are done,
+// or until ctx is done, returning its error.
+func (c *Cache) wait(ctx context.Context) error {
+	c.mu.RLock()
+	pending := c.pending
+	c.mu.RUnlock()
+
+	if pending == nil {
+		return nil
+	}
+
+	select {
+	case <-pending:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// refresh fetches fresh data from the provider, incrementally if possible,
+// without holding the lock, so that callers can still read the samples we
+// already have. The caller must hold refreshMu, so that only we change them.
+func (c *Cache) refresh(ctx context.Context) error {
+	started := time.Now()
+
+	if provider, ok := c.incrementalProvider(); ok {
+		delta, err := provider.ExecuteSince(ctx, c.watermark)
+		if err != nil {
+			return err
+		}
+
+		c.fetched(c.samples.Merge(delta), started, false)
+
+		return nil
+	}
+
+	samples, err := c.provider.ExecuteContext(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
+	c.fetched(samples, started, true)
+
+	return nil
+}
+
+// fetched stores the samples of a fetch that started at the given time and
+// has completed, so that the next incremental refresh looks for changes from
+// a margin before then. The caller must hold refreshMu.
+func (c *Cache) fetched(samples *db.TrackedSampleCollection, started time.Time, full bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.samples = samples
 	c.lastFetched = time.Now()
-	return c.samples, nil
+	c.retryAfter = time.Time{}
+	c.watermark = started.Add(-watermarkMargin)
+
+	if full {
+		c.lastFull = c.lastFetched
+	}
+}
+
+// incrementalProvider returns our provider as an IncrementalQueryProvider if
+// it is one and the next refresh doesn't need to be a full one.
+func (c *Cache) incrementalProvider() (db.IncrementalQueryProvider, bool) {
+	if c.fullInterval <= 0 || c.samples == nil || time.Since(c.lastFull) >= c.fullInterval {
+		return nil, false
+	}
+
+	provider, ok := c.provider.(db.IncrementalQueryProvider)
+
+	return provider, ok
 }
 
 // GetUniqueFacultySponsors returns a sorted list of unique faculty sponsors.
@@ -118,25 +366,14 @@ func GetStudiesForSponsor(samples []db.TrackedSample, sponsor string) []string {
 	return studies
 }
 
-// FilterSamples filters samples by faculty sponsor and optionally by study name.
-func FilterSamples(samples []db.TrackedSample, sponsor, study string) []db.TrackedSample {
-	if sponsor == "" {
-		return samples
-	}
-
-	var filtered []db.TrackedSample
-
-	for _, sample := range samples {
-		if sample.FacultySponsor != sponsor {
-			continue
-		}
-
-		if study != "" && sample.StudyName != study {
-			continue
-		}
+// GetStageNames returns the names of every stage, in order.
+func GetStageNames() []string {
+	stages := db.Stages()
+	names := make([]string, len(stages))
 
-		filtered = append(filtered, sample)
+	for i, stage := range stages {
+		names[i] = stage.String()
 	}
 
-	return filtered
+	return names
 }
@@ -26,11 +26,13 @@
 package server
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/wtsi-hgi/gst/db"
+	"github.com/wtsi-hgi/gst/snapshot"
 )
 
 // mockQueryProvider implements db.QueryProvider for testing.
@@ -41,7 +43,15 @@ type mockQueryProvider struct {
 }
 
 func (m *mockQueryProvider) Execute() (*db.TrackedSampleCollection, error) {
+	return m.ExecuteContext(context.Background())
+}
+
+func (m *mockQueryProvider) ExecuteContext(ctx context.Context) (*db.TrackedSampleCollection, error) {
 	m.executeCalls++
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	return m.samples, m.err
 }
 
@@ -63,7 +73,7 @@ func TestCache(t *testing.T) {
 		cache := NewCache(mockProvider, 100*time.Millisecond)
 
 		Convey("When getting samples for the first time", func() {
-			samples, err := cache.GetSamples()
+			samples, err := cache.GetSamples(context.Background())
 
 			Convey("It should fetch from the provider", func() {
 				So(err, ShouldBeNil)
@@ -72,7 +82,7 @@ func TestCache(t *testing.T) {
 			})
 
 			Convey("When getting samples again immediately", func() {
-				samples2, err := cache.GetSamples()
+				samples2, err := cache.GetSamples(context.Background())
 
 				Convey("It should use the cache and not call the provider again", func() {
 					So(err, ShouldBeNil)
@@ -83,7 +93,7 @@ func TestCache(t *testing.T) {
 
 			Convey("When getting samples after TTL expires", func() {
 				time.Sleep(150 * time.Millisecond) // Wait longer than TTL
-				samples2, err := cache.GetSamples()
+				samples2, err := cache.GetSamples(context.Background())
 
 				Convey("It should fetch from the provider again", func() {
 					So(err, ShouldBeNil)
@@ -91,6 +101,291 @@ func TestCache(t *testing.T) {
 					So(mockProvider.executeCalls, ShouldEqual, 2) // Now 2
 				})
 			})
+
+			Convey("When a refresh is cancelled after TTL expires", func() {
+				time.Sleep(150 * time.Millisecond)
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				samples2, err := cache.GetSamples(ctx)
+
+				Convey("It should return the expired samples", func() {
+					So(err, ShouldBeNil)
+					So(samples2, ShouldEqual, mockSamples)
+				})
+
+				Convey("And a later request should still refresh", func() {
+					samples2, err := cache.GetSamples(context.Background())
+					So(err, ShouldBeNil)
+					So(samples2, ShouldNotBeNil)
+					So(mockProvider.executeCalls, ShouldEqual, 3)
+				})
+			})
+
+			Convey("When a refresh fails after TTL expires", func() {
+				time.Sleep(150 * time.Millisecond)
+				mockProvider.err = db.ErrCircuitOpen
+
+				samples2, err := cache.GetSamples(context.Background())
+
+				Convey("It should return the expired samples", func() {
+					So(err, ShouldBeNil)
+					So(samples2, ShouldEqual, mockSamples)
+					So(mockProvider.executeCalls, ShouldEqual, 2)
+				})
+
+				Convey("It should wait before trying again", func() {
+					_, err = cache.GetSamples(context.Background())
+					So(err, ShouldBeNil)
+					So(mockProvider.executeCalls, ShouldEqual, 2)
+
+					time.Sleep(150 * time.Millisecond)
+					mockProvider.err = nil
+
+					_, err = cache.GetSamples(context.Background())
+					So(err, ShouldBeNil)
+					So(mockProvider.executeCalls, ShouldEqual, 3)
+				})
+			})
+		})
+
+		Convey("When the first fetch fails, it should return the error", func() {
+			mockProvider.err = db.ErrCircuitOpen
+
+			_, err := cache.GetSamples(context.Background())
+			So(err, ShouldEqual, db.ErrCircuitOpen)
+		})
+	})
+}
+
+// incrementalQueryProvider implements db.IncrementalQueryProvider for testing.
+type incrementalQueryProvider struct {
+	mockQueryProvider
+	delta      *db.TrackedSampleCollection
+	sinceCalls int
+	watermarks []time.Time
+}
+
+func (m *incrementalQueryProvider) ExecuteSince(_ context.Context,
+	watermark time.Time) (*db.TrackedSampleCollection, error) {
+	m.sinceCalls++
+	m.watermarks = append(m.watermarks, watermark)
+
+	return m.delta, m.err
+}
+
+func TestCacheIncremental(t *testing.T) {
+	Convey("Given a cache with an incremental provider and a full refresh interval", t, func() {
+		day1 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		day2 := day1.AddDate(0, 0, 1)
+
+		provider := &incrementalQueryProvider{
+			mockQueryProvider: mockQueryProvider{samples: &db.TrackedSampleCollection{
+				Samples: []db.TrackedSample{
+					{SangerSampleID: "S1", RunID: "R1", ManifestCreated: &day1},
+				},
+			}},
+			delta: &db.TrackedSampleCollection{Samples: []db.TrackedSample{
+				{SangerSampleID: "S1", RunID: "R1", ManifestCreated: &day1, LibraryStart: &day2},
+				{SangerSampleID: "S2", RunID: "R1", ManifestCreated: &day2},
+			}},
+		}
+
+		cache := NewCache(provider, 50*time.Millisecond, WithFullRefreshInterval(300*time.Millisecond))
+		ctx := context.Background()
+
+		fetchStart := time.Now()
+		samples, err := cache.GetSamples(ctx)
+		So(err, ShouldBeNil)
+		So(len(samples.Samples), ShouldEqual, 1)
+		So(provider.executeCalls, ShouldEqual, 1)
+
+		Convey("When the TTL expires before the full refresh interval", func() {
+			time.Sleep(75 * time.Millisecond)
+			samples, err := cache.GetSamples(ctx)
+
+			Convey("It should merge in the changes since a margin before the previous fetch", func() {
+				So(err, ShouldBeNil)
+				So(provider.executeCalls, ShouldEqual, 1)
+				So(provider.sinceCalls, ShouldEqual, 1)
+				So(provider.watermarks[0], ShouldHappenOnOrBetween,
+					fetchStart.Add(-watermarkMargin), time.Now().Add(-watermarkMargin))
+				So(len(samples.Samples), ShouldEqual, 2)
+				So(samples.Samples[0].LibraryStart, ShouldEqual, &day2)
+			})
+		})
+
+		Convey("When the full refresh interval has passed", func() {
+			time.Sleep(350 * time.Millisecond)
+			samples, err := cache.GetSamples(ctx)
+
+			Convey("It should do a full refresh", func() {
+				So(err, ShouldBeNil)
+				So(provider.executeCalls, ShouldEqual, 2)
+				So(provider.sinceCalls, ShouldEqual, 0)
+				So(len(samples.Samples), ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("Given a cache with an incremental provider but no full refresh interval", t, func() {
+		provider := &incrementalQueryProvider{
+			mockQueryProvider: mockQueryProvider{samples: &db.TrackedSampleCollection{}},
+		}
+		cache := NewCache(provider, time.Millisecond)
+
+		_, err := cache.GetSamples(context.Background())
+		So(err, ShouldBeNil)
+		time.Sleep(5 * time.Millisecond)
+		_, err = cache.GetSamples(context.Background())
+		So(err, ShouldBeNil)
+
+		Convey("Every refresh should be a full one", func() {
+			So(provider.executeCalls, ShouldEqual, 2)
+			So(provider.sinceCalls, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestCacheSnapshots(t *testing.T) {
+	Convey("Given a cache that saves snapshots and keeps 2", t, func() {
+		store, err := snapshot.Open(t.TempDir())
+		So(err, ShouldBeNil)
+
+		provider := &mockQueryProvider{samples: &db.TrackedSampleCollection{
+			Samples: []db.TrackedSample{{SangerSampleID: "S1"}},
+		}}
+		cache := NewCache(provider, time.Millisecond, WithSnapshots(store, "fp", 2))
+
+		Convey("When it has refreshed 3 times", func() {
+			for range 3 {
+				_, err := cache.GetSamples(context.Background())
+				So(err, ShouldBeNil)
+				time.Sleep(2 * time.Millisecond)
+			}
+
+			So(cache.wait(context.Background()), ShouldBeNil)
+
+			Convey("It should have saved the samples, keeping the newest 2 snapshots", func() {
+				infos, err := store.List()
+				So(err, ShouldBeNil)
+				So(len(infos), ShouldEqual, 2)
+				So(infos[0].Fingerprint, ShouldEqual, "fp")
+
+				loaded, err := store.Load(infos[1].ID)
+				So(err, ShouldBeNil)
+				So(loaded.Samples[0].SangerSampleID, ShouldEqual, "S1")
+			})
+		})
+	})
+}
+
+func TestCacheRefreshHook(t *testing.T) {
+	Convey("Given a cache with a refresh hook", t, func() {
+		provider := &mockQueryProvider{samples: &db.TrackedSampleCollection{
+			Samples: []db.TrackedSample{{SangerSampleID: "S1"}},
+		}}
+		refreshed := make(chan *db.TrackedSampleCollection, 2)
+		cache := NewCache(provider, time.Minute, WithRefreshHook(func(samples *db.TrackedSampleCollection) {
+			refreshed <- samples
+		}))
+
+		Convey("The hook should be called with the samples after a refresh, but not when cached", func() {
+			_, err := cache.GetSamples(context.Background())
+			So(err, ShouldBeNil)
+
+			select {
+			case samples := <-refreshed:
+				So(samples.Samples[0].SangerSampleID, ShouldEqual, "S1")
+			case <-time.After(time.Second):
+				So("hook was not called", ShouldBeEmpty)
+			}
+
+			_, err = cache.GetSamples(context.Background())
+			So(err, ShouldBeNil)
+
+			time.Sleep(10 * time.Millisecond)
+			So(len(refreshed), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestCacheRefreshHookOrder(t *testing.T) {
+	Convey("Given a cache with a hook that is slow for the first refresh", t, func() {
+		provider := &mockQueryProvider{}
+		seen := make(chan string, 3)
+		cache := NewCache(provider, time.Millisecond, WithRefreshHook(func(samples *db.TrackedSampleCollection) {
+			if samples.Samples[0].SangerSampleID == "S1" {
+				time.Sleep(50 * time.Millisecond)
+			}
+
+			seen <- samples.Samples[0].SangerSampleID
+		}))
+
+		Convey("The hook should see the refreshes in order", func() {
+			for _, id := range []string{"S1", "S2", "S3"} {
+				provider.samples = &db.TrackedSampleCollection{Samples: []db.TrackedSample{{SangerSampleID: id}}}
+				_, err := cache.GetSamples(context.Background())
+				So(err, ShouldBeNil)
+				time.Sleep(2 * time.Millisecond)
+			}
+
+			So(cache.wait(context.Background()), ShouldBeNil)
+			So([]string{<-seen, <-seen, <-seen}, ShouldResemble, []string{"S1", "S2", "S3"})
+		})
+	})
+}
+
+// blockingQueryProvider is a mockQueryProvider whose queries, after the first,
+// don't finish until release is closed.
+type blockingQueryProvider struct {
+	mockQueryProvider
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingQueryProvider) ExecuteContext(ctx context.Context) (*db.TrackedSampleCollection, error) {
+	if b.executeCalls > 0 {
+		close(b.started)
+		<-b.release
+	}
+
+	return b.mockQueryProvider.ExecuteContext(ctx)
+}
+
+func TestCacheStaleReads(t *testing.T) {
+	Convey("Given a cache with expired samples whose refresh is slow", t, func() {
+		old := &db.TrackedSampleCollection{Samples: []db.TrackedSample{{SangerSampleID: "S1"}}}
+		provider := &blockingQueryProvider{
+			mockQueryProvider: mockQueryProvider{samples: old},
+			started:           make(chan struct{}),
+			release:           make(chan struct{}),
+		}
+		cache := NewCache(provider, time.Millisecond)
+
+		_, err := cache.GetSamples(context.Background())
+		So(err, ShouldBeNil)
+		time.Sleep(2 * time.Millisecond)
+
+		refreshed := make(chan *db.TrackedSampleCollection)
+
+		go func() {
+			samples, _ := cache.GetSamples(context.Background())
+
+			refreshed <- samples
+		}()
+
+		<-provider.started
+
+		Convey("Other readers get the expired samples without waiting", func() {
+			samples, err := cache.GetSamples(context.Background())
+			So(err, ShouldBeNil)
+			So(samples, ShouldEqual, old)
+
+			fresh := &db.TrackedSampleCollection{Samples: []db.TrackedSample{{SangerSampleID: "S2"}}}
+			provider.samples = fresh
+			close(provider.release)
+			So(<-refreshed, ShouldEqual, fresh)
 		})
 	})
 }
@@ -0,0 +1,101 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/wtsi-hgi/gst/db"
+)
+
+// contentTypes are the content type of each export format.
+var contentTypes = map[db.Format]string{
+	db.FormatTSV:       "text/tab-separated-values",
+	db.FormatCSV:       "text/csv",
+	db.FormatJSON:      "application/json",
+	db.FormatJSONLines: "application/x-ndjson",
+	db.FormatXLSX:      "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	db.FormatParquet:   "application/vnd.apache.parquet",
+}
+
+// parseExport returns the format requested by the "format" query parameter
+// (xlsx by default), along with the writer options for it requested by the
+// "sheets" (study or sponsor) and "bom" (true) parameters.
+func parseExport(r *http.Request) (db.Format, []db.WriterOption, error) {
+	query := r.URL.Query()
+
+	name := query.Get("format")
+	if name == "" {
+		name = "xlsx"
+	}
+
+	format, err := db.ParseFormat(name)
+	if err != nil {
+		return format, nil, err
+	}
+
+	sheets, err := db.ParseSheetGroup(query.Get("sheets"))
+	if err != nil {
+		return format, nil, err
+	}
+
+	opts := []db.WriterOption{db.WithSheets(sheets)}
+	if query.Get("bom") == "true" {
+		opts = append(opts, db.WithBOM())
+	}
+
+	return format, opts, nil
+}
+
+// writeDownload writes samples in the given format to w as a file attachment.
+// The file is built in memory first, so that any error can still be reported.
+func writeDownload(w http.ResponseWriter, samples []db.TrackedSample, format db.Format,
+	opts ...db.WriterOption) error {
+	var buf bytes.Buffer
+
+	writer, err := db.NewSampleWriter(&buf, format, opts...)
+	if err != nil {
+		return err
+	}
+
+	for i := range samples {
+		if err = writer.Write(&samples[i]); err != nil {
+			return err
+		}
+	}
+
+	if err = writer.Close(); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", contentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"samples%s\"", format.Extension()))
+	_, err = buf.WriteTo(w)
+
+	return err
+}
@@ -26,6 +26,9 @@
 package server
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -68,6 +71,17 @@ func TestFilter(t *testing.T) {
 			})
 		})
 
+		Convey("The study parameter matches a study's name or ID without a sponsor", func() {
+			for _, study := range []string{"Study C", "9012"} {
+				f, err := parseFilter(httptest.NewRequest(http.MethodGet, "/api/samples?study="+url.QueryEscape(study), nil))
+				So(err, ShouldBeNil)
+
+				filtered := f.Apply(samples)
+				So(len(filtered), ShouldEqual, 1)
+				So(filtered[0].SangerSampleID, ShouldEqual, "SANG789")
+			}
+		})
+
 		Convey("When getting study names for a sponsor", func() {
 			studies := GetStudiesForSponsor(samples, "Sponsor 1")
 
@@ -78,26 +92,6 @@ func TestFilter(t *testing.T) {
 				So(studies, ShouldNotContain, "Study C")
 			})
 		})
-
-		Convey("When filtering samples by sponsor and study", func() {
-			filtered := FilterSamples(samples, "Sponsor 1", "Study B")
-
-			Convey("It should return only matching samples", func() {
-				So(len(filtered), ShouldEqual, 1)
-				So(filtered[0].StudyName, ShouldEqual, "Study B")
-				So(filtered[0].SangerSampleID, ShouldEqual, "SANG456")
-			})
-
-			Convey("When filtering by sponsor only", func() {
-				filtered := FilterSamples(samples, "Sponsor 2", "")
-
-				Convey("It should return all samples for that sponsor", func() {
-					So(len(filtered), ShouldEqual, 1)
-					So(filtered[0].StudyName, ShouldEqual, "Study C")
-					So(filtered[0].SangerSampleID, ShouldEqual, "SANG789")
-				})
-			})
-		})
 	})
 }
 
@@ -119,6 +113,13 @@ func TestFilterFunctions(t *testing.T) {
 			So(sponsors, ShouldContain, "Sponsor C")
 		})
 
+		Convey("GetStageNames should return every stage in order", func() {
+			stages := GetStageNames()
+			So(len(stages), ShouldEqual, 8)
+			So(stages[0], ShouldEqual, "manifest created")
+			So(stages[7], ShouldEqual, "QC complete")
+		})
+
 		Convey("GetStudiesForSponsor should return studies for the specified sponsor", func() {
 			studies := GetStudiesForSponsor(samples, "Sponsor A")
 			So(len(studies), ShouldEqual, 2)
@@ -0,0 +1,88 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// startRefreshing starts refreshing the cache in the background: once now,
+// then whenever it expires, until stopRefreshing is called.
+func (s *Server) startRefreshing() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	s.refreshMu.Lock()
+	s.stopRefresh = func() {
+		cancel()
+		<-done
+	}
+	s.refreshMu.Unlock()
+
+	go func() {
+		defer close(done)
+
+		s.refreshWhenStale(ctx)
+	}()
+}
+
+// stopRefreshing stops the background refreshes started by startRefreshing,
+// waiting for one in progress to be abandoned. It does nothing if they weren't
+// started.
+func (s *Server) stopRefreshing() {
+	s.refreshMu.Lock()
+	stop := s.stopRefresh
+	s.stopRefresh = nil
+	s.refreshMu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+}
+
+// refreshWhenStale refreshes the cache now and then whenever it expires, until
+// ctx is done, so a refresh is put off if a request refreshed the cache in the
+// meantime. Failures are logged, and tried again after CacheTTL.
+func (s *Server) refreshWhenStale(ctx context.Context) {
+	for {
+		if _, err := s.cache.GetSamples(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("background cache refresh failed: %v", err)
+		}
+
+		wait := time.Until(s.cache.expires())
+		if wait <= 0 {
+			wait = s.config.CacheTTL
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
@@ -28,6 +28,7 @@ package server
 import (
 	"bytes"
 	"html/template"
+	"strings"
 	"testing"
 	"time"
 
@@ -69,12 +70,10 @@ func TestSamplesTableTemplate(t *testing.T) {
 				QCPass:               "1",
 			}
 
-			data := struct {
-				HasData bool
-				Samples []db.TrackedSample
-			}{
+			data := tableData{
 				HasData: true,
 				Samples: []db.TrackedSample{sample},
+				Now:     now.Add(50 * time.Hour),
 			}
 
 			var buf bytes.Buffer
@@ -104,6 +103,8 @@ func TestSamplesTableTemplate(t *testing.T) {
 				So(output, ShouldContainSubstring, "Pipeline")
 				So(output, ShouldContainSubstring, "Sequencing Run Start")
 				So(output, ShouldContainSubstring, "Sequencing QC Complete")
+				So(output, ShouldContainSubstring, "<th>Stage</th>")
+				So(output, ShouldContainSubstring, "<th>Days in Stage</th>")
 
 				// Check that data is displayed
 				So(output, ShouldContainSubstring, "SANG123")
@@ -112,6 +113,12 @@ func TestSamplesTableTemplate(t *testing.T) {
 				So(output, ShouldContainSubstring, "RUN001")
 				So(output, ShouldContainSubstring, "Illumina")
 				So(output, ShouldContainSubstring, "Pipeline1")
+				So(output, ShouldContainSubstring, "<td>QC complete</td>")
+				So(output, ShouldContainSubstring, "<td>2</td>")
+			})
+
+			Convey("It should not highlight samples that aren't overdue", func() {
+				So(output, ShouldNotContainSubstring, "overdue")
 			})
 
 			Convey("It should not contain the excluded fields", func() {
@@ -121,5 +128,25 @@ func TestSamplesTableTemplate(t *testing.T) {
 				So(output, ShouldNotContainSubstring, "<th>Programme</th>")
 			})
 		})
+
+		Convey("When rendering with an overdue sample", func() {
+			data := tableData{
+				HasData: true,
+				Samples: []db.TrackedSample{{SangerSampleID: "LATE"}, {SangerSampleID: "ONTIME"}},
+				Now:     time.Now(),
+				Overdue: []string{"Overdue: 3.0 days in ordered, SLA is 2", ""},
+			}
+
+			var buf bytes.Buffer
+			err := tmpl.Execute(&buf, data)
+			So(err, ShouldBeNil)
+
+			Convey("It should highlight just that sample's row", func() {
+				output := buf.String()
+				So(output, ShouldContainSubstring,
+					`<tr class="overdue" title="Overdue: 3.0 days in ordered, SLA is 2">`)
+				So(strings.Count(output, `class="overdue"`), ShouldEqual, 1)
+			})
+		})
 	})
 }
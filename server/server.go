@@ -26,17 +26,26 @@
 package server
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"io/fs"
+	"log"
 	"net/http"
 	"path"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/wtsi-hgi/gst/db"
+	"github.com/wtsi-hgi/gst/metrics"
+	"github.com/wtsi-hgi/gst/sla"
+	"github.com/wtsi-hgi/gst/snapshot"
 )
 
 //go:embed static/*.html static/*.css static/*.js
@@ -52,8 +61,44 @@ type Config struct {
 
 	// CacheTTL is how long to cache data before refreshing.
 	CacheTTL time.Duration
+
+	// FullRefreshInterval, if non-zero, makes cache refreshes fetch only
+	// changed samples, with a full refresh at this interval to pick up
+	// deletions.
+	FullRefreshInterval time.Duration
+
+	// Snapshots, if set, stores a snapshot of the samples after every cache
+	// refresh.
+	Snapshots *snapshot.Store
+
+	// SnapshotFingerprint identifies the query in saved snapshots.
+	SnapshotFingerprint string
+
+	// SnapshotKeep, if non-zero, is how many of the newest snapshots to keep.
+	SnapshotKeep int
+
+	// Calendar decides which days are working days when turnaround is
+	// measured in working days. Defaults to weekdays with no holidays.
+	Calendar *metrics.Calendar
+
+	// SLA, if not nil, are the turnaround targets that samples are checked
+	// against, with overdue samples highlighted and listed by /api/overdue.
+	SLA *sla.Rules
+
+	// Notifier, if not nil, is given the samples after every cache refresh,
+	// so that it can notify people about breaches.
+	Notifier Notifier
 }
 
+// Notifier is something that wants to know about the samples after every
+// cache refresh, such as a notify.Notifier.
+type Notifier interface {
+	Notify(ctx context.Context, samples []db.TrackedSample, now time.Time) error
+}
+
+// notifyTimeout is how long a Notifier is given to finish after a refresh.
+const notifyTimeout = time.Minute
+
 // Server handles HTTP requests for the sample tracking dashboard.
 type Server struct {
 	config    Config
@@ -61,20 +106,80 @@ type Server struct {
 	templates *template.Template
 	mux       *http.ServeMux
 	staticFS  fs.FS
+	http      *http.Server
+
+	refreshMu   sync.Mutex
+	stopRefresh func()
 }
 
 // ChartData represents the data structure used for the Chart.js visualization.
 type ChartData struct {
 	Labels         []string `json:"labels"`
 	SampleIds      []string `json:"sampleIds"`
+	Stages         []string `json:"stages"`
 	LibraryTime    []int    `json:"libraryTime"`
 	SequencingTime []int    `json:"sequencingTime"`
+
+	// Turnaround holds each sample's time between the requested milestones,
+	// if any were requested, with 0 for samples that haven't reached both.
+	Turnaround      []float64 `json:"turnaround,omitempty"`
+	TurnaroundLabel string    `json:"turnaroundLabel,omitempty"`
 }
 
 // FilterResponse contains data for populating filter dropdowns.
 type FilterResponse struct {
 	FacultySponsors []string `json:"facultySponsors"`
 	Studies         []string `json:"studies,omitempty"`
+	Stages          []string `json:"stages,omitempty"`
+	Milestones      []string `json:"milestones,omitempty"`
+	Units           []string `json:"units,omitempty"`
+}
+
+// OverdueResponse represents the samples that have breached their SLA.
+type OverdueResponse struct {
+	Overdue []sla.Breach `json:"overdue"`
+}
+
+// QualityResponse represents the data problems found in samples.
+type QualityResponse struct {
+	Violations []db.Violation `json:"violations"`
+}
+
+// SampleResponse represents every run of a single sample, with their
+// platform-specific metrics where available.
+type SampleResponse struct {
+	Samples []db.TrackedSample `json:"samples"`
+}
+
+// HealthResponse represents whether the database was reachable when last
+// checked.
+type HealthResponse struct {
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// StatsResponse represents the turnaround statistics of groups of samples.
+type StatsResponse struct {
+	GroupBy string               `json:"groupBy"`
+	Groups  []metrics.GroupStats `json:"groups"`
+}
+
+// tableData is the data used to render the samples table template.
+type tableData struct {
+	HasData bool
+	Samples []db.TrackedSample
+
+	// Now is the time that times in stage are calculated as of.
+	Now time.Time
+
+	// TurnaroundLabel, if not empty, heads a column of the formatted
+	// Turnaround of each sample.
+	TurnaroundLabel string
+	Turnaround      []string
+
+	// Overdue, if not nil, describes each sample's SLA breach, or is empty for
+	// samples that aren't overdue.
+	Overdue []string
 }
 
 // New creates a new Server with the given configuration.
@@ -89,6 +194,11 @@ func New(config Config) (*Server, error) {
 		config.CacheTTL = 5 * time.Minute
 	}
 
+	// Set default calendar if not specified
+	if config.Calendar == nil {
+		config.Calendar = metrics.NewCalendar()
+	}
+
 	// Extract static files directory
 	staticDir, err := fs.Sub(staticFiles, "static")
 	if err != nil {
@@ -102,7 +212,16 @@ func New(config Config) (*Server, error) {
 	}
 
 	// Create cache
-	cache := NewCache(config.QueryProvider, config.CacheTTL)
+	cacheOpts := []CacheOption{
+		WithFullRefreshInterval(config.FullRefreshInterval),
+		WithSnapshots(config.Snapshots, config.SnapshotFingerprint, config.SnapshotKeep),
+	}
+
+	if config.Notifier != nil {
+		cacheOpts = append(cacheOpts, WithRefreshHook(notifyHook(config.Notifier)))
+	}
+
+	cache := NewCache(config.QueryProvider, config.CacheTTL, cacheOpts...)
 
 	// Create server
 	server := &Server{
@@ -113,6 +232,8 @@ func New(config Config) (*Server, error) {
 		staticFS:  staticDir,
 	}
 
+	server.http = &http.Server{Addr: fmt.Sprintf(":%d", config.Port), Handler: server}
+
 	// Register routes
 	server.registerRoutes()
 
@@ -123,9 +244,15 @@ func New(config Config) (*Server, error) {
 func (s *Server) registerRoutes() {
 	// API routes
 	s.mux.HandleFunc("/api/samples", s.handleSamples)
+	s.mux.HandleFunc("/api/sample", s.handleSample)
 	s.mux.HandleFunc("/api/chart", s.handleChart)
 	s.mux.HandleFunc("/api/filters", s.handleFilters)
 	s.mux.HandleFunc("/api/studies", s.handleStudies)
+	s.mux.HandleFunc("/api/stats", s.handleStats)
+	s.mux.HandleFunc("/api/overdue", s.handleOverdue)
+	s.mux.HandleFunc("/api/quality", s.handleQuality)
+	s.mux.HandleFunc("/api/export", s.handleExport)
+	s.mux.HandleFunc("/api/health", s.handleHealth)
 
 	// Static files route
 	s.mux.HandleFunc("/static/", s.handleStaticFiles)
@@ -156,6 +283,19 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.mux.ServeHTTP(w, r)
 }
 
+// notifyHook returns a cache refresh hook that passes the samples to n,
+// logging any failure.
+func notifyHook(n Notifier) func(*db.TrackedSampleCollection) {
+	return func(samples *db.TrackedSampleCollection) {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+
+		if err := n.Notify(ctx, samples.Samples, time.Now()); err != nil {
+			log.Printf("failed to send notifications: %v", err)
+		}
+	}
+}
+
 // handleIndex serves the main dashboard HTML page.
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
@@ -179,10 +319,7 @@ func (s *Server) handleSamples(w http.ResponseWriter, r *http.Request) {
 	// Ensure both filters are provided
 	if sponsor == "" || study == "" {
 		// Return template with HasData = false
-		templateData := struct {
-			HasData bool
-			Samples []db.TrackedSample
-		}{
+		templateData := tableData{
 			HasData: false,
 			Samples: nil,
 		}
@@ -195,24 +332,49 @@ func (s *Server) handleSamples(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	turnaround, err := parseTurnaround(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	view, err := parseView(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sampleFilter, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Get sample data from cache
-	samplesData, err := s.cache.GetSamples()
+	samplesData, err := s.cache.GetSamples(r.Context())
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error retrieving sample data: %v", err),
 			http.StatusInternalServerError)
 		return
 	}
 
-	// Apply filters (now both are required)
-	filteredSamples := FilterSamples(samplesData.Samples, sponsor, study)
+	// Apply filters (sponsor and study are required, the others are optional)
+	filteredSamples := sampleFilter.Apply(view.apply(samplesData.Samples))
 
 	// Create template data
-	templateData := struct {
-		HasData bool
-		Samples []db.TrackedSample
-	}{
+	templateData := tableData{
 		HasData: true,
 		Samples: filteredSamples,
+		Now:     time.Now(),
+	}
+
+	if turnaround != nil {
+		templateData.TurnaroundLabel = turnaround.label()
+		templateData.Turnaround = turnaround.formatted(s.config.Calendar, filteredSamples)
+	}
+
+	if s.config.SLA != nil {
+		templateData.Overdue = describeOverdue(s.config.SLA, filteredSamples, templateData.Now)
 	}
 
 	// Render table template
@@ -223,6 +385,65 @@ func (s *Server) handleSamples(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleSample provides the runs of the sample with the Sanger sample ID given
+// by the "id" parameter, for drilling down into it. Their platform-specific
+// metrics are fetched if the QueryProvider is a db.DetailQueryProvider, and
+// otherwise are only present if the samples already had them.
+func (s *Server) handleSample(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "missing id parameter", http.StatusBadRequest)
+		return
+	}
+
+	samplesData, err := s.cache.GetSamples(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving sample data: %v", err),
+			http.StatusInternalServerError)
+		return
+	}
+
+	response := SampleResponse{Samples: sampleRuns(samplesData.Samples, id)}
+	if len(response.Samples) == 0 {
+		http.Error(w, fmt.Sprintf("sample %q not found", id), http.StatusNotFound)
+		return
+	}
+
+	if details, ok := s.config.QueryProvider.(db.DetailQueryProvider); ok {
+		if err := details.AddDetails(r.Context(), response.Samples); err != nil {
+			http.Error(w, fmt.Sprintf("Error retrieving sample details: %v", err),
+				http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, fmt.Sprintf("Error encoding JSON: %v", err),
+			http.StatusInternalServerError)
+	}
+}
+
+// sampleRuns returns copies of the samples with the given Sanger sample ID,
+// including their slices of platform details, so that they can be given
+// details without changing the cached samples.
+func sampleRuns(samples []db.TrackedSample, id string) []db.TrackedSample {
+	var runs []db.TrackedSample
+
+	for _, sample := range samples {
+		if sample.SangerSampleID == id {
+			sample.Illumina = slices.Clone(sample.Illumina)
+			sample.PacBio = slices.Clone(sample.PacBio)
+			sample.ONT = slices.Clone(sample.ONT)
+			runs = append(runs, sample)
+		}
+	}
+
+	return runs
+}
+
 // handleChart provides JSON data for the Chart.js visualization.
 func (s *Server) handleChart(w http.ResponseWriter, r *http.Request) {
 	// Get required filter parameters
@@ -234,6 +455,7 @@ func (s *Server) handleChart(w http.ResponseWriter, r *http.Request) {
 		emptyChart := ChartData{
 			Labels:         []string{},
 			SampleIds:      []string{},
+			Stages:         []string{},
 			LibraryTime:    []int{},
 			SequencingTime: []int{},
 		}
@@ -246,8 +468,26 @@ func (s *Server) handleChart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	turnaround, err := parseTurnaround(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	view, err := parseView(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sampleFilter, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Get sample data from cache
-	samplesData, err := s.cache.GetSamples()
+	samplesData, err := s.cache.GetSamples(r.Context())
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error retrieving sample data: %v", err),
 			http.StatusInternalServerError)
@@ -255,10 +495,14 @@ func (s *Server) handleChart(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Apply filters
-	filteredSamples := FilterSamples(samplesData.Samples, sponsor, study)
+	filteredSamples := sampleFilter.Apply(view.apply(samplesData.Samples))
 
 	// Prepare chart data
 	chartData := prepareChartData(filteredSamples)
+	if turnaround != nil {
+		chartData.Turnaround, _ = turnaround.values(s.config.Calendar, filteredSamples)
+		chartData.TurnaroundLabel = turnaround.label()
+	}
 
 	// Return as JSON
 	w.Header().Set("Content-Type", "application/json")
@@ -271,7 +515,7 @@ func (s *Server) handleChart(w http.ResponseWriter, r *http.Request) {
 // handleFilters provides a list of faculty sponsors for filtering.
 func (s *Server) handleFilters(w http.ResponseWriter, r *http.Request) {
 	// Get sample data from cache
-	samplesData, err := s.cache.GetSamples()
+	samplesData, err := s.cache.GetSamples(r.Context())
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error retrieving sample data: %v", err),
 			http.StatusInternalServerError)
@@ -284,6 +528,9 @@ func (s *Server) handleFilters(w http.ResponseWriter, r *http.Request) {
 	// Create response with explicitly initialized array
 	response := FilterResponse{
 		FacultySponsors: make([]string, len(sponsors)),
+		Stages:          GetStageNames(),
+		Milestones:      getMilestoneNames(),
+		Units:           getUnitNames(),
 	}
 	copy(response.FacultySponsors, sponsors)
 
@@ -312,7 +559,7 @@ func (s *Server) handleStudies(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get sample data from cache
-	samplesData, err := s.cache.GetSamples()
+	samplesData, err := s.cache.GetSamples(r.Context())
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error retrieving sample data: %v", err),
 			http.StatusInternalServerError)
@@ -343,11 +590,193 @@ func (s *Server) handleStudies(w http.ResponseWriter, r *http.Request) {
 	w.Write(jsonData)
 }
 
+// handleStats provides turnaround statistics of the samples grouped on the
+// field given by the "by" parameter (sponsor by default), of the samples
+// matching the filter parameters that parseFilter reads, like the other
+// endpoints.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	by := metrics.GroupBySponsor
+	if name := query.Get("by"); name != "" {
+		var err error
+		if by, err = metrics.ParseGroupBy(name); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	view, err := parseView(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sampleFilter, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get sample data from cache
+	samplesData, err := s.cache.GetSamples(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving sample data: %v", err),
+			http.StatusInternalServerError)
+		return
+	}
+
+	filteredSamples := sampleFilter.Apply(view.apply(samplesData.Samples))
+
+	response := StatsResponse{
+		GroupBy: by.String(),
+		Groups:  metrics.Stats(filteredSamples, by),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, fmt.Sprintf("Error encoding JSON: %v", err),
+			http.StatusInternalServerError)
+	}
+}
+
+// handleOverdue provides the samples that have been in their current stage for
+// longer than the SLA allows, of the samples matching the filter parameters
+// like the other endpoints. It is empty if there is no SLA.
+func (s *Server) handleOverdue(w http.ResponseWriter, r *http.Request) {
+	view, err := parseView(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sampleFilter, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get sample data from cache
+	samplesData, err := s.cache.GetSamples(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving sample data: %v", err),
+			http.StatusInternalServerError)
+		return
+	}
+
+	response := OverdueResponse{Overdue: []sla.Breach{}}
+
+	if s.config.SLA != nil {
+		filteredSamples := sampleFilter.Apply(view.apply(samplesData.Samples))
+
+		if breaches := s.config.SLA.Overdue(filteredSamples, time.Now()); breaches != nil {
+			response.Overdue = breaches
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, fmt.Sprintf("Error encoding JSON: %v", err),
+			http.StatusInternalServerError)
+	}
+}
+
+// handleQuality provides the data problems found in the samples by the
+// validation rules named in the comma separated "rules" parameter, or all of
+// them, in the samples matching the filter parameters like the other
+// endpoints.
+func (s *Server) handleQuality(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var names []string
+	if query.Get("rules") != "" {
+		names = strings.Split(query.Get("rules"), ",")
+	}
+
+	rules, err := db.SelectValidationRules(names...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sampleFilter, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get sample data from cache
+	samplesData, err := s.cache.GetSamples(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving sample data: %v", err),
+			http.StatusInternalServerError)
+		return
+	}
+
+	filtered := &db.TrackedSampleCollection{Samples: sampleFilter.Apply(samplesData.Samples)}
+
+	response := QualityResponse{Violations: []db.Violation{}}
+	if violations := filtered.Validate(rules...); violations != nil {
+		response.Violations = violations
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, fmt.Sprintf("Error encoding JSON: %v", err),
+			http.StatusInternalServerError)
+	}
+}
+
+// handleHealth reports whether the database was reachable when the
+// QueryProvider last checked, with a 503 status if not. Providers that don't
+// check, like mock data, are always healthy.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	response := HealthResponse{Healthy: true}
+	status := http.StatusOK
+
+	if checker, ok := s.config.QueryProvider.(db.HealthChecker); ok {
+		if err := checker.Health(); err != nil {
+			response = HealthResponse{Error: err.Error()}
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("failed to write health response: %v", err)
+	}
+}
+
+// describeOverdue returns a description of each sample's SLA breach as of now,
+// or an empty string for samples that aren't overdue.
+func describeOverdue(rules *sla.Rules, samples []db.TrackedSample, now time.Time) []string {
+	overdue := make([]string, len(samples))
+
+	for i := range samples {
+		if b, ok := rules.Check(&samples[i], now); ok {
+			overdue[i] = fmt.Sprintf("Overdue: %.1f %s in %s, SLA is %g",
+				b.Elapsed, b.Unit, b.Stage, b.Max)
+		}
+	}
+
+	return overdue
+}
+
 // prepareChartData converts sample data into a format suitable for Chart.js.
 func prepareChartData(samples []db.TrackedSample) ChartData {
 	chartData := ChartData{
 		Labels:         make([]string, 0, len(samples)),
 		SampleIds:      make([]string, 0, len(samples)),
+		Stages:         make([]string, 0, len(samples)),
 		LibraryTime:    make([]int, 0, len(samples)),
 		SequencingTime: make([]int, 0, len(samples)),
 	}
@@ -357,6 +786,7 @@ func prepareChartData(samples []db.TrackedSample) ChartData {
 		chartData.Labels = append(chartData.Labels, sample.SupplierName)
 		// Keep SangerSampleID for tooltip reference
 		chartData.SampleIds = append(chartData.SampleIds, sample.SangerSampleID)
+		chartData.Stages = append(chartData.Stages, sample.Stage().String())
 
 		// Handle potential nil values
 		libTime := 0
@@ -375,7 +805,73 @@ func prepareChartData(samples []db.TrackedSample) ChartData {
 	return chartData
 }
 
-// Start starts the HTTP server on the configured port.
+// Start starts the HTTP server on the configured port, and refreshes the cache
+// in the background whenever it expires, so that snapshots and notifications
+// happen even when nobody is looking at the dashboard. It returns nil once Shutdown
+// has been called.
 func (s *Server) Start() error {
-	return http.ListenAndServe(fmt.Sprintf(":%d", s.config.Port), s)
+	s.startRefreshing()
+
+	if err := s.http.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		s.stopRefreshing()
+
+		return err
+	}
+
+	return nil
+}
+
+// Shutdown stops the server and its background refreshes, waiting for
+// requests in progress to finish and for snapshots and notifications of
+// refreshes to be done, until ctx is done. It then closes the QueryProvider if
+// it is an io.Closer.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.http.Shutdown(ctx)
+
+	s.stopRefreshing()
+	err = errors.Join(err, s.cache.wait(ctx))
+
+	if closer, ok := s.config.QueryProvider.(io.Closer); ok {
+		err = errors.Join(err, closer.Close())
+	}
+
+	return err
+}
+
+// handleExport provides the samples as a file to download, in the format
+// given by the "format" parameter (an Excel workbook by default), of the
+// samples matching the filter parameters like the other endpoints.
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	format, opts, err := parseExport(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	view, err := parseView(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sampleFilter, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get sample data from cache
+	samplesData, err := s.cache.GetSamples(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving sample data: %v", err),
+			http.StatusInternalServerError)
+		return
+	}
+
+	filteredSamples := sampleFilter.Apply(view.apply(samplesData.Samples))
+
+	if err := writeDownload(w, filteredSamples, format, opts...); err != nil {
+		http.Error(w, fmt.Sprintf("Error exporting samples: %v", err),
+			http.StatusInternalServerError)
+	}
 }
@@ -26,16 +26,22 @@
 package server_test
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/wtsi-hgi/gst/db"
+	"github.com/wtsi-hgi/gst/metrics"
 	"github.com/wtsi-hgi/gst/server"
+	"github.com/wtsi-hgi/gst/sla"
+	"github.com/xuri/excelize/v2"
 )
 
 // mockQueryProvider implements db.QueryProvider for testing.
@@ -48,6 +54,10 @@ func (m *mockQueryProvider) Execute() (*db.TrackedSampleCollection, error) {
 	return m.samples, m.err
 }
 
+func (m *mockQueryProvider) ExecuteContext(_ context.Context) (*db.TrackedSampleCollection, error) {
+	return m.samples, m.err
+}
+
 // getAvailablePort returns a random available port by asking the OS to assign one.
 func getAvailablePort() (int, error) {
 	addr, err := net.ResolveTCPAddr("tcp", "localhost:0")
@@ -165,6 +175,15 @@ func TestServer(t *testing.T) {
 				So(response.FacultySponsors, ShouldContain, "Test Sponsor")
 				So(response.FacultySponsors, ShouldContain, "Another Sponsor")
 			})
+
+			Convey("It should list the stages that can be filtered on", func() {
+				var response struct {
+					Stages []string `json:"stages"`
+				}
+				err := json.Unmarshal(resp.Body.Bytes(), &response)
+				So(err, ShouldBeNil)
+				So(response.Stages, ShouldContain, "library in progress")
+			})
 		})
 
 		Convey("When requesting studies for a sponsor", func() {
@@ -261,7 +280,557 @@ func TestServer(t *testing.T) {
 				So(body, ShouldContainSubstring, "5")  // LibraryTime value
 				So(body, ShouldContainSubstring, "10") // SequencingTime value
 				So(body, ShouldNotContainSubstring, "SANG456")
+				So(body, ShouldContainSubstring, `"stages":["QC complete"]`)
+			})
+		})
+
+		Convey("When requesting the chart data with a turnaround between milestones", func() {
+			req := httptest.NewRequest("GET", "/api/chart?sponsor=Test+Sponsor&study=Test+Study"+
+				"&from=ManifestCreated&to=SequencingQCComplete&unit=working+days", nil)
+			resp := httptest.NewRecorder()
+
+			srv.ServeHTTP(resp, req)
+
+			Convey("It should contain the turnaround of each sample", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+
+				var chartData server.ChartData
+				err := json.Unmarshal(resp.Body.Bytes(), &chartData)
+				So(err, ShouldBeNil)
+				So(chartData.TurnaroundLabel, ShouldEqual,
+					"ManifestCreated to SequencingQCComplete (working days)")
+				So(chartData.Turnaround, ShouldResemble, []float64{0})
+			})
+		})
+
+		Convey("When requesting the sample data with a turnaround between milestones", func() {
+			req := httptest.NewRequest("GET", "/api/samples?sponsor=Test+Sponsor&study=Test+Study"+
+				"&from=LibraryStart&to=LibraryComplete&unit=hours", nil)
+			resp := httptest.NewRecorder()
+
+			srv.ServeHTTP(resp, req)
+
+			Convey("It should include a turnaround column", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(resp.Body.String(), ShouldContainSubstring,
+					"Turnaround: LibraryStart to LibraryComplete (hours)")
+				So(resp.Body.String(), ShouldContainSubstring, "<td>0.0</td>")
+			})
+		})
+
+		Convey("When requesting a turnaround from an unknown milestone", func() {
+			req := httptest.NewRequest("GET", "/api/chart?sponsor=Test+Sponsor&study=Test+Study"+
+				"&from=nonsense&to=LibraryComplete", nil)
+			resp := httptest.NewRecorder()
+
+			srv.ServeHTTP(resp, req)
+
+			Convey("It should return 400 Bad Request", func() {
+				So(resp.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When requesting turnaround statistics by platform", func() {
+			req := httptest.NewRequest("GET", "/api/stats?by=platform", nil)
+			resp := httptest.NewRecorder()
+
+			srv.ServeHTTP(resp, req)
+
+			Convey("It should summarise each platform's samples", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+
+				var stats server.StatsResponse
+				err := json.Unmarshal(resp.Body.Bytes(), &stats)
+				So(err, ShouldBeNil)
+				So(stats.GroupBy, ShouldEqual, "platform")
+				So(len(stats.Groups), ShouldEqual, 2)
+				So(stats.Groups[0].Group, ShouldEqual, "")
+				So(stats.Groups[1].Group, ShouldEqual, "Illumina")
+				So(stats.Groups[1].LibraryTime.Count, ShouldEqual, 1)
+				So(stats.Groups[1].LibraryTime.Median, ShouldEqual, 5)
+				So(stats.Groups[1].SequencingTime.Max, ShouldEqual, 10)
+				So(stats.Groups[1].EndToEnd.Count, ShouldEqual, 1)
+				So(stats.Groups[0].EndToEnd.Count, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When requesting turnaround statistics for one sponsor", func() {
+			req := httptest.NewRequest("GET", "/api/stats?sponsor=Another+Sponsor", nil)
+			resp := httptest.NewRecorder()
+
+			srv.ServeHTTP(resp, req)
+
+			Convey("It should only summarise that sponsor's samples", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+
+				var stats server.StatsResponse
+				err := json.Unmarshal(resp.Body.Bytes(), &stats)
+				So(err, ShouldBeNil)
+				So(stats.GroupBy, ShouldEqual, "sponsor")
+				So(len(stats.Groups), ShouldEqual, 1)
+				So(stats.Groups[0].Group, ShouldEqual, "Another Sponsor")
+			})
+		})
+
+		Convey("When requesting turnaround statistics grouped on an unknown field", func() {
+			req := httptest.NewRequest("GET", "/api/stats?by=colour", nil)
+			resp := httptest.NewRecorder()
+
+			srv.ServeHTTP(resp, req)
+
+			Convey("It should return 400 Bad Request", func() {
+				So(resp.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When requesting overdue samples without an SLA", func() {
+			req := httptest.NewRequest("GET", "/api/overdue", nil)
+			resp := httptest.NewRecorder()
+
+			srv.ServeHTTP(resp, req)
+
+			Convey("It should return none", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(resp.Body.String(), ShouldEqual, `{"overdue":[]}`+"\n")
+			})
+		})
+
+		Convey("Given an SLA that QC complete samples break", func() {
+			rules, errs := sla.New([]sla.Rule{{Stage: "QC complete", Max: 1}}, metrics.NewCalendar())
+			So(errs, ShouldBeNil)
+
+			srv, err = server.New(server.Config{
+				QueryProvider: mockProvider,
+				Port:          port,
+				SLA:           rules,
+			})
+			So(err, ShouldBeNil)
+
+			Convey("Requesting overdue samples should list them", func() {
+				req := httptest.NewRequest("GET", "/api/overdue?sponsor=Test+Sponsor", nil)
+				resp := httptest.NewRecorder()
+
+				srv.ServeHTTP(resp, req)
+				So(resp.Code, ShouldEqual, http.StatusOK)
+
+				var overdue server.OverdueResponse
+				err := json.Unmarshal(resp.Body.Bytes(), &overdue)
+				So(err, ShouldBeNil)
+				So(len(overdue.Overdue), ShouldEqual, 1)
+				So(overdue.Overdue[0].Sample.SangerSampleID, ShouldEqual, "SANG123")
+				So(overdue.Overdue[0].Stage, ShouldEqual, "QC complete")
+			})
+
+			Convey("The sample table should highlight them", func() {
+				req := httptest.NewRequest("GET", "/api/samples?sponsor=Test+Sponsor&study=Test+Study", nil)
+				resp := httptest.NewRecorder()
+
+				srv.ServeHTTP(resp, req)
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(resp.Body.String(), ShouldContainSubstring, `class="overdue"`)
+			})
+		})
+
+		Convey("When requesting a data quality report", func() {
+			req := httptest.NewRequest("GET", "/api/quality", nil)
+			resp := httptest.NewRecorder()
+
+			srv.ServeHTTP(resp, req)
+
+			Convey("It should find no problems in good data", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(resp.Body.String(), ShouldEqual, `{"violations":[]}`+"\n")
+			})
+		})
+
+		Convey("Given a sample whose library completed before it started", func() {
+			before := sampleTime.AddDate(0, 0, -1)
+			mockSamples.Samples[0].LibraryComplete = &before
+
+			Convey("A data quality report should list it", func() {
+				req := httptest.NewRequest("GET", "/api/quality?sponsor=Test+Sponsor", nil)
+				resp := httptest.NewRecorder()
+
+				srv.ServeHTTP(resp, req)
+				So(resp.Code, ShouldEqual, http.StatusOK)
+
+				var quality server.QualityResponse
+				err := json.Unmarshal(resp.Body.Bytes(), &quality)
+				So(err, ShouldBeNil)
+				So(len(quality.Violations), ShouldEqual, 1)
+				So(quality.Violations[0].Rule, ShouldEqual, "library-completed-before-start")
+				So(quality.Violations[0].Sample.SangerSampleID, ShouldEqual, "SANG123")
+			})
+
+			Convey("A report for other rules should not list it", func() {
+				req := httptest.NewRequest("GET", "/api/quality?rules=negative-library-time", nil)
+				resp := httptest.NewRecorder()
+
+				srv.ServeHTTP(resp, req)
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(resp.Body.String(), ShouldEqual, `{"violations":[]}`+"\n")
+			})
+
+			Convey("A report for an unknown rule should be rejected", func() {
+				req := httptest.NewRequest("GET", "/api/quality?rules=nonsense", nil)
+				resp := httptest.NewRecorder()
+
+				srv.ServeHTTP(resp, req)
+				So(resp.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When downloading the samples as a workbook", func() {
+			req := httptest.NewRequest("GET", "/api/export?sponsor=Test+Sponsor&sheets=study", nil)
+			resp := httptest.NewRecorder()
+
+			srv.ServeHTTP(resp, req)
+
+			Convey("It should be an XLSX attachment with the samples", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(resp.Header().Get("Content-Disposition"), ShouldEqual, `attachment; filename="samples.xlsx"`)
+
+				f, err := excelize.OpenReader(resp.Body)
+				So(err, ShouldBeNil)
+				defer f.Close()
+
+				So(len(f.GetSheetList()), ShouldEqual, 3)
+
+				rows, err := f.GetRows("Samples")
+				So(err, ShouldBeNil)
+				So(len(rows), ShouldEqual, 2)
+				So(rows[1], ShouldContain, "SANG123")
+			})
+		})
+
+		Convey("When downloading the samples in another format", func() {
+			req := httptest.NewRequest("GET", "/api/export?format=jsonl&sponsor=Test+Sponsor&study=Other+Study", nil)
+			resp := httptest.NewRecorder()
+
+			srv.ServeHTTP(resp, req)
+
+			Convey("It should be in that format, filtered", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(resp.Header().Get("Content-Type"), ShouldEqual, "application/x-ndjson")
+				So(resp.Body.Len(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When filtering on other fields", func() {
+			req := httptest.NewRequest("GET", "/api/export?format=jsonl&programme=Nonsense", nil)
+			resp := httptest.NewRecorder()
+
+			srv.ServeHTTP(resp, req)
+
+			Convey("The filters should apply", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(resp.Body.Len(), ShouldEqual, 0)
+			})
+
+			Convey("Bad times should be rejected", func() {
+				req := httptest.NewRequest("GET", "/api/stats?since=tomorrow", nil)
+				resp := httptest.NewRecorder()
+
+				srv.ServeHTTP(resp, req)
+				So(resp.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When downloading the samples in an unknown format", func() {
+			req := httptest.NewRequest("GET", "/api/export?format=pdf", nil)
+			resp := httptest.NewRecorder()
+
+			srv.ServeHTTP(resp, req)
+
+			So(resp.Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("When requesting the sample data for a stage the samples are not in", func() {
+			req := httptest.NewRequest("GET", "/api/samples?sponsor=Test+Sponsor&study=Test+Study&stage=ordered", nil)
+			resp := httptest.NewRecorder()
+
+			srv.ServeHTTP(resp, req)
+
+			Convey("It should not contain those samples", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(resp.Body.String(), ShouldNotContainSubstring, "SANG123")
+				So(resp.Body.String(), ShouldContainSubstring, "No samples found")
 			})
 		})
 	})
 }
+
+func TestSampleView(t *testing.T) {
+	Convey("Given a server with a sample sequenced in two runs", t, func() {
+		day1 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		day2 := day1.AddDate(0, 0, 1)
+		row := db.TrackedSample{
+			StudyID: "1", StudyName: "Study", FacultySponsor: "Sponsor", SangerSampleID: "SANG1",
+			OrderMade: &day1, Platform: "Illumina", SequencingRunStart: &day2,
+		}
+		run1, run2 := row, row
+		run1.RunID, run2.RunID = "RUN1", "RUN2"
+		run2.SequencingQCComplete = &day2
+
+		srv, err := server.New(server.Config{QueryProvider: &mockQueryProvider{
+			samples: &db.TrackedSampleCollection{Samples: []db.TrackedSample{run1, run2}},
+		}})
+		So(err, ShouldBeNil)
+
+		chart := func(query string) server.ChartData {
+			req := httptest.NewRequest("GET", "/api/chart?sponsor=Sponsor&study=Study"+query, nil)
+			resp := httptest.NewRecorder()
+			srv.ServeHTTP(resp, req)
+			So(resp.Code, ShouldEqual, http.StatusOK)
+
+			var data server.ChartData
+			So(json.Unmarshal(resp.Body.Bytes(), &data), ShouldBeNil)
+
+			return data
+		}
+
+		Convey("The run view should have a bar per run", func() {
+			So(len(chart("").SampleIds), ShouldEqual, 2)
+			So(len(chart("&view=run").SampleIds), ShouldEqual, 2)
+		})
+
+		Convey("The sample view should have a bar per sample, in the stage of its slowest run", func() {
+			data := chart("&view=sample")
+			So(data.SampleIds, ShouldResemble, []string{"SANG1"})
+			So(data.Stages, ShouldResemble, []string{"sequencing"})
+		})
+
+		Convey("The sample view table should list the sample's runs together", func() {
+			req := httptest.NewRequest("GET", "/api/samples?sponsor=Sponsor&study=Study&view=sample", nil)
+			resp := httptest.NewRecorder()
+			srv.ServeHTTP(resp, req)
+			So(resp.Code, ShouldEqual, http.StatusOK)
+			So(resp.Body.String(), ShouldContainSubstring, "<td>RUN1, RUN2</td>")
+		})
+
+		Convey("An unknown view should be rejected", func() {
+			req := httptest.NewRequest("GET", "/api/samples?sponsor=Sponsor&study=Study&view=lane", nil)
+			resp := httptest.NewRecorder()
+			srv.ServeHTTP(resp, req)
+			So(resp.Code, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
+
+// detailQueryProvider is a mockQueryProvider that gives samples of run RUN1 an
+// Illumina lane.
+type detailQueryProvider struct {
+	mockQueryProvider
+}
+
+func (d *detailQueryProvider) AddDetails(_ context.Context, samples []db.TrackedSample) error {
+	for i := range samples {
+		if samples[i].RunID == "RUN1" {
+			samples[i].Illumina = append(samples[i].Illumina, db.IlluminaLane{Lane: 3})
+		}
+	}
+
+	return nil
+}
+
+func TestSampleDetail(t *testing.T) {
+	Convey("Given a server with a provider of platform details", t, func() {
+		samples := &db.TrackedSampleCollection{Samples: []db.TrackedSample{
+			{SangerSampleID: "SANG1", RunID: "RUN1"},
+			{SangerSampleID: "SANG1", RunID: "RUN2"},
+			{SangerSampleID: "SANG2", RunID: "RUN1"},
+		}}
+
+		srv, err := server.New(server.Config{
+			QueryProvider: &detailQueryProvider{mockQueryProvider{samples: samples}},
+		})
+		So(err, ShouldBeNil)
+
+		get := func(id string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", "/api/sample?id="+id, nil)
+			resp := httptest.NewRecorder()
+			srv.ServeHTTP(resp, req)
+
+			return resp
+		}
+
+		Convey("A sample's runs are returned with their details", func() {
+			resp := get("SANG1")
+			So(resp.Code, ShouldEqual, http.StatusOK)
+
+			var data server.SampleResponse
+			So(json.Unmarshal(resp.Body.Bytes(), &data), ShouldBeNil)
+			So(len(data.Samples), ShouldEqual, 2)
+			So(data.Samples[0].Illumina, ShouldResemble, []db.IlluminaLane{{Lane: 3}})
+			So(data.Samples[1].Illumina, ShouldBeNil)
+			So(resp.Body.String(), ShouldNotContainSubstring, "PacBio")
+
+			Convey("without changing the cached samples", func() {
+				So(get("SANG1").Code, ShouldEqual, http.StatusOK)
+				So(samples.Samples[0].Illumina, ShouldBeNil)
+			})
+		})
+
+		Convey("Cached details are copied before more are added", func() {
+			cached := make([]db.IlluminaLane, 1, 2)
+			cached[0].Lane = 1
+			samples.Samples[2].Illumina = cached
+
+			resp := get("SANG2")
+			So(resp.Code, ShouldEqual, http.StatusOK)
+
+			var data server.SampleResponse
+			So(json.Unmarshal(resp.Body.Bytes(), &data), ShouldBeNil)
+			So(data.Samples[0].Illumina, ShouldResemble, []db.IlluminaLane{{Lane: 1}, {Lane: 3}})
+			So(cached[:2][1].Lane, ShouldEqual, 0)
+		})
+
+		Convey("An unknown or missing sample ID is rejected", func() {
+			So(get("SANG9").Code, ShouldEqual, http.StatusNotFound)
+			So(get("").Code, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
+
+// closingQueryProvider is a mockQueryProvider that records being closed.
+type closingQueryProvider struct {
+	mockQueryProvider
+	closed bool
+}
+
+func (c *closingQueryProvider) Close() error {
+	c.closed = true
+
+	return nil
+}
+
+// unhealthyQueryProvider is a mockQueryProvider whose database can't be
+// reached.
+type unhealthyQueryProvider struct {
+	mockQueryProvider
+}
+
+func (u *unhealthyQueryProvider) Health() error {
+	return errors.New("connection refused")
+}
+
+func TestHealth(t *testing.T) {
+	Convey("The health endpoint reports whether the database is reachable", t, func() {
+		for _, test := range []struct {
+			provider db.QueryProvider
+			status   int
+			response server.HealthResponse
+		}{
+			{&mockQueryProvider{}, http.StatusOK, server.HealthResponse{Healthy: true}},
+			{&unhealthyQueryProvider{}, http.StatusServiceUnavailable,
+				server.HealthResponse{Error: "connection refused"}},
+		} {
+			srv, err := server.New(server.Config{QueryProvider: test.provider})
+			So(err, ShouldBeNil)
+
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+			So(w.Code, ShouldEqual, test.status)
+
+			var response server.HealthResponse
+			So(json.Unmarshal(w.Body.Bytes(), &response), ShouldBeNil)
+			So(response, ShouldResemble, test.response)
+		}
+	})
+}
+
+// countingNotifier is a server.Notifier that reports each notification.
+type countingNotifier struct {
+	notified chan struct{}
+}
+
+func (c *countingNotifier) Notify(_ context.Context, _ []db.TrackedSample, _ time.Time) error {
+	select {
+	case c.notified <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func TestBackgroundRefresh(t *testing.T) {
+	Convey("Given a running server with a notifier and a short cache TTL", t, func() {
+		port, err := getAvailablePort()
+		So(err, ShouldBeNil)
+
+		notifier := &countingNotifier{notified: make(chan struct{}, 1)}
+		srv, err := server.New(server.Config{
+			QueryProvider: &mockQueryProvider{samples: &db.TrackedSampleCollection{}},
+			Port:          port,
+			CacheTTL:      20 * time.Millisecond,
+			Notifier:      notifier,
+		})
+		So(err, ShouldBeNil)
+
+		go srv.Start()
+
+		Convey("It notifies after refreshes without any requests", func() {
+			for range 3 {
+				select {
+				case <-notifier.notified:
+				case <-time.After(time.Second):
+					So("notifier was not called", ShouldBeEmpty)
+				}
+			}
+
+			So(srv.Shutdown(context.Background()), ShouldBeNil)
+		})
+	})
+}
+
+func TestShutdown(t *testing.T) {
+	Convey("Given a running server with a provider that can be closed", t, func() {
+		port, err := getAvailablePort()
+		So(err, ShouldBeNil)
+
+		provider := &closingQueryProvider{
+			mockQueryProvider: mockQueryProvider{samples: &db.TrackedSampleCollection{}},
+		}
+
+		srv, err := server.New(server.Config{QueryProvider: provider, Port: port})
+		So(err, ShouldBeNil)
+
+		started := make(chan error, 1)
+
+		go func() { started <- srv.Start() }()
+
+		url := "http://localhost:" + strconv.Itoa(port) + "/api/filters"
+		So(waitForServer(url), ShouldBeNil)
+
+		Convey("Shutting it down stops it cleanly and closes the provider", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			So(srv.Shutdown(ctx), ShouldBeNil)
+			So(<-started, ShouldBeNil)
+			So(provider.closed, ShouldBeTrue)
+
+			_, err := http.Get(url)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+// waitForServer polls url until it responds, or gives up after a few seconds.
+func waitForServer(url string) error {
+	var err error
+
+	for range 50 {
+		var resp *http.Response
+
+		if resp, err = http.Get(url); err == nil {
+			resp.Body.Close()
+
+			return nil
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return err
+}
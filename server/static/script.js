@@ -36,6 +36,9 @@ function loadFacultySponsors() {
                 option.textContent = sponsor;
                 sponsorSelect.appendChild(option);
             });
+
+            populateStages(data.stages);
+            populateTurnaround(data.milestones, data.units);
         })
         .catch(error => {
             console.error("Error fetching sponsors:", error);
@@ -43,6 +46,65 @@ function loadFacultySponsors() {
         });
 }
 
+// Populate the stage dropdown with the given stage names
+function populateStages(stages) {
+    if (!stages || !Array.isArray(stages)) {
+        return;
+    }
+
+    const stageSelect = document.getElementById('stage-select');
+    stageSelect.innerHTML = '<option value="">Any stage</option>';
+
+    stages.forEach(stage => {
+        const option = document.createElement('option');
+        option.value = stage;
+        option.textContent = stage;
+        stageSelect.appendChild(option);
+    });
+}
+
+// Populate the turnaround dropdowns with the given milestone and unit names
+function populateTurnaround(milestones, units) {
+    if (milestones && Array.isArray(milestones)) {
+        ['from-select', 'to-select'].forEach(id => {
+            const select = document.getElementById(id);
+            select.innerHTML = '<option value="">None</option>';
+
+            milestones.forEach(milestone => {
+                const option = document.createElement('option');
+                option.value = milestone;
+                option.textContent = milestone;
+                select.appendChild(option);
+            });
+        });
+    }
+
+    if (units && Array.isArray(units)) {
+        const unitSelect = document.getElementById('unit-select');
+        unitSelect.innerHTML = '';
+
+        units.forEach(unit => {
+            const option = document.createElement('option');
+            option.value = unit;
+            option.textContent = unit;
+            unitSelect.appendChild(option);
+        });
+    }
+}
+
+// Add the selected turnaround milestones and unit, if both milestones are
+// selected, to the given URLSearchParams
+function appendTurnaroundParams(params) {
+    const from = document.getElementById('from-select').value;
+    const to = document.getElementById('to-select').value;
+
+    if (from && to) {
+        params.append('from', from);
+        params.append('to', to);
+        params.append('unit', document.getElementById('unit-select').value);
+    }
+}
+
 // Process studies response 
 function processStudiesResponse(event) {
     if (event.detail.target.id === 'study-select') {
@@ -84,12 +146,13 @@ function handleSampleDataLoaded(event) {
     if (event.detail.target.id === 'samples-container') {
         const sponsor = document.getElementById('sponsor-select').value;
         const study = document.getElementById('study-select').value;
+        const stage = document.getElementById('stage-select').value;
 
         if (sponsor && study) {
             // Initialize pagination if we have a table
             initPagination();
             // Update chart
-            updateChartWithFilters(sponsor, study);
+            updateChartWithFilters(sponsor, study, stage);
         }
     }
 }
@@ -196,10 +259,15 @@ function showPage(pageNumber, rows, rowsPerPage, totalPages, infoElement) {
 }
 
 // Update chart with filter values
-function updateChartWithFilters(sponsor, study) {
+function updateChartWithFilters(sponsor, study, stage) {
     const params = new URLSearchParams();
     params.append('sponsor', sponsor);
     params.append('study', study);
+    if (stage) {
+        params.append('stage', stage);
+    }
+    params.append('view', document.getElementById('view-select').value);
+    appendTurnaroundParams(params);
 
     fetch('/api/chart?' + params.toString())
         .then(response => {
@@ -221,6 +289,18 @@ function updateChartWithFilters(sponsor, study) {
         });
 }
 
+// Download the samples matching the current filters as an Excel workbook
+function downloadWorkbook() {
+    const params = new URLSearchParams();
+    params.append('format', 'xlsx');
+    params.append('sponsor', document.getElementById('sponsor-select').value);
+    params.append('study', document.getElementById('study-select').value);
+    params.append('stage', document.getElementById('stage-select').value);
+    params.append('view', document.getElementById('view-select').value);
+
+    window.location = '/api/export?' + params.toString();
+}
+
 // Create and update chart
 let chart; // Global chart variable
 
@@ -232,6 +312,23 @@ function updateChart(data) {
     createChart(data);
 }
 
+// Return a dataset for the requested turnaround, if any, drawn as its own bar
+// rather than stacked with the processing times
+function turnaroundDatasets(data) {
+    if (!data.turnaround || !data.turnaroundLabel) {
+        return [];
+    }
+
+    return [{
+        label: 'Turnaround: ' + data.turnaroundLabel,
+        data: data.turnaround,
+        stack: 'turnaround',
+        backgroundColor: 'rgba(75, 192, 192, 0.7)',
+        borderColor: 'rgba(75, 192, 192, 1)',
+        borderWidth: 1
+    }];
+}
+
 function createChart(data) {
     // Check if we have data to display
     if (!data.labels || data.labels.length === 0) {
@@ -260,7 +357,7 @@ function createChart(data) {
                     borderColor: 'rgba(255, 99, 132, 1)',
                     borderWidth: 1
                 }
-            ]
+            ].concat(turnaroundDatasets(data))
         },
         options: {
             indexAxis: 'y',
@@ -290,7 +387,8 @@ function createChart(data) {
                         footer: function (tooltipItems) {
                             const item = tooltipItems[0];
                             const index = item.dataIndex;
-                            return 'Sample ID: ' + data.sampleIds[index];
+                            return 'Sample ID: ' + data.sampleIds[index] +
+                                '\nStage: ' + data.stages[index];
                         }
                     }
                 }
@@ -299,6 +397,57 @@ function createChart(data) {
     });
 }
 
+// Show the runs of a sample with their platform-specific metrics
+function showSampleDetail(sampleId) {
+    fetch('/api/sample?id=' + encodeURIComponent(sampleId))
+        .then(response => {
+            if (!response.ok) throw new Error(response.statusText);
+            return response.json();
+        })
+        .then(data => {
+            document.getElementById('sample-detail-title').textContent = 'Sample ' + sampleId;
+            const runs = document.getElementById('sample-detail-runs');
+            runs.replaceChildren(...data.samples.map(sampleDetailRun));
+            const panel = document.getElementById('sample-detail');
+            panel.classList.remove('hidden');
+            panel.scrollIntoView();
+        })
+        .catch(error => console.error('Error loading sample detail:', error));
+}
+
+// Build the drill-down section for one run of a sample
+function sampleDetailRun(sample) {
+    const section = document.createElement('div');
+    section.className = 'sample-detail-run';
+
+    const heading = document.createElement('h3');
+    heading.textContent = 'Run ' + (sample.RunID || 'not yet sequenced') +
+        (sample.Platform ? ' (' + sample.Platform + ')' : '');
+    section.appendChild(heading);
+
+    const rows = [
+        ...(sample.Illumina || []).map(l => ['Lane ' + l.Lane,
+            'yield ' + (l.YieldBases ?? '-') + ' bases, Q30 yield ' + (l.Q30YieldKb ?? '-') + ' kb']),
+        ...(sample.PacBio || []).map(w => ['Well ' + w.Well,
+            'movie ' + w.Movie + ', HiFi yield ' + (w.HiFiYieldBases ?? '-') + ' bases']),
+        ...(sample.ONT || []).map(f => ['Flowcell ' + f.FlowcellID, 'position ' + f.DevicePosition]),
+    ];
+
+    if (rows.length === 0) {
+        rows.push(['No run metrics available', '']);
+    }
+
+    const table = document.createElement('table');
+    rows.forEach(([name, value]) => {
+        const tr = table.insertRow();
+        tr.insertCell().textContent = name;
+        tr.insertCell().textContent = value;
+    });
+    section.appendChild(table);
+
+    return section;
+}
+
 // Setup event listeners
 document.addEventListener('DOMContentLoaded', function () {
     // Load faculty sponsors on page load
@@ -308,6 +457,7 @@ document.addEventListener('DOMContentLoaded', function () {
     document.getElementById('sponsor-select').addEventListener('change', function () {
         const studySelect = document.getElementById('study-select');
         const applyButton = document.getElementById('apply-filters');
+        const downloadButton = document.getElementById('download-xlsx');
 
         if (this.value) {
             studySelect.disabled = false;
@@ -315,6 +465,7 @@ document.addEventListener('DOMContentLoaded', function () {
             studySelect.disabled = true;
             studySelect.innerHTML = '<option value="">Select a study...</option>';
             applyButton.disabled = true;
+            downloadButton.disabled = true;
         }
     });
 
@@ -322,11 +473,21 @@ document.addEventListener('DOMContentLoaded', function () {
     document.getElementById('study-select').addEventListener('change', function () {
         const sponsorSelect = document.getElementById('sponsor-select');
         const applyButton = document.getElementById('apply-filters');
+        const downloadButton = document.getElementById('download-xlsx');
 
-        if (this.value && sponsorSelect.value) {
-            applyButton.disabled = false;
-        } else {
-            applyButton.disabled = true;
+        applyButton.disabled = !(this.value && sponsorSelect.value);
+        downloadButton.disabled = applyButton.disabled;
+    });
+
+    // Download button handler - fetches the filtered samples as a workbook
+    document.getElementById('download-xlsx').addEventListener('click', downloadWorkbook);
+
+    // Drill down into a sample when its ID is clicked in the table
+    document.getElementById('samples-container').addEventListener('click', function (event) {
+        const link = event.target.closest('.sample-link');
+        if (link) {
+            event.preventDefault();
+            showSampleDetail(link.dataset.sample);
         }
     });
 
@@ -340,11 +501,12 @@ document.addEventListener('DOMContentLoaded', function () {
     document.getElementById('apply-filters').addEventListener('click', function () {
         const sponsor = document.getElementById('sponsor-select').value;
         const study = document.getElementById('study-select').value;
+        const stage = document.getElementById('stage-select').value;
 
         if (sponsor && study) {
             // HTMX will handle the sample table update
             // We manually trigger chart update here
-            updateChartWithFilters(sponsor, study);
+            updateChartWithFilters(sponsor, study, stage);
         }
     });
 });
\ No newline at end of file
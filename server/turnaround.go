@@ -0,0 +1,132 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/wtsi-hgi/gst/db"
+	"github.com/wtsi-hgi/gst/metrics"
+)
+
+// turnaround describes a duration between two milestones that was requested
+// for display.
+type turnaround struct {
+	from db.Milestone
+	to   db.Milestone
+	unit metrics.Unit
+}
+
+// parseTurnaround returns the turnaround requested by the "from", "to" and
+// optional "unit" query parameters, or nil if none was requested.
+func parseTurnaround(r *http.Request) (*turnaround, error) {
+	query := r.URL.Query()
+	if query.Get("from") == "" && query.Get("to") == "" {
+		return nil, nil
+	}
+
+	from, err := db.ParseMilestone(query.Get("from"))
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := db.ParseMilestone(query.Get("to"))
+	if err != nil {
+		return nil, err
+	}
+
+	unit := metrics.Days
+	if name := query.Get("unit"); name != "" {
+		if unit, err = metrics.ParseUnit(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return &turnaround{from: from, to: to, unit: unit}, nil
+}
+
+// label describes the turnaround for display.
+func (t *turnaround) label() string {
+	return fmt.Sprintf("%s to %s (%s)", t.from, t.to, t.unit)
+}
+
+// values returns the turnaround of each sample, with ok false for those that
+// haven't reached both milestones.
+func (t *turnaround) values(cal *metrics.Calendar, samples []db.TrackedSample) (values []float64, ok []bool) {
+	values = make([]float64, len(samples))
+	ok = make([]bool, len(samples))
+
+	for i := range samples {
+		values[i], ok[i] = cal.Between(&samples[i], t.from, t.to, t.unit)
+	}
+
+	return values, ok
+}
+
+// formatted returns the turnaround of each sample formatted for the table,
+// with "-" for those that haven't reached both milestones.
+func (t *turnaround) formatted(cal *metrics.Calendar, samples []db.TrackedSample) []string {
+	values, ok := t.values(cal, samples)
+	formatted := make([]string, len(samples))
+
+	for i, v := range values {
+		switch {
+		case !ok[i]:
+			formatted[i] = "-"
+		case t.unit == metrics.Hours:
+			formatted[i] = fmt.Sprintf("%.1f", v)
+		default:
+			formatted[i] = fmt.Sprintf("%.0f", v)
+		}
+	}
+
+	return formatted
+}
+
+// getMilestoneNames returns the names of every milestone, in order.
+func getMilestoneNames() []string {
+	milestones := db.Milestones()
+	names := make([]string, len(milestones))
+
+	for i, m := range milestones {
+		names[i] = m.String()
+	}
+
+	return names
+}
+
+// getUnitNames returns the names of every unit.
+func getUnitNames() []string {
+	units := metrics.Units()
+	names := make([]string, len(units))
+
+	for i, u := range units {
+		names[i] = u.String()
+	}
+
+	return names
+}
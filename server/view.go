@@ -0,0 +1,64 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/wtsi-hgi/gst/db"
+)
+
+// view is how samples are presented: as a row per run, like the query results,
+// or as a row per sample summarising its runs.
+type view int
+
+const (
+	runView view = iota
+	sampleView
+)
+
+// parseView returns the view requested by the "view" query parameter, "run"
+// (the default) or "sample".
+func parseView(r *http.Request) (view, error) {
+	switch name := r.URL.Query().Get("view"); name {
+	case "", "run":
+		return runView, nil
+	case "sample":
+		return sampleView, nil
+	default:
+		return runView, fmt.Errorf("unknown view %q", name)
+	}
+}
+
+// apply returns the given per-run samples as this view presents them.
+func (v view) apply(samples []db.TrackedSample) []db.TrackedSample {
+	if v == runView {
+		return samples
+	}
+
+	return db.Summaries(db.GroupSamples(samples))
+}
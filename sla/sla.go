@@ -0,0 +1,211 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// Package sla checks samples against the turnaround targets promised for each
+// stage of their processing.
+package sla
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wtsi-hgi/gst/db"
+	"github.com/wtsi-hgi/gst/metrics"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule is the most time a sample may spend in a stage. If Platform or
+// Programme are set, the rule only applies to samples with that platform or
+// programme.
+type Rule struct {
+	Stage     string  `yaml:"stage"`
+	Platform  string  `yaml:"platform"`
+	Programme string  `yaml:"programme"`
+	Max       float64 `yaml:"max"`
+
+	// Unit is what Max is measured in: "days" (the default), "working days"
+	// or "hours".
+	Unit string `yaml:"unit"`
+
+	stage db.Stage
+	unit  metrics.Unit
+}
+
+// file is the layout of a rules file.
+type file struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rules is a set of validated Rules that samples can be checked against.
+type Rules struct {
+	rules    []Rule
+	calendar *metrics.Calendar
+}
+
+// New validates the given rules and returns them ready to check samples with.
+// Working days are counted with the given calendar.
+func New(rules []Rule, calendar *metrics.Calendar) (*Rules, error) {
+	parsed := make([]Rule, len(rules))
+
+	for i, rule := range rules {
+		if err := rule.parse(); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+
+		parsed[i] = rule
+	}
+
+	return &Rules{rules: parsed, calendar: calendar}, nil
+}
+
+// Load reads rules from the YAML file at path, which has a list of rules under
+// "rules", and validates them like New.
+func Load(path string, calendar *metrics.Calendar) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SLA rules: %w", err)
+	}
+
+	var f file
+	if err = yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse SLA rules %s: %w", path, err)
+	}
+
+	return New(f.Rules, calendar)
+}
+
+// parse checks the rule's stage, limit and unit.
+func (r *Rule) parse() error {
+	stage, err := db.ParseStage(r.Stage)
+	if err != nil {
+		return err
+	}
+
+	if r.Max <= 0 {
+		return fmt.Errorf("max for stage %q must be more than 0", r.Stage)
+	}
+
+	r.stage, r.unit = stage, metrics.Days
+	if r.Unit != "" {
+		r.unit, err = metrics.ParseUnit(r.Unit)
+	}
+
+	return err
+}
+
+// matches returns true if the rule applies to the given sample in the given
+// stage.
+func (r *Rule) matches(s *db.TrackedSample, stage db.Stage) bool {
+	return r.stage == stage &&
+		(r.Platform == "" || strings.EqualFold(r.Platform, s.Platform)) &&
+		(r.Programme == "" || strings.EqualFold(r.Programme, s.Programme))
+}
+
+// specificity returns how many of the optional restrictions the rule has.
+func (r *Rule) specificity() int {
+	n := 0
+
+	for _, v := range []string{r.Platform, r.Programme} {
+		if v != "" {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Breach describes a sample that has spent longer in its stage than its rule
+// allows.
+type Breach struct {
+	Sample  db.TrackedSample `json:"sample"`
+	Stage   string           `json:"stage"`
+	Elapsed float64          `json:"elapsed"`
+	Max     float64          `json:"max"`
+	Unit    string           `json:"unit"`
+}
+
+// String describes the breach.
+func (b Breach) String() string {
+	return fmt.Sprintf("%s (run %s): %s for %.1f %s, more than %g",
+		b.Sample.SangerSampleID, b.Sample.RunID, b.Stage, b.Elapsed, b.Unit, b.Max)
+}
+
+// Check returns the breach if the sample has been in its current stage for
+// longer than the most specific rule for it allows as of now, or false if it
+// hasn't or no rule applies. Of equally specific rules, the first is used.
+func (r *Rules) Check(s *db.TrackedSample, now time.Time) (Breach, bool) {
+	rule := r.ruleFor(s)
+	if rule == nil {
+		return Breach{}, false
+	}
+
+	elapsed := r.calendar.Duration(*rule.stage.Milestone().Time(s), now, rule.unit)
+	if elapsed <= rule.Max {
+		return Breach{}, false
+	}
+
+	return Breach{
+		Sample:  *s,
+		Stage:   rule.stage.String(),
+		Elapsed: elapsed,
+		Max:     rule.Max,
+		Unit:    rule.unit.String(),
+	}, true
+}
+
+// ruleFor returns the most specific rule for the sample's current stage, or
+// nil if there isn't one.
+func (r *Rules) ruleFor(s *db.TrackedSample) *Rule {
+	stage := s.Stage()
+	if stage == db.StageUnknown {
+		return nil
+	}
+
+	var best *Rule
+
+	for i := range r.rules {
+		rule := &r.rules[i]
+		if rule.matches(s, stage) && (best == nil || rule.specificity() > best.specificity()) {
+			best = rule
+		}
+	}
+
+	return best
+}
+
+// Overdue returns the breaches of all the given samples as of now.
+func (r *Rules) Overdue(samples []db.TrackedSample, now time.Time) []Breach {
+	var breaches []Breach
+
+	for i := range samples {
+		if b, ok := r.Check(&samples[i], now); ok {
+			breaches = append(breaches, b)
+		}
+	}
+
+	return breaches
+}
@@ -0,0 +1,110 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package sla_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+	"github.com/wtsi-hgi/gst/metrics"
+	"github.com/wtsi-hgi/gst/sla"
+)
+
+func TestSLA(t *testing.T) {
+	Convey("Given rules for the library stage, stricter for PacBio", t, func() {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "sla.yaml")
+		err := os.WriteFile(path, []byte(`rules:
+  - stage: library in progress
+    max: 5
+    unit: working days
+  - stage: Library in progress
+    platform: pacbio
+    max: 2
+  - stage: sequencing
+    max: 48
+    unit: hours
+`), 0600)
+		So(err, ShouldBeNil)
+
+		rules, err := sla.Load(path, metrics.NewCalendar())
+		So(err, ShouldBeNil)
+
+		// Friday 3rd January 2025
+		start := time.Date(2025, 1, 3, 9, 0, 0, 0, time.UTC)
+		illumina := db.TrackedSample{SangerSampleID: "ill", Platform: "Illumina", LibraryStart: &start}
+		pacbio := db.TrackedSample{SangerSampleID: "pb", Platform: "PacBio", LibraryStart: &start}
+		complete := db.TrackedSample{SangerSampleID: "done", LibraryStart: &start, LibraryComplete: &start}
+
+		Convey("Samples within the limit of their rule should not be overdue", func() {
+			_, overdue := rules.Check(&illumina, start.AddDate(0, 0, 7))
+			So(overdue, ShouldBeFalse)
+		})
+
+		Convey("Samples past the limit of their rule should be overdue", func() {
+			b, overdue := rules.Check(&illumina, start.AddDate(0, 0, 10))
+			So(overdue, ShouldBeTrue)
+			So(b.Stage, ShouldEqual, "library in progress")
+			So(b.Elapsed, ShouldEqual, 6)
+			So(b.Max, ShouldEqual, 5)
+			So(b.Unit, ShouldEqual, "working days")
+		})
+
+		Convey("The most specific rule should apply", func() {
+			b, overdue := rules.Check(&pacbio, start.AddDate(0, 0, 3))
+			So(overdue, ShouldBeTrue)
+			So(b.Unit, ShouldEqual, "days")
+			So(b.Elapsed, ShouldEqual, 3)
+		})
+
+		Convey("Samples in a stage without a rule should not be overdue", func() {
+			_, overdue := rules.Check(&complete, start.AddDate(1, 0, 0))
+			So(overdue, ShouldBeFalse)
+		})
+
+		Convey("Overdue should list just the breaches", func() {
+			breaches := rules.Overdue([]db.TrackedSample{illumina, pacbio, complete}, start.AddDate(0, 0, 3))
+			So(len(breaches), ShouldEqual, 1)
+			So(breaches[0].Sample.SangerSampleID, ShouldEqual, "pb")
+			So(breaches[0].String(), ShouldEqual, "pb (run ): library in progress for 3.0 days, more than 2")
+		})
+	})
+
+	Convey("Invalid rules should be rejected", t, func() {
+		_, err := sla.New([]sla.Rule{{Stage: "nonsense", Max: 1}}, metrics.NewCalendar())
+		So(err, ShouldNotBeNil)
+
+		_, err = sla.New([]sla.Rule{{Stage: "ordered"}}, metrics.NewCalendar())
+		So(err, ShouldNotBeNil)
+
+		_, err = sla.New([]sla.Rule{{Stage: "ordered", Max: 1, Unit: "fortnights"}}, metrics.NewCalendar())
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,237 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// Package snapshot stores timestamped copies of query results in a local
+// directory, so that past results can be listed, reloaded and compared.
+package snapshot
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wtsi-hgi/gst/db"
+)
+
+const (
+	// fileSuffix is the extension of snapshot files: gzipped JSON Lines.
+	fileSuffix = ".jsonl.gz"
+
+	// timeLayout is how a snapshot's time is written in its ID.
+	timeLayout = "20060102T150405.000000000Z"
+
+	// idSeparator separates the time and fingerprint in a snapshot's ID.
+	idSeparator = "_"
+
+	// fingerprintLength is the number of hex characters in a fingerprint.
+	fingerprintLength = 12
+)
+
+// ErrNotFound is returned when loading a snapshot that doesn't exist.
+var ErrNotFound = errors.New("snapshot not found")
+
+// Info describes a stored snapshot.
+type Info struct {
+	// ID uniquely identifies the snapshot within its store.
+	ID string
+
+	// Time is when the snapshot was saved.
+	Time time.Time
+
+	// Fingerprint identifies the query that produced the snapshot.
+	Fingerprint string
+}
+
+// Fingerprint returns a short hash of the given parts, suitable for
+// identifying a query and its arguments.
+func Fingerprint(parts ...any) string {
+	h := sha256.New()
+
+	for _, part := range parts {
+		fmt.Fprintf(h, "%v\x00", part)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:fingerprintLength]
+}
+
+// Store is a directory of snapshot files.
+type Store struct {
+	dir string
+}
+
+// Open returns a Store that keeps its snapshots in dir, creating it if
+// necessary.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	return &Store{dir: dir}, nil
+}
+
+// Save stores the given samples as a new snapshot taken now.
+func (s *Store) Save(samples *db.TrackedSampleCollection, fingerprint string) (Info, error) {
+	w, err := s.Create(fingerprint)
+	if err != nil {
+		return Info{}, err
+	}
+
+	for i := range samples.Samples {
+		if err = w.Write(&samples.Samples[i]); err != nil {
+			w.Abort()
+
+			return Info{}, err
+		}
+	}
+
+	if err = w.Close(); err != nil {
+		return Info{}, err
+	}
+
+	return w.Info, nil
+}
+
+// List returns every snapshot in the store, oldest first.
+func (s *Store) List() ([]Info, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	var infos []Info
+
+	for _, entry := range entries {
+		id, isSnapshot := strings.CutSuffix(entry.Name(), fileSuffix)
+		if !isSnapshot {
+			continue
+		}
+
+		if info, ok := parseID(id); ok {
+			infos = append(infos, info)
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Time.Before(infos[j].Time) })
+
+	return infos, nil
+}
+
+// Load returns the samples stored in the snapshot with the given ID.
+func (s *Store) Load(id string) (*db.TrackedSampleCollection, error) {
+	f, err := os.Open(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s is corrupt: %w", id, err)
+	}
+
+	return decodeSamples(gz)
+}
+
+// Prune deletes all but the newest keep snapshots, returning those deleted.
+func (s *Store) Prune(keep int) ([]Info, error) {
+	infos, err := s.List()
+	if err != nil || len(infos) <= keep {
+		return nil, err
+	}
+
+	pruned := infos[:len(infos)-keep]
+
+	for _, info := range pruned {
+		if err = os.Remove(s.path(info.ID)); err != nil {
+			return nil, err
+		}
+	}
+
+	return pruned, nil
+}
+
+// path returns the path of the file holding the snapshot with the given ID.
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+fileSuffix)
+}
+
+// newInfo returns the Info of a snapshot taken at t.
+func newInfo(t time.Time, fingerprint string) Info {
+	t = t.UTC()
+
+	return Info{
+		ID:          t.Format(timeLayout) + idSeparator + fingerprint,
+		Time:        t,
+		Fingerprint: fingerprint,
+	}
+}
+
+// parseID returns the Info encoded in the given snapshot ID.
+func parseID(id string) (Info, bool) {
+	timestamp, fingerprint, ok := strings.Cut(id, idSeparator)
+	if !ok {
+		return Info{}, false
+	}
+
+	t, err := time.Parse(timeLayout, timestamp)
+	if err != nil {
+		return Info{}, false
+	}
+
+	return Info{ID: id, Time: t, Fingerprint: fingerprint}, true
+}
+
+// decodeSamples reads samples encoded one per line as JSON.
+func decodeSamples(r io.Reader) (*db.TrackedSampleCollection, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	collection := &db.TrackedSampleCollection{}
+
+	for {
+		var sample db.TrackedSample
+
+		err := dec.Decode(&sample)
+		if errors.Is(err, io.EOF) {
+			return collection, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+		}
+
+		collection.Samples = append(collection.Samples, sample)
+	}
+}
@@ -0,0 +1,143 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package snapshot_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/gst/db"
+	"github.com/wtsi-hgi/gst/snapshot"
+)
+
+func TestStore(t *testing.T) {
+	Convey("Given a new snapshot store", t, func() {
+		dir := filepath.Join(t.TempDir(), "snapshots")
+		store, err := snapshot.Open(dir)
+		So(err, ShouldBeNil)
+
+		sampleTime := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+		libraryTime := 5
+		samples := &db.TrackedSampleCollection{Samples: []db.TrackedSample{
+			{SangerSampleID: "S1", RunID: "R1", ManifestCreated: &sampleTime, LibraryTime: &libraryTime},
+			{SangerSampleID: "S2"},
+		}}
+
+		Convey("It should have no snapshots", func() {
+			infos, err := store.List()
+			So(err, ShouldBeNil)
+			So(infos, ShouldBeEmpty)
+		})
+
+		Convey("When saving a snapshot", func() {
+			info, err := store.Save(samples, "abc123")
+			So(err, ShouldBeNil)
+
+			Convey("It should be listed with its time and fingerprint", func() {
+				infos, err := store.List()
+				So(err, ShouldBeNil)
+				So(infos, ShouldResemble, []snapshot.Info{info})
+				So(info.Fingerprint, ShouldEqual, "abc123")
+				So(time.Since(info.Time), ShouldBeLessThan, time.Minute)
+			})
+
+			Convey("It should load back with the same samples", func() {
+				loaded, err := store.Load(info.ID)
+				So(err, ShouldBeNil)
+				So(len(loaded.Samples), ShouldEqual, 2)
+				So(loaded.Samples[0].SangerSampleID, ShouldEqual, "S1")
+				So(loaded.Samples[0].ManifestCreated.Equal(sampleTime), ShouldBeTrue)
+				So(*loaded.Samples[0].LibraryTime, ShouldEqual, 5)
+				So(loaded.Samples[1].ManifestCreated, ShouldBeNil)
+			})
+
+			Convey("It should leave no temporary files behind", func() {
+				entries, err := os.ReadDir(dir)
+				So(err, ShouldBeNil)
+				So(len(entries), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When saving several snapshots and pruning", func() {
+			var saved []snapshot.Info
+
+			for range 3 {
+				info, err := store.Save(samples, "abc123")
+				So(err, ShouldBeNil)
+
+				saved = append(saved, info)
+			}
+
+			pruned, err := store.Prune(1)
+			So(err, ShouldBeNil)
+
+			Convey("Only the newest should remain", func() {
+				So(pruned, ShouldResemble, saved[:2])
+
+				infos, err := store.List()
+				So(err, ShouldBeNil)
+				So(infos, ShouldResemble, saved[2:])
+			})
+		})
+
+		Convey("When an aborted snapshot was being written", func() {
+			w, err := store.Create("abc123")
+			So(err, ShouldBeNil)
+			So(w.Write(&samples.Samples[0]), ShouldBeNil)
+			w.Abort()
+
+			Convey("It should not be listed or left on disk", func() {
+				infos, err := store.List()
+				So(err, ShouldBeNil)
+				So(infos, ShouldBeEmpty)
+
+				entries, err := os.ReadDir(dir)
+				So(err, ShouldBeNil)
+				So(entries, ShouldBeEmpty)
+			})
+		})
+
+		Convey("Loading an unknown snapshot should fail", func() {
+			_, err := store.Load("missing")
+			So(errors.Is(err, snapshot.ErrNotFound), ShouldBeTrue)
+		})
+	})
+}
+
+func TestFingerprint(t *testing.T) {
+	Convey("Fingerprints should be short and depend on every part", t, func() {
+		a := snapshot.Fingerprint("SELECT", "x", 2)
+
+		So(len(a), ShouldEqual, 12)
+		So(snapshot.Fingerprint("SELECT", "x", 2), ShouldEqual, a)
+		So(snapshot.Fingerprint("SELECT", "x", 3), ShouldNotEqual, a)
+		So(snapshot.Fingerprint("SELECT", "x2"), ShouldNotEqual, snapshot.Fingerprint("SELECT", "x", "2"))
+	})
+}
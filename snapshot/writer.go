@@ -0,0 +1,116 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package snapshot
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/wtsi-hgi/gst/db"
+)
+
+// Writer adds samples to a new snapshot one at a time. The snapshot only
+// appears in its store once the Writer is closed.
+type Writer struct {
+	// Info describes the snapshot being written.
+	Info Info
+
+	store *Store
+	file  *os.File
+	buf   *bufio.Writer
+	gz    *gzip.Writer
+	enc   *json.Encoder
+}
+
+// Create starts a new snapshot taken now, for the query with the given
+// fingerprint.
+func (s *Store) Create(fingerprint string) (*Writer, error) {
+	f, err := os.CreateTemp(s.dir, ".tmp-*"+fileSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	buf := bufio.NewWriter(f)
+	gz := gzip.NewWriter(buf)
+
+	return &Writer{
+		Info:  newInfo(time.Now(), fingerprint),
+		store: s,
+		file:  f,
+		buf:   buf,
+		gz:    gz,
+		enc:   json.NewEncoder(gz),
+	}, nil
+}
+
+// Write adds the given sample to the snapshot.
+func (w *Writer) Write(sample *db.TrackedSample) error {
+	return w.enc.Encode(sample)
+}
+
+// Close finishes writing the snapshot and adds it to the store. If this fails
+// the snapshot is discarded.
+func (w *Writer) Close() error {
+	err := w.finish()
+	if err == nil {
+		err = os.Rename(w.file.Name(), w.store.path(w.Info.ID))
+	}
+
+	if err != nil {
+		os.Remove(w.file.Name())
+
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// Abort discards the snapshot.
+func (w *Writer) Abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+// finish flushes and closes the temporary file.
+func (w *Writer) finish() error {
+	if err := w.gz.Close(); err != nil {
+		w.file.Close()
+
+		return err
+	}
+
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+
+		return err
+	}
+
+	return w.file.Close()
+}
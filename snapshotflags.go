@@ -0,0 +1,100 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package main
+
+import (
+	"flag"
+
+	"github.com/wtsi-hgi/gst/snapshot"
+)
+
+// snapshotFlags are the command line options for saving snapshots of query
+// results.
+type snapshotFlags struct {
+	dir  *string
+	keep *int
+}
+
+// addSnapshotFlags defines the snapshot flags on fs.
+func addSnapshotFlags(fs *flag.FlagSet) *snapshotFlags {
+	return &snapshotFlags{
+		dir:  fs.String("snapshots", "", "Directory to save a snapshot of each query result in (empty to not save)"),
+		keep: fs.Int("snapshotKeep", 0, "Number of newest snapshots to keep (0 to keep all)"),
+	}
+}
+
+// store opens the configured snapshot store, returning nil if there isn't one.
+func (f *snapshotFlags) store() (*snapshot.Store, error) {
+	if *f.dir == "" {
+		return nil, nil
+	}
+
+	return snapshot.Open(*f.dir)
+}
+
+// create starts a new snapshot in the configured store, returning nil if there
+// isn't one.
+func (f *snapshotFlags) create(fingerprint string) (*snapshot.Writer, error) {
+	store, err := f.store()
+	if store == nil || err != nil {
+		return nil, err
+	}
+
+	return store.Create(fingerprint)
+}
+
+// commit saves the given snapshot, if not nil, then prunes old snapshots if
+// configured to.
+func (f *snapshotFlags) commit(w *snapshot.Writer) error {
+	if w == nil {
+		return nil
+	}
+
+	if err := w.Close(); err != nil || *f.keep <= 0 {
+		return err
+	}
+
+	store, err := f.store()
+	if err != nil {
+		return err
+	}
+
+	_, err = store.Prune(*f.keep)
+
+	return err
+}
+
+// queryFingerprint identifies the query that will be run as spec says,
+// or the mock data file if mockPath isn't empty.
+func queryFingerprint(mockPath string, spec querySpec) string {
+	if mockPath != "" {
+		return snapshot.Fingerprint("mock", mockPath)
+	}
+
+	query, args := spec.params.Build(spec.sql())
+
+	return snapshot.Fingerprint(append([]any{query}, args...)...)
+}
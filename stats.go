@@ -0,0 +1,134 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/wtsi-hgi/gst/db"
+	"github.com/wtsi-hgi/gst/metrics"
+)
+
+// runStats implements the stats subcommand, summarising turnaround times of
+// the queried samples.
+func runStats(args []string) {
+	statsCmd := flag.NewFlagSet("stats", flag.ExitOnError)
+	by := statsCmd.String("by", "sponsor", "Group on: sponsor, study, programme, platform or pipeline")
+	format := statsCmd.String("format", "text", "Output format: text or json")
+	mockPath := statsCmd.String("mock", "", "Path to a sample file (TSV, CSV, JSON or JSON Lines, optionally gzipped) to read instead of the database")
+	strict := statsCmd.Bool("strict", false, "Fail on malformed values in the --mock file, listing each one")
+	timeout := statsCmd.Duration("timeout", 0, "Abandon the query if it takes longer than this (0 for no limit)")
+	query := addQueryFlags(statsCmd)
+
+	statsCmd.Parse(args)
+
+	groupBy, err := metrics.ParseGroupBy(*by)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in grouping: %v\n", err)
+		os.Exit(1)
+	}
+
+	samples, err := querySamples(*mockPath, *timeout, query.mustSpec(mustConfig(*query.config)), strictOptions(*strict)...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error querying samples: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err = writeStats(os.Stdout, groupBy, metrics.Stats(samples.Samples, groupBy), *format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing stats: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// querySamples reads the samples in the TSV file at mockPath, with any extra
+// mockOpts, or queries the database as spec says if mockPath is empty.
+func querySamples(mockPath string, timeout time.Duration, spec querySpec,
+	mockOpts ...db.Option) (*db.TrackedSampleCollection, error) {
+	ctx, cancel := exportContext(timeout)
+	defer cancel()
+
+	opts := spec.options()
+	if mockPath != "" {
+		opts = append([]db.Option{db.WithMockData(mockPath)}, mockOpts...)
+	}
+
+	provider, err := db.New(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer closeProvider(provider)
+
+	return provider.ExecuteContext(ctx)
+}
+
+// writeStats writes stats to w in the given format.
+func writeStats(w io.Writer, by metrics.GroupBy, stats []metrics.GroupStats, format string) error {
+	switch format {
+	case "text":
+		return writeStatsText(w, by, stats)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(stats)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// writeStatsText writes stats to w as an aligned table, with a row for each
+// time of each group. Times are in days.
+func writeStatsText(w io.Writer, by metrics.GroupBy, stats []metrics.GroupStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "%s\tTIME (DAYS)\tCOUNT\tMEDIAN\tMEAN\tP90\tMAX\n", strings.ToUpper(by.String()))
+
+	for _, g := range stats {
+		writeSummaryRow(tw, g.Group, "library", g.LibraryTime)
+		writeSummaryRow(tw, g.Group, "sequencing", g.SequencingTime)
+		writeSummaryRow(tw, g.Group, "manifest to QC", g.EndToEnd)
+	}
+
+	return tw.Flush()
+}
+
+// writeSummaryRow writes one row of the stats table.
+func writeSummaryRow(w io.Writer, group, name string, s metrics.Summary) {
+	if group == "" {
+		group = "-"
+	}
+
+	fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\t%.1f\t%.1f\t%.1f\n",
+		group, name, s.Count, s.Median, s.Mean, s.P90, s.Max)
+}
@@ -0,0 +1,121 @@
+/*******************************************************************************
+ * Copyright (c) 2025 Genome Research Ltd.
+ *
+ * Author: Sendu Bala <sb10@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/wtsi-hgi/gst/db"
+)
+
+// runValidate implements the validate subcommand, reporting data problems in
+// the queried samples and exiting non-zero if there are any.
+func runValidate(args []string) {
+	validateCmd := flag.NewFlagSet("validate", flag.ExitOnError)
+	ruleNames := validateCmd.String("rules", "", "Comma separated names of the rules to check (default all)")
+	listRules := validateCmd.Bool("list", false, "List the rules that can be checked and exit")
+	format := validateCmd.String("format", "text", "Output format: text or json")
+	mockPath := validateCmd.String("mock", "", "Path to a sample file (TSV, CSV, JSON or JSON Lines, optionally gzipped) to read instead of the database")
+	strict := validateCmd.Bool("strict", false, "Fail on malformed values in the --mock file, listing each one")
+	timeout := validateCmd.Duration("timeout", 0, "Abandon the query if it takes longer than this (0 for no limit)")
+	query := addQueryFlags(validateCmd)
+
+	validateCmd.Parse(args)
+
+	if *listRules {
+		writeValidationRules(os.Stdout)
+
+		return
+	}
+
+	rules, err := db.SelectValidationRules(splitList(*ruleNames)...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in rules: %v\n", err)
+		os.Exit(1)
+	}
+
+	samples, err := querySamples(*mockPath, *timeout, query.mustSpec(mustConfig(*query.config)), strictOptions(*strict)...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error querying samples: %v\n", err)
+		os.Exit(1)
+	}
+
+	violations := samples.Validate(rules...)
+
+	if err = writeViolations(os.Stdout, violations, *format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing violations: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(violations) > 0 {
+		os.Exit(exitProblems)
+	}
+}
+
+// strictOptions returns the options for strict TSV parsing if strict is true.
+func strictOptions(strict bool) []db.Option {
+	if !strict {
+		return nil
+	}
+
+	return []db.Option{db.WithStrictParsing()}
+}
+
+// writeValidationRules lists the names and descriptions of the built-in rules.
+func writeValidationRules(w io.Writer) {
+	for _, rule := range db.ValidationRules() {
+		fmt.Fprintf(w, "%s: %s\n", rule.Name, rule.Description)
+	}
+}
+
+// writeViolations writes violations to w in the given format.
+func writeViolations(w io.Writer, violations []db.Violation, format string) error {
+	switch format {
+	case "text":
+		for _, v := range violations {
+			if _, err := fmt.Fprintln(w, v); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case "json":
+		if violations == nil {
+			violations = []db.Violation{}
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(violations)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}